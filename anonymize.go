@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/fgrosse/fritz-mon/tr064"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// anonymizedLabels contains the names of all labels whose values describe the
+// household, e.g. the names of devices and rooms or MAC addresses, by the
+// prefix of their pseudonyms.
+var anonymizedLabels = map[string]string{
+	"box":         "box",
+	"device_name": "device",
+	"name":        "host",
+	"mac":         "mac",
+	"ssid":        "ssid",
+	"button":      "button",
+	"repeater":    "repeater",
+	"peer":        "node",
+	"template":    "template",
+	"cell_id":     "cell",
+}
+
+// pseudonymLength is the number of hex digits of the hash in each pseudonym.
+const pseudonymLength = 12
+
+// pseudonymizer replaces household details with pseudonyms. The pseudonyms
+// are derived from the values via HMAC, so they are stable across restarts and
+// collections but cannot be reversed without the key, even for values with few
+// possibilities such as MAC addresses. A nil pseudonymizer keeps all values,
+// so callers do not need to check if anonymization is enabled.
+type pseudonymizer struct {
+	key []byte
+}
+
+// pseudonym returns the pseudonym of a value, e.g. "mac-3fa9c2d1e07b". Empty
+// values are kept since they do not reveal anything.
+func (p *pseudonymizer) pseudonym(prefix, value string) string {
+	if p == nil || value == "" {
+		return value
+	}
+
+	mac := hmac.New(sha256.New, p.key)
+	_, _ = mac.Write([]byte(value))
+	return prefix + "-" + hex.EncodeToString(mac.Sum(nil))[:pseudonymLength]
+}
+
+// label returns the pseudonym of the value of the label with the given name
+// if it is one of the anonymizedLabels.
+func (p *pseudonymizer) label(name, value string) string {
+	prefix, ok := anonymizedLabels[name]
+	if !ok {
+		return value
+	}
+	return p.pseudonym(prefix, value)
+}
+
+// deviceErrors returns the failed requests of a collection by the pseudonyms
+// of the device names.
+func (p *pseudonymizer) deviceErrors(errs map[string]string) map[string]string {
+	if p == nil || errs == nil {
+		return errs
+	}
+
+	result := make(map[string]string, len(errs))
+	for name, err := range errs {
+		result[p.label("device_name", name)] = err
+	}
+	return result
+}
+
+// data returns a copy of the raw data of a collection with all household
+// details replaced by pseudonyms. The values which are also exported as labels
+// get the same pseudonyms as the labels.
+func (p *pseudonymizer) data(data interface{}) interface{} {
+	if p == nil {
+		return data
+	}
+
+	switch data := data.(type) {
+	case []fritzbox.Device:
+		result := make([]fritzbox.Device, len(data))
+		for i, d := range data {
+			d.Name = p.label("device_name", d.Name)
+			d.Buttons = append([]fritzbox.ButtonInfo(nil), d.Buttons...)
+			for j := range d.Buttons {
+				d.Buttons[j].Name = p.label("button", d.Buttons[j].Name)
+			}
+			result[i] = d
+		}
+		return result
+
+	case []tr064.Host:
+		result := make([]tr064.Host, len(data))
+		for i, h := range data {
+			h.HostName = p.label("name", h.HostName)
+			h.MACAddress = p.label("mac", h.MACAddress)
+			h.IPAddress = p.pseudonym("ip", h.IPAddress)
+			result[i] = h
+		}
+		return result
+
+	case []tr064.WLANStatistics:
+		result := make([]tr064.WLANStatistics, len(data))
+		for i, s := range data {
+			s.SSID = p.label("ssid", s.SSID)
+			s.Clients = append([]tr064.AssociatedDevice(nil), s.Clients...)
+			for j := range s.Clients {
+				s.Clients[j].MACAddress = p.label("mac", s.Clients[j].MACAddress)
+				s.Clients[j].IPAddress = p.pseudonym("ip", s.Clients[j].IPAddress)
+			}
+			result[i] = s
+		}
+		return result
+
+	case []tr064.Backhaul:
+		result := make([]tr064.Backhaul, len(data))
+		for i, b := range data {
+			b.Repeater = p.label("repeater", b.Repeater)
+			b.MACAddress = p.label("mac", b.MACAddress)
+			b.ConnectedNode = p.label("peer", b.ConnectedNode)
+			result[i] = b
+		}
+		return result
+
+	case []fritzbox.EventLogEntry:
+		// The messages contain names and MAC addresses at arbitrary
+		// positions, so the whole message is replaced.
+		result := make([]fritzbox.EventLogEntry, len(data))
+		for i, e := range data {
+			e.Message = p.pseudonym("event", e.Message)
+			result[i] = e
+		}
+		return result
+
+	case *WANReadings:
+		if data == nil {
+			return data
+		}
+		result := *data
+		result.ExternalIPv4 = p.pseudonym("ip", result.ExternalIPv4)
+		result.ExternalIPv6 = p.pseudonym("ip", result.ExternalIPv6)
+		return &result
+
+	case *SystemReadings:
+		if data == nil || data.Info == nil {
+			return data
+		}
+		result, info := *data, *data.Info
+		info.SerialNumber = p.pseudonym("serial", info.SerialNumber)
+		result.Info = &info
+		return &result
+
+	case *fritzbox.MobileStatus:
+		if data == nil {
+			return data
+		}
+		result := *data
+		result.CellID = p.pseudonym("cell", result.CellID)
+		return &result
+
+	default:
+		return data
+	}
+}
+
+// anonymizingGatherer is a prometheus.Gatherer which replaces the values of
+// all anonymizedLabels with pseudonyms.
+type anonymizingGatherer struct {
+	prometheus.Gatherer
+	pseudonyms *pseudonymizer
+}
+
+func (g anonymizingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			for _, label := range m.GetLabel() {
+				value := g.pseudonyms.label(label.GetName(), label.GetValue())
+				label.Value = &value
+			}
+		}
+	}
+
+	return families, err
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/fgrosse/fritz-mon/tr064"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+func TestPseudonymizerIsStableAndNilSafe(t *testing.T) {
+	p := &pseudonymizer{key: []byte("secret")}
+
+	a := p.label("mac", "00:11:22:33:44:55")
+	if a != p.label("mac", "00:11:22:33:44:55") {
+		t.Error("pseudonyms of the same value differ")
+	}
+	if !strings.HasPrefix(a, "mac-") || len(a) != len("mac-")+pseudonymLength {
+		t.Errorf("unexpected pseudonym %q", a)
+	}
+	if other := (&pseudonymizer{key: []byte("other")}).label("mac", "00:11:22:33:44:55"); other == a {
+		t.Error("pseudonyms do not depend on the key")
+	}
+	if got := p.label("interface", "802.11"); got != "802.11" {
+		t.Errorf("value of a label which is not anonymized was replaced with %q", got)
+	}
+
+	var disabled *pseudonymizer
+	if got := disabled.label("mac", "00:11:22:33:44:55"); got != "00:11:22:33:44:55" {
+		t.Errorf("nil pseudonymizer replaced value with %q", got)
+	}
+}
+
+func TestPseudonymizerData(t *testing.T) {
+	p := &pseudonymizer{key: []byte("secret")}
+
+	devices := []fritzbox.Device{{Name: "Living room", Buttons: []fritzbox.ButtonInfo{{Name: "Living room top"}}}}
+	anonymized := p.data(devices).([]fritzbox.Device)
+	if anonymized[0].Name != p.label("device_name", "Living room") {
+		t.Errorf("device name is %q", anonymized[0].Name)
+	}
+	if anonymized[0].Buttons[0].Name != p.label("button", "Living room top") {
+		t.Errorf("button name is %q", anonymized[0].Buttons[0].Name)
+	}
+	if devices[0].Name != "Living room" || devices[0].Buttons[0].Name != "Living room top" {
+		t.Error("original data was modified")
+	}
+
+	hosts := p.data([]tr064.Host{{HostName: "laptop", MACAddress: "00:11:22:33:44:55", IPAddress: "192.168.178.20"}}).([]tr064.Host)
+	for _, v := range []string{hosts[0].HostName, hosts[0].MACAddress, hosts[0].IPAddress} {
+		if v == "laptop" || v == "00:11:22:33:44:55" || v == "192.168.178.20" {
+			t.Errorf("host detail %q was not replaced", v)
+		}
+	}
+
+	wan := p.data(&WANReadings{ExternalIPv4: "203.0.113.1"}).(*WANReadings)
+	if wan.ExternalIPv4 == "203.0.113.1" {
+		t.Error("external IP address was not replaced")
+	}
+}
+
+func TestGathererAnonymizesCellID(t *testing.T) {
+	p := &pseudonymizer{key: []byte("secret")}
+	m := NewMobileMetrics(zap.NewNop())
+	m.CellInfo.WithLabelValues("LTE", "B20", "26202-1234567").Set(1)
+
+	r := prometheus.NewRegistry()
+	r.MustRegister(m.CellInfo)
+	families, err := anonymizingGatherer{Gatherer: r, pseudonyms: p}.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]string{}
+	for _, label := range families[0].GetMetric()[0].GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+
+	if want := p.pseudonym("cell", "26202-1234567"); labels["cell_id"] != want {
+		t.Errorf("cell_id = %q, want %q", labels["cell_id"], want)
+	}
+	if labels["technology"] != "LTE" || labels["band"] != "B20" {
+		t.Errorf("labels which are not anonymized were replaced: %v", labels)
+	}
+
+	// The raw data gets the same pseudonym as the label.
+	status := p.data(&fritzbox.MobileStatus{CellID: "26202-1234567"}).(*fritzbox.MobileStatus)
+	if status.CellID != labels["cell_id"] {
+		t.Errorf("cell ID in data = %q, want %q", status.CellID, labels["cell_id"])
+	}
+}
+
+func TestWebhookPayloadIsAnonymized(t *testing.T) {
+	var payload webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		payload.Data = &[]fritzbox.Device{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	p := &pseudonymizer{key: []byte("secret")}
+	w := newWebhook(WebhookConfig{URL: srv.URL, Timeout: time.Second}, p, zap.NewNop())
+	w.Post(context.Background(), CollectionResult{
+		Box:          "home",
+		Collector:    "devices",
+		Data:         []fritzbox.Device{{Name: "Living room"}},
+		DeviceErrors: map[string]string{"Living room": "timeout"},
+	})
+
+	if payload.Box != p.label("box", "home") {
+		t.Errorf("box is %q", payload.Box)
+	}
+	if devices := *payload.Data.(*[]fritzbox.Device); len(devices) != 1 || devices[0].Name != p.label("device_name", "Living room") {
+		t.Errorf("devices are %+v", devices)
+	}
+	if _, ok := payload.DeviceErrors[p.label("device_name", "Living room")]; !ok {
+		t.Errorf("device errors are %v", payload.DeviceErrors)
+	}
+}
+
+func TestExpvarsAreAnonymized(t *testing.T) {
+	conf := DefaultConfig()
+	conf.Metrics.Anonymize = true
+	conf.Metrics.AnonymizeKey = "secret"
+
+	s := newTestServer(conf)
+	s.status.record("devices", CollectionResult{Data: []fritzbox.Device{{Name: "Living room"}}})
+
+	status := s.expvars().(map[string]collectorStatus)["devices"]
+	devices := status.Data.([]fritzbox.Device)
+	if devices[0].Name != s.pseudonyms.label("device_name", "Living room") {
+		t.Errorf("device name at /debug/vars is %q", devices[0].Name)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// apiCollectorStatus is the status of a single collector as it is returned by
+// the /api/v1/status endpoint. Other than the status at /debug/vars it omits
+// the raw data and uses human readable durations.
+type apiCollectorStatus struct {
+	Name         string     `json:"name"`
+	Interval     string     `json:"interval"`
+	LastRun      *time.Time `json:"last_run,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastSuccess  *time.Time `json:"last_success,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	Ready        bool       `json:"ready"`
+}
+
+// apiStatus returns the status of all collectors sorted by their name.
+func (s *Server) apiStatus() []apiCollectorStatus {
+	snapshot := s.status.snapshot()
+	result := make([]apiCollectorStatus, 0, len(snapshot))
+	for name, status := range snapshot {
+		c := apiCollectorStatus{
+			Name:        name,
+			Interval:    status.Interval.String(),
+			LastRun:     optionalTime(status.LastRun),
+			LastSuccess: optionalTime(status.LastSuccess),
+			LastError:   status.LastError,
+			NextRun:     optionalTime(status.NextRun),
+			Ready:       s.ready.isDone(name),
+		}
+		if !status.LastRun.IsZero() {
+			c.LastDuration = status.LastDuration.Round(time.Millisecond).String()
+		}
+
+		result = append(result, c)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// serveStatus implements the /api/v1/status endpoint.
+func (s *Server) serveStatus(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, map[string]interface{}{
+		"collectors": s.apiStatus(),
+	})
+}
+
+// writeJSON writes the response of an endpoint of the JSON API.
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	if err != nil {
+		s.Logger.Debug("Failed to write API response", zap.Error(err))
+	}
+}
+
+// serveIndex implements the HTML landing page which shows the status of all
+// collectors.
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := indexTemplate.Execute(w, s.apiStatus())
+	if err != nil {
+		s.Logger.Debug("Failed to render landing page", zap.Error(err))
+	}
+}
+
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"time": func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format("2006-01-02 15:04:05")
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>fritz-mon</title>
+<style>
+body { font-family: sans-serif; }
+td, th { padding: 0.2em 1em; text-align: left; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>fritz-mon</h1>
+<p>
+<a href="/metrics">Metrics</a> |
+<a href="/api/v1/status">Status</a> |
+<a href="/healthz">Health</a> |
+<a href="/ready">Readiness</a>
+</p>
+<table>
+<tr><th>Collector</th><th>Interval</th><th>Last run</th><th>Duration</th><th>Next run</th><th>Last error</th></tr>
+{{- range . }}
+<tr>
+<td>{{ .Name }}</td>
+<td>{{ .Interval }}</td>
+<td>{{ time .LastRun }}</td>
+<td>{{ or .LastDuration "-" }}</td>
+<td>{{ time .NextRun }}</td>
+<td class="error">{{ .LastError }}</td>
+</tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type APIConfig struct {
+	ReadTokens    []string             `yaml:"read_tokens"`    // tokens which may only read, e.g. for a wall-mounted dashboard
+	ControlTokens []string             `yaml:"control_tokens"` // tokens which may also switch devices and change thermostats
+	RateLimits    map[string]RateLimit `yaml:"rate_limits"`    // limits of the control actions by action name, e.g. "switch"
+	Confirm       []string             `yaml:"confirm"`        // control actions which must be confirmed with a second request
+}
+
+func (c APIConfig) Validate() error {
+	seen := map[string]bool{}
+	for _, tokens := range [][]string{c.ReadTokens, c.ControlTokens} {
+		for _, token := range tokens {
+			if token == "" {
+				return fmt.Errorf("api tokens cannot be empty")
+			}
+			if seen[token] {
+				return fmt.Errorf("api tokens must be unique")
+			}
+			seen[token] = true
+		}
+	}
+
+	for action, limit := range c.RateLimits {
+		if !isAPIAction(action) {
+			return fmt.Errorf("api.rate_limits: unknown action %q", action)
+		}
+		if err := limit.Validate(); err != nil {
+			return fmt.Errorf("api.rate_limits.%s: %w", action, err)
+		}
+	}
+
+	for _, action := range c.Confirm {
+		if !isAPIAction(action) {
+			return fmt.Errorf("api.confirm: unknown action %q", action)
+		}
+	}
+
+	return nil
+}
+
+// mustConfirm returns true if the given action must be confirmed.
+func (c APIConfig) mustConfirm(action string) bool {
+	for _, a := range c.Confirm {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// apiScope is what a token allows to do with the JSON API.
+type apiScope int
+
+const (
+	scopeNone    apiScope = iota
+	scopeRead             // read the status and the devices
+	scopeControl          // additionally switch devices and change thermostats
+)
+
+// scope returns the scope of the given token.
+func (c APIConfig) scope(token string) apiScope {
+	if token == "" {
+		return scopeNone
+	}
+
+	for _, t := range c.ControlTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return scopeControl
+		}
+	}
+	for _, t := range c.ReadTokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return scopeRead
+		}
+	}
+
+	return scopeNone
+}
+
+// requireScope wraps a handler of the JSON API so it is only called with a
+// token of the given scope. Tokens are passed as "Authorization: Bearer"
+// header. If no tokens are configured at all, reading is allowed without a
+// token but control endpoints are disabled.
+func (s *Server) requireScope(required apiScope, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conf := s.Config.API
+		if required == scopeRead && len(conf.ReadTokens) == 0 && len(conf.ControlTokens) == 0 {
+			h(w, r)
+			return
+		}
+		if required == scopeControl && len(conf.ControlTokens) == 0 {
+			http.Error(w, "control API is disabled, see api.control_tokens", http.StatusForbidden)
+			return
+		}
+
+		var token string
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		scope := conf.scope(token)
+		switch {
+		case scope == scopeNone:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fritz-mon"`)
+			http.Error(w, "missing or unknown API token", http.StatusUnauthorized)
+		case scope < required:
+			http.Error(w, "API token is read-only", http.StatusForbidden)
+		default:
+			h(w, r)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+// apiActionTimeout limits how long a device action of the JSON API may take.
+const apiActionTimeout = 30 * time.Second
+
+// maxAPIRequestSize limits the size of the request body of the control API.
+const maxAPIRequestSize = 64 << 10
+
+// apiDevice is a smart home device as it is returned by /api/v1/devices.
+// Fields which are not supported by the device are omitted.
+type apiDevice struct {
+	Box         string   `json:"box"`
+	AIN         string   `json:"ain"`
+	Name        string   `json:"name"`
+	Product     string   `json:"product,omitempty"`
+	Present     bool     `json:"present"`
+	SwitchOn    *bool    `json:"switch_on,omitempty"`
+	PowerWatts  *float64 `json:"power_watts,omitempty"`
+	Temperature *float64 `json:"temperature_celsius,omitempty"`
+	Humidity    *float64 `json:"humidity_percent,omitempty"`
+	Target      *float64 `json:"target_temperature_celsius,omitempty"`
+	Alert       *bool    `json:"alert,omitempty"`
+}
+
+func newAPIDevice(box string, d fritzbox.Device) apiDevice {
+	result := apiDevice{
+		Box:     box,
+		AIN:     d.Identifier,
+		Name:    d.Name,
+		Product: d.ProductName,
+		Present: d.Present == 1,
+	}
+
+	if d.IsSwitch() {
+		on := d.Switch.IsPoweredOn()
+		result.SwitchOn = &on
+	}
+	if d.CanMeasurePower() {
+		power := d.Power.GetPower()
+		result.PowerWatts = &power
+	}
+	if d.CanMeasureTemperature() {
+		celsius := d.Temperature.GetCelsius()
+		result.Temperature = &celsius
+	}
+	if humidity, ok := d.Humidity.GetRelativeHumidity(); ok && d.CanMeasureHumidity() {
+		result.Humidity = &humidity
+	}
+	if goal, ok := d.Thermostat.GetGoal(); ok && d.IsThermostat() {
+		result.Target = &goal
+	}
+	if alert, known := d.AlertSensor.IsAlerting(); known && d.IsAlertSensor() {
+		result.Alert = &alert
+	}
+
+	return result
+}
+
+// devices returns the devices of the last successful collection of each box.
+func (s *Server) devices() map[*Box][]fritzbox.Device {
+	result := map[*Box][]fritzbox.Device{}
+	for _, box := range s.Boxes {
+		last, _ := s.status.latest(s.collectorID(box, "devices"))
+		devices, _ := last.Data.([]fritzbox.Device)
+		result[box] = devices
+	}
+
+	return result
+}
+
+// serveDevices implements the /api/v1/devices endpoint.
+func (s *Server) serveDevices(w http.ResponseWriter, _ *http.Request) {
+	devices := s.devices()
+	result := []apiDevice{}
+	for _, box := range s.Boxes {
+		for _, d := range devices[box] {
+			device := newAPIDevice(s.pseudonyms.label("box", box.Name), d)
+			device.Name = s.pseudonyms.label("device_name", device.Name)
+			result = append(result, device)
+		}
+	}
+
+	s.writeJSON(w, map[string]interface{}{
+		"devices": result,
+	})
+}
+
+// serveDeviceAction implements the control endpoints of the JSON API:
+//
+//	POST /api/v1/devices/<ain>/switch       {"state": "on"|"off"|"toggle"}
+//	POST /api/v1/devices/<ain>/temperature  {"celsius": 21.5} or {"off": true}
+func (s *Server) serveDeviceAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/devices/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	box, device := s.findDevice(parts[0])
+	if device == nil {
+		http.Error(w, fmt.Sprintf("unknown device %q", parts[0]), http.StatusNotFound)
+		return
+	}
+
+	action := parts[1]
+	if action != "switch" && action != "temperature" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxAPIRequestSize))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if !s.confirmAction(w, r, action, device.Identifier, body) {
+		return
+	}
+
+	if !s.limitAction(w, action, device.Name) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), apiActionTimeout)
+	defer cancel()
+
+	switch action {
+	case "switch":
+		err = s.switchDevice(ctx, body, box, device)
+	case "temperature":
+		err = s.setTemperature(ctx, body, box, device)
+	}
+
+	if err != nil {
+		box.Logger.Error("Device action of the API failed", zap.String("device", device.Name), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// limitAction checks the rate limit of the action and responds with 429 Too
+// Many Requests if it is exceeded.
+func (s *Server) limitAction(w http.ResponseWriter, action, target string) bool {
+	retryAfter, ok := s.limiter.allow(action, time.Now())
+	if ok {
+		return true
+	}
+
+	s.Logger.Warn("Rejected action of the API due to its rate limit",
+		zap.String("action", action),
+		zap.String("target", target),
+	)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, fmt.Sprintf("rate limit of %s actions exceeded", action), http.StatusTooManyRequests)
+	return false
+}
+
+// confirmAction implements the confirmation flow for the actions listed in
+// api.confirm. If the request does not carry a valid confirmation token, it
+// responds with a new token which must be sent in the X-Confirmation-Token
+// header of an identical request and returns false.
+func (s *Server) confirmAction(w http.ResponseWriter, r *http.Request, action, target string, body []byte) bool {
+	if !s.Config.API.mustConfirm(action) {
+		return true
+	}
+
+	now := time.Now()
+	request := action + "\xff" + target + "\xff" + string(body)
+	if token := r.Header.Get("X-Confirmation-Token"); token != "" {
+		if s.confirm.confirm(token, request, now) {
+			return true
+		}
+
+		http.Error(w, "invalid or expired confirmation token", http.StatusConflict)
+		return false
+	}
+
+	token, expires, err := s.confirm.request(request, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	s.writeJSON(w, map[string]interface{}{
+		"confirmation_token": token,
+		"expires_at":         expires,
+	})
+	return false
+}
+
+// findDevice returns the device with the given AIN from the last collection.
+func (s *Server) findDevice(ain string) (*Box, *fritzbox.Device) {
+	ain = strings.ReplaceAll(ain, " ", "")
+	for box, devices := range s.devices() {
+		for i, d := range devices {
+			if strings.ReplaceAll(d.Identifier, " ", "") == ain {
+				return box, &devices[i]
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *Server) switchDevice(ctx context.Context, body []byte, box *Box, device *fritzbox.Device) error {
+	if !device.IsSwitch() {
+		return fmt.Errorf("device %q is not a switch", device.Name)
+	}
+
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("bad request body: %w", err)
+	}
+
+	switch req.State {
+	case "on":
+		return box.FritzBox.SwitchOn(ctx, device.Identifier)
+	case "off":
+		return box.FritzBox.SwitchOff(ctx, device.Identifier)
+	case "toggle":
+		_, err := box.FritzBox.SwitchToggle(ctx, device.Identifier)
+		return err
+	default:
+		return fmt.Errorf("state must be on, off or toggle but got %q", req.State)
+	}
+}
+
+func (s *Server) setTemperature(ctx context.Context, body []byte, box *Box, device *fritzbox.Device) error {
+	if !device.IsThermostat() {
+		return fmt.Errorf("device %q is not a thermostat", device.Name)
+	}
+
+	var req struct {
+		Celsius float64 `json:"celsius"`
+		Off     bool    `json:"off"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return fmt.Errorf("bad request body: %w", err)
+	}
+
+	if req.Off {
+		return box.FritzBox.SetThermostatOff(ctx, device.Identifier)
+	}
+	return box.FritzBox.SetTargetTemperature(ctx, device.Identifier, req.Celsius)
+}
+
+// serveGroupAction switches all smart plugs of a group concurrently and
+// returns the result of each device:
+//
+//	POST /api/v1/groups/<name>/switch  {"state": "on"|"off"|"toggle"}
+func (s *Server) serveGroupAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rest := strings.TrimPrefix(r.URL.Path, "/api/v1/groups/")
+	if !strings.HasSuffix(rest, "/switch") {
+		http.NotFound(w, r)
+		return
+	}
+	group := strings.TrimSuffix(rest, "/switch")
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxAPIRequestSize))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("bad request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.State != "on" && req.State != "off" && req.State != "toggle" {
+		http.Error(w, fmt.Sprintf("state must be on, off or toggle but got %q", req.State), http.StatusBadRequest)
+		return
+	}
+
+	if !s.confirmAction(w, r, "group_switch", group, body) || !s.limitAction(w, "group_switch", group) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), apiActionTimeout)
+	defer cancel()
+
+	devices := s.devices()
+	results := []switchResult{}
+	for _, box := range s.Boxes {
+		switches, err := groupSwitches(ctx, box.FritzBox, devices[box], s.Config.Groups, group)
+		if err != nil {
+			box.Logger.Error("Failed to resolve group of the API", zap.String("group", group), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		results = append(results, switchAll(ctx, box.FritzBox, switches, req.State)...)
+	}
+
+	if len(results) == 0 {
+		http.Error(w, fmt.Sprintf("unknown group %q or group without smart plugs", group), http.StatusNotFound)
+		return
+	}
+
+	var failed int
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+
+	s.writeJSON(w, map[string]interface{}{
+		"group":     group,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+		"results":   results,
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// apiActions are the names of the actions of the control API which can be
+// rate limited or require a confirmation.
+var apiActions = []string{"switch", "temperature", "group_switch", "guest_wlan"}
+
+// confirmationTimeout is how long a confirmation token of the control API can
+// be used to confirm an action.
+const confirmationTimeout = time.Minute
+
+type RateLimit struct {
+	Count  int           `yaml:"count"`  // maximum number of actions within the period
+	Period time.Duration `yaml:"period"` // e.g. 1m
+}
+
+func (l RateLimit) Validate() error {
+	if l.Count <= 0 {
+		return fmt.Errorf("count must be positive")
+	}
+	if l.Period <= 0 {
+		return fmt.Errorf("period must be positive")
+	}
+	return nil
+}
+
+func isAPIAction(name string) bool {
+	for _, action := range apiActions {
+		if action == name {
+			return true
+		}
+	}
+	return false
+}
+
+// actionLimiter limits how often each action of the control API can be
+// executed within a sliding window, e.g. to stop a broken automation which
+// toggles a switch in a loop. It is safe for concurrent use.
+type actionLimiter struct {
+	limits map[string]RateLimit
+
+	mu      sync.Mutex
+	history map[string][]time.Time // times of the executed actions within the period
+}
+
+func newActionLimiter(limits map[string]RateLimit) *actionLimiter {
+	return &actionLimiter{limits: limits, history: map[string][]time.Time{}}
+}
+
+// allow records an execution of the action if it is within its rate limit.
+// Otherwise it returns false and how long to wait until the next execution is
+// allowed.
+func (l *actionLimiter) allow(action string, now time.Time) (time.Duration, bool) {
+	limit, ok := l.limits[action]
+	if !ok {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	history := l.history[action]
+	for len(history) > 0 && now.Sub(history[0]) >= limit.Period {
+		history = history[1:]
+	}
+
+	if len(history) >= limit.Count {
+		l.history[action] = history
+		return history[0].Add(limit.Period).Sub(now), false
+	}
+
+	l.history[action] = append(history, now)
+	return 0, true
+}
+
+// confirmations implements the confirmation flow of the control API: the
+// first request of an action which must be confirmed only returns a token
+// and the action is executed when the same request is sent again together with
+// this token. It is safe for concurrent use.
+type confirmations struct {
+	mu      sync.Mutex
+	pending map[string]pendingConfirmation // by token
+}
+
+type pendingConfirmation struct {
+	request string // identifies the action, its device and arguments
+	expires time.Time
+}
+
+func newConfirmations() *confirmations {
+	return &confirmations{pending: map[string]pendingConfirmation{}}
+}
+
+// request creates a new token which confirms the given request.
+func (c *confirmations) request(request string, now time.Time) (string, time.Time, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create confirmation token: %w", err)
+	}
+
+	token := hex.EncodeToString(b)
+	expires := now.Add(confirmationTimeout)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for t, p := range c.pending {
+		if now.After(p.expires) {
+			delete(c.pending, t)
+		}
+	}
+	c.pending[token] = pendingConfirmation{request: request, expires: expires}
+
+	return token, expires, nil
+}
+
+// confirm returns true if the token was issued for the given request and did
+// not expire yet. Each token can only be used once.
+func (c *confirmations) confirm(token, request string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.pending[token]
+	if !ok || p.request != request || now.After(p.expires) {
+		return false
+	}
+
+	delete(c.pending, token)
+	return true
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// logStartupSummary logs the effective configuration of all collectors, sinks
+// and the JSON API once at startup, so the configuration can be checked (e.g.
+// in a support request) without reading the YAML file. Secrets such as
+// passwords, tokens and the HomeKit PIN are never logged.
+func (s *Server) logStartupSummary() {
+	conf := s.Config
+	for _, box := range s.Boxes {
+		var collectors []string
+		for _, c := range s.boxCollectors(box) {
+			if c.interval <= 0 {
+				continue
+			}
+
+			entry := c.name + "=" + c.interval.String()
+			if expr, ok := conf.Schedules[c.name]; ok {
+				entry += " (" + expr + ")"
+			}
+			if s.collectsOnScrape(c.name) {
+				entry += " (on scrape)"
+			}
+			collectors = append(collectors, entry)
+		}
+
+		box.Logger.Info("Monitoring FRITZ!Box",
+			zap.String("fritzbox", redactURL(box.Config.BaseURL)),
+			zap.String("username", box.Config.Username),
+			zap.String("tls", tlsMode(box.Config)),
+			zap.String("mode", box.Config.Mode),
+			zap.Strings("collectors", collectors),
+		)
+	}
+
+	var sinks []string
+	if conf.Pushgateway.Enabled() {
+		sinks = append(sinks, "pushgateway "+redactURL(conf.Pushgateway.URL))
+	}
+	if conf.RemoteWrite.Enabled() {
+		sink := "remote_write " + redactURL(conf.RemoteWrite.URL)
+		if conf.RemoteWrite.Username != "" {
+			sink += " (basic auth)"
+		}
+		sinks = append(sinks, sink)
+	}
+	if conf.Webhook.Enabled() {
+		sink := "webhook " + redactURL(conf.Webhook.URL)
+		if conf.Webhook.Secret != "" {
+			sink += " (signed)"
+		}
+		sinks = append(sinks, sink)
+	}
+	if conf.HomeKit.Enabled() {
+		sinks = append(sinks, "homekit "+conf.HomeKit.Name)
+	}
+
+	s.Logger.Info("Effective configuration",
+		zap.String("metrics_profile", string(conf.Metrics.Profile)),
+		zap.Strings("device_labels", conf.Metrics.DeviceLabels),
+		zap.Bool("anonymize", conf.Metrics.Anonymize),
+		zap.Strings("endpoints", conf.Endpoints),
+		zap.Strings("sinks", sinks),
+		zap.String("api_auth", apiAuthMode(conf.API)),
+		zap.Strings("api_confirm", conf.API.Confirm),
+		zap.Bool("align_collections", conf.AlignCollections),
+		zap.Duration("collection_jitter", conf.CollectionJitter),
+		zap.Duration("startup_delay", conf.StartupDelay),
+	)
+}
+
+// redactURL removes the password and the query, which may contain a token,
+// from the given URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid URL>"
+	}
+
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		}
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "xxxxx"
+	}
+
+	return u.String()
+}
+
+// tlsMode describes how the certificate of the FRITZ!Box is verified.
+func tlsMode(conf FritzBoxConfig) string {
+	switch {
+	case !strings.HasPrefix(conf.BaseURL, "https://"):
+		return "none"
+	case conf.InsecureSkipVerify:
+		return "insecure"
+	case conf.TLSFingerprint != "":
+		return "fingerprint"
+	case conf.CAFile != "":
+		return "ca_file"
+	default:
+		return "system"
+	}
+}
+
+// apiAuthMode describes which endpoints of the JSON API require a token.
+func apiAuthMode(conf APIConfig) string {
+	switch {
+	case len(conf.ReadTokens) == 0 && len(conf.ControlTokens) == 0:
+		return "open (read only, control disabled)"
+	case len(conf.ControlTokens) == 0:
+		return "tokens (read only, control disabled)"
+	default:
+		return "tokens"
+	}
+}
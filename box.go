@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/fgrosse/fritz-mon/tr064"
+	"go.uber.org/zap"
+)
+
+// Box contains the clients and metrics of a single monitored FRITZ!Box.
+type Box struct {
+	Name     string // used as value of the "box" label of all metrics
+	Config   FritzBoxConfig
+	Logger   *zap.Logger
+	Metrics  *Metrics
+	FritzBox *fritzbox.Client
+	TR064    *tr064.Client
+
+	availability availability     // detects reboots of the FRITZ!Box
+	conflicts    accountConflicts // detects other clients which use the same account
+}
+
+func NewBox(conf Config, boxConf FritzBoxConfig, logger *zap.Logger) (*Box, error) {
+	name := boxConf.DisplayName()
+	logger = logger.With(zap.String("box", name))
+
+	client, err := fritzbox.New(boxConf.BaseURL, boxConf.Username, boxConf.Password, logger)
+	if err != nil {
+		return nil, fmt.Errorf("bad FRITZ!Box configuration")
+	}
+
+	tr064Client, err := tr064.New(boxConf.TR064BaseURL(), boxConf.Username, boxConf.Password, logger)
+	if err != nil {
+		return nil, fmt.Errorf("bad FRITZ!Box TR-064 configuration")
+	}
+
+	httpClient, err := newHTTPClient(boxConf)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SetHTTPClient(httpClient)
+	tr064Client.SetHTTPClient(httpClient)
+	client.Location = conf.Location()
+	tr064Client.Location = conf.Location()
+
+	metrics := NewMetrics(logger, conf.Metrics.DeviceLabels)
+	metrics.WAN.LogIPChanges = conf.LogIPChanges
+	metrics.Events.LogEvents = conf.LogEvents
+	metrics.Devices.StateFile = conf.StateFile
+	metrics.Devices.HeatingBaseTemperature = conf.HeatingBaseTemperature
+	metrics.Devices.ThermostatTolerance = conf.ThermostatTolerance
+	metrics.Devices.Standby.Rules = conf.StandbyKiller
+	metrics.Devices.Standby.Location = conf.Location()
+	metrics.Devices.FullLogInterval = conf.DebugFullLogInterval
+	metrics.Devices.Pipeline.Pool = devicePool{Workers: conf.DeviceWorkers, Timeout: conf.DeviceRequestTimeout}
+	metrics.Devices.StatsInterval = conf.DeviceStatsInterval
+	metrics.Devices.ExpireAfter = conf.ExpireDevicesAfter
+	metrics.Devices.DefaultName = conf.Metrics.DefaultDeviceName
+	if conf.StateFile != "" && len(conf.Boxes()) > 1 {
+		metrics.Devices.StateFile = boxFile(conf.StateFile, name)
+	}
+	if conf.ResolveHostNames || len(conf.HostNames) > 0 {
+		names := newHostNames(logger, conf.ResolveHostNames, conf.HostNames)
+		metrics.WLAN.Names = names
+		metrics.Hosts.Names = names
+	}
+
+	metrics.Hosts.AlertNewDevices = conf.AlertNewDevices
+	metrics.GuestWLANWatchdog.Config = conf.GuestWLANWatchdog
+	metrics.Hosts.KnownHostsFile = conf.KnownHostsFile
+	if conf.KnownHostsFile != "" && len(conf.Boxes()) > 1 {
+		metrics.Hosts.KnownHostsFile = boxFile(conf.KnownHostsFile, name)
+	}
+
+	if metrics.Devices.StateFile != "" {
+		err = metrics.Devices.RestoreState(metrics.Devices.StateFile)
+		if err != nil {
+			logger.Warn("Failed to restore device readings", zap.Error(err))
+		}
+	}
+
+	return &Box{
+		Name:     name,
+		Config:   boxConf,
+		Logger:   logger,
+		Metrics:  metrics,
+		FritzBox: client,
+		TR064:    tr064Client,
+	}, nil
+}
+
+// reloadPassword reads the password_file of the FRITZ!Box again, e.g. after
+// the secret was rotated. It does nothing if the password is configured
+// directly.
+func (b *Box) reloadPassword() error {
+	if b.Config.PasswordFile == "" {
+		return nil
+	}
+
+	conf := b.Config
+	conf.Password = ""
+	if err := conf.readPasswordFile("fritzbox"); err != nil {
+		return err
+	}
+
+	b.FritzBox.SetPassword(conf.Password)
+	b.TR064.SetPassword(conf.Password)
+	b.Logger.Info("Reloaded FRITZ!Box password", zap.String("password_file", conf.PasswordFile))
+	return nil
+}
+
+// portDiscoveryTimeout limits how long we wait for the FRITZ!Box when
+// discovering its TR-064 HTTPS port at startup.
+const portDiscoveryTimeout = 10 * time.Second
+
+// discoverTR064Port looks up the port at which the FRITZ!Box serves TR-064 via
+// HTTPS unless the TR-064 URL is configured explicitly. If the FRITZ!Box does
+// not tell us, we keep using the default port.
+func (b *Box) discoverTR064Port() {
+	if b.Config.TR064URL != "" || b.TR064.BaseURL.Scheme != "https" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), portDiscoveryTimeout)
+	defer cancel()
+
+	err := b.TR064.DiscoverTLSPort(ctx)
+	if err != nil {
+		b.Logger.Warn("Failed to discover TR-064 HTTPS port of FRITZ!Box, using default port",
+			zap.String("port", tr064.DefaultTLSPort),
+			zap.Error(err),
+		)
+		return
+	}
+
+	b.Logger.Debug("Discovered TR-064 HTTPS port of FRITZ!Box", zap.String("url", b.TR064.BaseURL.String()))
+}
+
+// boxFile returns the path of a state file of a single FRITZ!Box if multiple
+// boxes are monitored (e.g. "state.json" becomes "state.fritz.box.json").
+func boxFile(path, box string) string {
+	ext := filepath.Ext(path)
+	box = strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(box)
+	return strings.TrimSuffix(path, ext) + "." + box + ext
+}
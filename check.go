@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+// Exit codes of the "check" command.
+const (
+	checkOK            = 0
+	checkInvalidConfig = 1
+	checkBoxFailed     = 3 // 2 is used for usage errors like by all other commands
+)
+
+// runCheck implements the "check" command which validates the configuration
+// and verifies that fritz-mon can log into every configured FRITZ!Box and
+// fetch its devices and network statistics. It is meant to be used before
+// starting fritz-mon, e.g. via ExecStartPre in the systemd unit.
+func runCheck(args []string, configPath string) int {
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "Usage: fritz-mon [-config <path>] check")
+		return 2
+	}
+
+	conf, err := LoadConfiguration(configPath, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Configuration %s: %v\n", configPath, err)
+		return checkInvalidConfig
+	}
+	fmt.Printf("Configuration %s is valid\n", configPath)
+
+	code := checkOK
+	for _, box := range conf.Boxes() {
+		if !checkBox(box) {
+			code = checkBoxFailed
+		}
+	}
+
+	return code
+}
+
+// checkBox logs into the FRITZ!Box, fetches its devices and network
+// statistics once and prints a summary. It returns false if any step failed.
+func checkBox(conf FritzBoxConfig) bool {
+	name := conf.Name
+	if name == "" {
+		name = conf.BaseURL
+	}
+
+	client, err := newStatsClient(conf)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to create FRITZ!Box client: %v\n", name, err)
+		return false
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	start := time.Now()
+	devices, err := client.Devices(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to fetch devices: %v\n", name, err)
+		return false
+	}
+
+	var present int
+	for _, d := range devices {
+		if d.Present == 1 {
+			present++
+		}
+	}
+	fmt.Printf("%s: logged in as %q, found %d smart home devices (%d connected) in %s\n",
+		name, conf.Username, len(devices), present, time.Since(start).Round(time.Millisecond))
+
+	// An IP client has no internet connection of its own so there are no
+	// network statistics to check.
+	if conf.Mode == ModeIPClient {
+		return true
+	}
+
+	stats, err := client.NetworkStats(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: failed to fetch network statistics: %v\n", name, err)
+		return false
+	}
+
+	fmt.Printf("%s: current internet traffic is %s down and %s up\n",
+		name, formatBitRate(stats.DownstreamInternet[0]*8), formatBitRate(totalUpstream(stats)*8))
+
+	return true
+}
+
+// totalUpstream returns the current upstream of all priorities in bytes per
+// second.
+func totalUpstream(stats *fritzbox.TrafficMonitoringData) float64 {
+	return stats.UpstreamRealtime[0] +
+		stats.UpstreamHighPriority[0] +
+		stats.UpstreamDefaultPriority[0] +
+		stats.UpstreamLowPriority[0]
+}
+
+// formatBitRate formats bits per second in a human readable unit.
+func formatBitRate(bps float64) string {
+	switch {
+	case bps >= 1e6:
+		return fmt.Sprintf("%.1f Mbit/s", bps/1e6)
+	case bps >= 1e3:
+		return fmt.Sprintf("%.1f kbit/s", bps/1e3)
+	default:
+		return fmt.Sprintf("%.0f bit/s", bps)
+	}
+}
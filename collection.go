@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// CollectionResult is the outcome of a single collection. The status API, the
+// sinks and HomeKit all consume it, so none of them has to fetch from the
+// FRITZ!Box again.
+type CollectionResult struct {
+	Box          string            `json:"box"`
+	Collector    string            `json:"collector"`
+	Start        time.Time         `json:"start"`
+	Duration     time.Duration     `json:"duration"`
+	Data         interface{}       `json:"data,omitempty"`          // raw data fetched from the FRITZ!Box
+	DeviceErrors map[string]string `json:"device_errors,omitempty"` // failed requests for individual devices by device name
+	Err          error             `json:"-"`                       // nil if the collection succeeded
+}
+
+// DeviceErrors is returned by a collector whose collection succeeded although
+// requests for individual devices failed, e.g. switching off a device in
+// standby. The errors are by device name.
+type DeviceErrors map[string]error
+
+func (e DeviceErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%q: %v", name, e[name])
+	}
+
+	return "requests for devices failed: " + strings.Join(msgs, "; ")
+}
+
+// merge returns the errors of both e and other. Errors of the same device are
+// combined.
+func (e DeviceErrors) merge(other DeviceErrors) DeviceErrors {
+	if len(other) == 0 {
+		return e
+	}
+
+	result := make(DeviceErrors, len(e)+len(other))
+	for name, err := range e {
+		result[name] = err
+	}
+	for name, err := range other {
+		result[name] = multierr.Append(result[name], err)
+	}
+	return result
+}
+
+// strings returns the error messages by device name, e.g. for JSON.
+func (e DeviceErrors) strings() map[string]string {
+	result := make(map[string]string, len(e))
+	for name, err := range e {
+		result[name] = err.Error()
+	}
+	return result
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"go.uber.org/multierr"
@@ -11,14 +15,188 @@ import (
 )
 
 type Config struct {
-	ListenAddr                string        `yaml:"listen_addr"`                 // base URL at which to expose Prometheus metrics
-	DeviceMonitoringInterval  time.Duration `yaml:"device_monitoring_interval"`  // how often to scrape device metrics from the FRITZ!Box API
-	NetworkMonitoringInterval time.Duration `yaml:"network_monitoring_interval"` // how often to scrape network metrics from the FRITZ!Box API
-	FritzBox                  struct {
-		Username string `yaml:"username"`
-		Password string `yaml:"password"`
-		BaseURL  string `yaml:"base_url"`
-	} `yaml:"fritzbox"`
+	ListenAddr                 string        `yaml:"listen_addr"`                   // base URL at which to expose Prometheus metrics
+	DeviceMonitoringInterval   time.Duration `yaml:"device_monitoring_interval"`    // how often to scrape device metrics from the FRITZ!Box API
+	NetworkMonitoringInterval  time.Duration `yaml:"network_monitoring_interval"`   // how often to scrape network metrics from the FRITZ!Box API
+	SystemMonitoringInterval   time.Duration `yaml:"system_monitoring_interval"`    // how often to scrape system metrics from the FRITZ!Box TR-064 API
+	WANMonitoringInterval      time.Duration `yaml:"wan_monitoring_interval"`       // how often to scrape WAN metrics from the FRITZ!Box TR-064 API
+	EventLogMonitoringInterval time.Duration `yaml:"event_log_monitoring_interval"` // how often to read the FRITZ!Box event log
+	WLANMonitoringInterval     time.Duration `yaml:"wlan_monitoring_interval"`      // how often to scrape WLAN metrics from the FRITZ!Box TR-064 API
+	LANMonitoringInterval      time.Duration `yaml:"lan_monitoring_interval"`       // how often to scrape LAN metrics from the FRITZ!Box TR-064 API
+	MobileMonitoringInterval   time.Duration `yaml:"mobile_monitoring_interval"`    // how often to scrape mobile network metrics (LTE models only, zero disables)
+	FiberMonitoringInterval    time.Duration `yaml:"fiber_monitoring_interval"`     // how often to scrape fiber metrics (Fiber models only, zero disables)
+	DOCSISMonitoringInterval   time.Duration `yaml:"docsis_monitoring_interval"`    // how often to scrape DOCSIS channel metrics (Cable models only, zero disables)
+	HostMonitoringInterval     time.Duration `yaml:"host_monitoring_interval"`      // how often to check the FRITZ!Box for new devices in the network
+	MeshMonitoringInterval     time.Duration `yaml:"mesh_monitoring_interval"`      // how often to scrape the uplinks of mesh repeaters from the FRITZ!Box TR-064 API (zero disables)
+	LogIPChanges               bool          `yaml:"log_ip_changes"`                // log old and new address when the external IP changes
+	LogEvents                  bool          `yaml:"log_events"`                    // log each new entry of the FRITZ!Box event log
+	StateFile                  string        `yaml:"state_file,omitempty"`          // optional file to persist the latest device readings across restarts
+	KnownHostsFile             string        `yaml:"known_hosts_file,omitempty"`    // optional file to persist the MAC addresses of all known devices across restarts
+	AlertNewDevices            bool          `yaml:"alert_new_devices"`             // log a warning when a new device is detected in the network
+	HeatingBaseTemperature     float64       `yaml:"heating_base_temperature"`      // base temperature in °C for the heating degree days of thermostats
+	ThermostatTolerance        float64       `yaml:"thermostat_tolerance"`          // deviation from the target temperature in °C which is still considered on schedule
+	LogDedupWindow             time.Duration `yaml:"log_dedup_window"`              // summarize repeated identical errors at most once per window, zero disables
+	DebugFullLogInterval       time.Duration `yaml:"debug_full_log_interval"`       // only debug log changed device readings and all readings once per interval, zero logs all readings every time
+	DeviceWorkers              int           `yaml:"device_workers"`                // maximum number of concurrent follow-up requests for individual smart home devices
+	DeviceRequestTimeout       time.Duration `yaml:"device_request_timeout"`        // timeout of each follow-up request for an individual smart home device
+	DeviceStatsInterval        time.Duration `yaml:"device_stats_interval"`         // how often to fetch the energy and temperature history of each device, zero disables
+	ExpireDevicesAfter         int           `yaml:"expire_devices_after"`          // remove the metrics of devices which are missing from this many device collections in a row, zero keeps them
+	ResolveHostNames           bool          `yaml:"resolve_host_names"`            // label per-client metrics with the host names from the FRITZ!Box instead of only the MAC address
+	CollectOnScrape            bool          `yaml:"collect_on_scrape"`             // fetch device and network metrics when Prometheus scrapes instead of periodically
+	ScrapeCacheTTL             time.Duration `yaml:"scrape_cache_ttl"`              // reuse data fetched on scrape for this long
+	SessionKeepAlive           time.Duration `yaml:"session_keep_alive"`            // refresh the FRITZ!Box session when it was idle for this long, zero disables
+	StartupDelay               time.Duration `yaml:"startup_delay"`                 // delay of the first collection of all collectors, e.g. while the FRITZ!Box is still booting
+	Timezone                   string        `yaml:"timezone,omitempty"`            // IANA time zone (e.g. Europe/Berlin) of schedules, time windows and the event log, defaults to the system time zone
+	AlignCollections           bool          `yaml:"align_collections"`             // collect at multiples of the interval, e.g. at :00 and :05 for 5m
+	CollectionJitter           time.Duration `yaml:"collection_jitter"`             // maximum random delay of each collection, zero disables
+
+	Collectors   map[string]CollectorConfig `yaml:"collectors,omitempty"`    // whether each collector is enabled and its interval and timeout by collector name
+	Schedules    map[string]string          `yaml:"schedules,omitempty"`     // cron expressions by collector name which replace the interval for when to collect
+	StartOffsets map[string]time.Duration   `yaml:"start_offsets,omitempty"` // delay of the first collection by collector name so the FRITZ!Box is not hit by all collectors at once
+	DeferFirst   map[string]bool            `yaml:"defer_first,omitempty"`   // collectors which wait a full interval before their first collection
+	Endpoints    []string                   `yaml:"endpoints,omitempty"`     // collectors whose metrics are exposed at /metrics/<name> instead of /metrics
+	Pushgateway  PushgatewayConfig          `yaml:"pushgateway,omitempty"`   // optionally push metrics after each collection
+	RemoteWrite  RemoteWriteConfig          `yaml:"remote_write,omitempty"`  // optionally send metrics via Prometheus remote write after each collection
+	Webhook      WebhookConfig              `yaml:"webhook,omitempty"`       // optionally post the raw data of each collection as JSON
+	HomeKit      HomeKitConfig              `yaml:"homekit,omitempty"`       // optionally expose the smart home devices as HomeKit bridge
+	API          APIConfig                  `yaml:"api,omitempty"`           // tokens of the JSON API
+	Log          LogConfig                  `yaml:"log,omitempty"`           // format, level and output of the logs
+	Metrics      MetricsConfig              `yaml:"metrics,omitempty"`       // labels of the exported metrics
+	HostNames    map[string]string          `yaml:"host_names,omitempty"`    // static names by MAC address which take precedence over the names of the FRITZ!Box
+	Groups       map[string][]string        `yaml:"groups,omitempty"`        // device names or AINs by group name, take precedence over the device groups of the FRITZ!Box
+
+	GuestWLANWatchdog GuestWLANWatchdogConfig `yaml:"guest_wlan_watchdog,omitempty"` // optionally alert or disable the guest WLAN if it is left enabled
+	StandbyKiller     []StandbyRule           `yaml:"standby_killer,omitempty"`      // switch off smart plugs whose device stays in standby
+
+	FritzBox   FritzBoxConfig   `yaml:"fritzbox"`
+	FritzBoxes []FritzBoxConfig `yaml:"fritzboxes,omitempty"` // monitor multiple FRITZ!Boxes instead of the single fritzbox
+}
+
+// Location returns the configured time zone or the system time zone if none is
+// configured.
+func (c Config) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local // already rejected by Validate
+	}
+	return loc
+}
+
+// Boxes returns the configuration of all FRITZ!Boxes which should be monitored.
+func (c Config) Boxes() []FritzBoxConfig {
+	if len(c.FritzBoxes) > 0 {
+		return c.FritzBoxes
+	}
+	return []FritzBoxConfig{c.FritzBox}
+}
+
+type FritzBoxConfig struct {
+	Name     string `yaml:"name,omitempty"` // optional name to identify the FRITZ!Box in logs, defaults to the host of the base URL
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	BaseURL  string `yaml:"base_url"` // e.g. http://fritz.box or https://example.myfritz.net:44312
+
+	PasswordFile string `yaml:"password_file,omitempty"` // optional file to read the password from instead of password (e.g. a Docker or Kubernetes secret), read again on SIGHUP
+
+	TR064URL           string `yaml:"tr064_url,omitempty"`            // optional URL of the TR-064 interface, defaults to the base URL with port 49000
+	TLSFingerprint     string `yaml:"tls_fingerprint,omitempty"`      // optional SHA-256 fingerprint to pin the self-signed certificate of the FRITZ!Box
+	CAFile             string `yaml:"ca_file,omitempty"`              // optional PEM encoded CA certificate to verify the certificate of the FRITZ!Box
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"` // do not verify the TLS certificate of the FRITZ!Box at all
+	Mode               string `yaml:"mode,omitempty"`                 // "router", "ip_client" or "auto" (default) to detect if the FRITZ!Box has its own internet connection
+
+	Intervals map[string]time.Duration `yaml:"intervals,omitempty"` // optional monitoring intervals by collector name which override the global intervals
+}
+
+// CollectorConfig contains the settings of a single collector. All fields are
+// optional and override the global settings of the collector.
+type CollectorConfig struct {
+	Enabled  *bool         `yaml:"enabled,omitempty"`  // nil keeps the collector enabled if it has an interval
+	Interval time.Duration `yaml:"interval,omitempty"` // overrides the global interval of the collector, e.g. wlan_monitoring_interval
+	Timeout  time.Duration `yaml:"timeout,omitempty"`  // deadline of each collection, defaults to the interval
+}
+
+// collectorIntervals returns the global interval of each collector by name.
+// Collectors with an interval of zero are disabled.
+func (c Config) collectorIntervals() map[string]time.Duration {
+	return map[string]time.Duration{
+		"devices":             c.DeviceMonitoringInterval,
+		"network":             c.NetworkMonitoringInterval,
+		"system":              c.SystemMonitoringInterval,
+		"wan":                 c.WANMonitoringInterval,
+		"event_log":           c.EventLogMonitoringInterval,
+		"wlan":                c.WLANMonitoringInterval,
+		"lan":                 c.LANMonitoringInterval,
+		"mobile":              c.MobileMonitoringInterval,
+		"fiber":               c.FiberMonitoringInterval,
+		"docsis":              c.DOCSISMonitoringInterval,
+		"mesh":                c.MeshMonitoringInterval,
+		"hosts":               c.HostMonitoringInterval,
+		"guest_wlan_watchdog": c.GuestWLANWatchdog.interval(),
+	}
+}
+
+// collectorInterval returns the interval of the collector with the given name
+// from the collectors section or its global interval. It returns zero if the
+// collector is disabled.
+func (c Config) collectorInterval(name string) time.Duration {
+	cc := c.Collectors[name]
+	switch {
+	case cc.Enabled != nil && !*cc.Enabled:
+		return 0
+	case cc.Interval > 0:
+		return cc.Interval
+	default:
+		return c.collectorIntervals()[name]
+	}
+}
+
+// boxInterval returns the interval of the collector with the given name for a
+// single FRITZ!Box. The intervals of the box take precedence over the global
+// interval but do not enable a collector which is disabled via
+// collectors.<name>.enabled.
+func (c Config) boxInterval(box FritzBoxConfig, name string) time.Duration {
+	if cc := c.Collectors[name]; cc.Enabled != nil && !*cc.Enabled {
+		return 0
+	}
+	if interval, ok := box.Intervals[name]; ok {
+		return interval
+	}
+	return c.collectorInterval(name)
+}
+
+// Operation modes of a FRITZ!Box.
+const (
+	ModeAuto     = "auto"
+	ModeRouter   = "router"
+	ModeIPClient = "ip_client"
+)
+
+// TR064BaseURL returns the URL at which the TR-064 interface of the FRITZ!Box
+// can be reached.
+func (c FritzBoxConfig) TR064BaseURL() string {
+	if c.TR064URL != "" {
+		return c.TR064URL
+	}
+	return c.BaseURL
+}
+
+// DisplayName returns the configured name of the FRITZ!Box or the host of its
+// base URL if no name is configured.
+func (c FritzBoxConfig) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	u, err := url.Parse(c.BaseURL)
+	if err != nil || u.Host == "" {
+		return c.BaseURL
+	}
+
+	return u.Host
 }
 
 func LoadConfiguration(path string, logger *zap.Logger) (Config, error) {
@@ -39,6 +217,11 @@ func LoadConfiguration(path string, logger *zap.Logger) (Config, error) {
 		return conf, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	err = conf.readPasswordFiles()
+	if err != nil {
+		return conf, err
+	}
+
 	err = conf.Validate()
 	if err != nil {
 		return conf, fmt.Errorf("invalid configuration: %w", err)
@@ -52,7 +235,43 @@ func DefaultConfig() Config {
 	conf.ListenAddr = "0:0:0:0:3000"
 	conf.DeviceMonitoringInterval = 5 * time.Minute
 	conf.NetworkMonitoringInterval = 10 * time.Second
+	conf.SystemMonitoringInterval = time.Minute
+	conf.WANMonitoringInterval = 30 * time.Second
+	conf.EventLogMonitoringInterval = time.Minute
+	conf.WLANMonitoringInterval = 30 * time.Second
+	conf.LANMonitoringInterval = 30 * time.Second
+	conf.HostMonitoringInterval = time.Minute
+	conf.MeshMonitoringInterval = time.Minute
+	conf.HeatingBaseTemperature = defaultHeatingBaseTemperature
+	conf.ThermostatTolerance = defaultThermostatTolerance
 	conf.FritzBox.BaseURL = "http://fritz.box"
+	conf.Pushgateway.Job = "fritz-mon"
+	conf.RemoteWrite.Timeout = 10 * time.Second
+	conf.Webhook.Timeout = 10 * time.Second
+	conf.HomeKit.Name = "fritz-mon"
+	conf.HomeKit.StorageDir = "homekit"
+	conf.GuestWLANWatchdog.Action = WatchdogActionAlert
+	conf.GuestWLANWatchdog.Interval = time.Minute
+	conf.LogDedupWindow = 10 * time.Minute
+	conf.DeviceWorkers = defaultDeviceWorkers
+	conf.DeviceRequestTimeout = defaultDeviceRequestTimeout
+	conf.DeviceStatsInterval = 15 * time.Minute
+	conf.ExpireDevicesAfter = defaultExpireDevicesAfter
+	conf.Metrics.DeviceLabels = defaultDeviceLabels
+	conf.Metrics.Profile = profileFull
+	conf.Metrics.DefaultDeviceName = defaultDeviceNameTemplate
+	conf.API.RateLimits = map[string]RateLimit{
+		"switch":       {Count: 30, Period: time.Minute},
+		"temperature":  {Count: 30, Period: time.Minute},
+		"group_switch": {Count: 10, Period: time.Minute},
+		"guest_wlan":   {Count: 10, Period: time.Minute},
+	}
+	conf.Log.Format = "console"
+	conf.Log.Level = "info"
+	conf.Log.MaxSizeMB = 100
+	conf.Log.MaxBackups = 3
+	conf.ScrapeCacheTTL = 5 * time.Second
+	conf.ResolveHostNames = true
 	return conf
 }
 
@@ -62,21 +281,246 @@ func (c Config) Validate() error {
 	if c.ListenAddr == "" {
 		err = multierr.Append(err, fmt.Errorf("missing listen_addr"))
 	}
-	if c.FritzBox.Username == "" {
-		err = multierr.Append(err, fmt.Errorf("missing fritzbox.username"))
-	}
-	if c.FritzBox.Username == "" {
-		err = multierr.Append(err, fmt.Errorf("missing fritzbox.password"))
-	}
 	if c.DeviceMonitoringInterval == 0 {
 		err = multierr.Append(err, fmt.Errorf("device_monitoring_interval cannot be zero"))
 	}
 	if c.NetworkMonitoringInterval == 0 {
 		err = multierr.Append(err, fmt.Errorf("network_monitoring_interval cannot be zero"))
 	}
-	if c.FritzBox.BaseURL == "" {
-		err = multierr.Append(err, fmt.Errorf("FRITZ!Box base URL cannot be empty"))
+	if c.SystemMonitoringInterval == 0 {
+		err = multierr.Append(err, fmt.Errorf("system_monitoring_interval cannot be zero"))
+	}
+	if c.WANMonitoringInterval == 0 {
+		err = multierr.Append(err, fmt.Errorf("wan_monitoring_interval cannot be zero"))
+	}
+	if c.EventLogMonitoringInterval == 0 {
+		err = multierr.Append(err, fmt.Errorf("event_log_monitoring_interval cannot be zero"))
+	}
+	if c.WLANMonitoringInterval == 0 {
+		err = multierr.Append(err, fmt.Errorf("wlan_monitoring_interval cannot be zero"))
+	}
+	if c.LANMonitoringInterval == 0 {
+		err = multierr.Append(err, fmt.Errorf("lan_monitoring_interval cannot be zero"))
+	}
+	if c.HostMonitoringInterval == 0 {
+		err = multierr.Append(err, fmt.Errorf("host_monitoring_interval cannot be zero"))
+	}
+	if c.MobileMonitoringInterval < 0 {
+		err = multierr.Append(err, fmt.Errorf("mobile_monitoring_interval cannot be negative"))
+	}
+	if c.FiberMonitoringInterval < 0 {
+		err = multierr.Append(err, fmt.Errorf("fiber_monitoring_interval cannot be negative"))
+	}
+	if c.DOCSISMonitoringInterval < 0 {
+		err = multierr.Append(err, fmt.Errorf("docsis_monitoring_interval cannot be negative"))
+	}
+	if c.MeshMonitoringInterval < 0 {
+		err = multierr.Append(err, fmt.Errorf("mesh_monitoring_interval cannot be negative"))
+	}
+	for name, offset := range c.StartOffsets {
+		if !isCollectorName(name) {
+			err = multierr.Append(err, fmt.Errorf("start_offsets: unknown collector %q (valid collectors are %s)", name, strings.Join(collectorNames, ", ")))
+		}
+		if offset < 0 {
+			err = multierr.Append(err, fmt.Errorf("start_offsets.%s cannot be negative", name))
+		}
+	}
+	if _, tzErr := time.LoadLocation(c.Timezone); tzErr != nil {
+		err = multierr.Append(err, fmt.Errorf("timezone: %w", tzErr))
+	}
+	if c.CollectionJitter < 0 {
+		err = multierr.Append(err, fmt.Errorf("collection_jitter cannot be negative"))
+	}
+	intervals := c.collectorIntervals()
+	for name, cc := range c.Collectors {
+		if !isCollectorName(name) {
+			err = multierr.Append(err, fmt.Errorf("collectors: unknown collector %q (valid collectors are %s)", name, strings.Join(collectorNames, ", ")))
+			continue
+		}
+		if cc.Interval < 0 {
+			err = multierr.Append(err, fmt.Errorf("collectors.%s.interval cannot be negative", name))
+		}
+		if cc.Timeout < 0 {
+			err = multierr.Append(err, fmt.Errorf("collectors.%s.timeout cannot be negative", name))
+		}
+		if cc.Enabled != nil && *cc.Enabled && cc.Interval == 0 && intervals[name] <= 0 {
+			err = multierr.Append(err, fmt.Errorf("collectors.%s.interval is required to enable the collector since it is disabled by default", name))
+		}
+	}
+	for name, expr := range c.Schedules {
+		if !isCollectorName(name) {
+			err = multierr.Append(err, fmt.Errorf("schedules: unknown collector %q (valid collectors are %s)", name, strings.Join(collectorNames, ", ")))
+		}
+		if _, cronErr := parseCron(expr); cronErr != nil {
+			err = multierr.Append(err, fmt.Errorf("schedules.%s: %w", name, cronErr))
+		}
+	}
+	for name := range c.DeferFirst {
+		if !isCollectorName(name) {
+			err = multierr.Append(err, fmt.Errorf("defer_first: unknown collector %q (valid collectors are %s)", name, strings.Join(collectorNames, ", ")))
+		}
+	}
+	for _, name := range c.Endpoints {
+		if !isCollectorName(name) {
+			err = multierr.Append(err, fmt.Errorf("endpoints: unknown collector %q (valid collectors are %s)", name, strings.Join(collectorNames, ", ")))
+		}
+	}
+	if c.StartupDelay < 0 {
+		err = multierr.Append(err, fmt.Errorf("startup_delay cannot be negative"))
+	}
+	if c.LogDedupWindow < 0 {
+		err = multierr.Append(err, fmt.Errorf("log_dedup_window cannot be negative"))
+	}
+	if c.DebugFullLogInterval < 0 {
+		err = multierr.Append(err, fmt.Errorf("debug_full_log_interval cannot be negative"))
+	}
+	if c.DeviceWorkers < 1 {
+		err = multierr.Append(err, fmt.Errorf("device_workers must be at least 1"))
+	}
+	if c.DeviceRequestTimeout <= 0 {
+		err = multierr.Append(err, fmt.Errorf("device_request_timeout must be positive"))
+	}
+	if c.ExpireDevicesAfter < 0 {
+		err = multierr.Append(err, fmt.Errorf("expire_devices_after cannot be negative"))
+	}
+	if c.DeviceStatsInterval < 0 {
+		err = multierr.Append(err, fmt.Errorf("device_stats_interval cannot be negative"))
+	}
+	for mac, name := range c.HostNames {
+		if _, macErr := net.ParseMAC(mac); macErr != nil {
+			err = multierr.Append(err, fmt.Errorf("host_names: invalid MAC address %q", mac))
+		}
+		if name == "" {
+			err = multierr.Append(err, fmt.Errorf("host_names.%s cannot be empty", mac))
+		}
+	}
+	if c.ThermostatTolerance < 0 {
+		err = multierr.Append(err, fmt.Errorf("thermostat_tolerance cannot be negative"))
+	}
+	if c.ScrapeCacheTTL < 0 {
+		err = multierr.Append(err, fmt.Errorf("scrape_cache_ttl cannot be negative"))
+	}
+	if c.SessionKeepAlive < 0 {
+		err = multierr.Append(err, fmt.Errorf("session_keep_alive cannot be negative"))
+	}
+	if pushErr := c.Pushgateway.Validate(); pushErr != nil {
+		err = multierr.Append(err, pushErr)
+	}
+	if remoteWriteErr := c.RemoteWrite.Validate(); remoteWriteErr != nil {
+		err = multierr.Append(err, remoteWriteErr)
+	}
+	if webhookErr := c.Webhook.Validate(); webhookErr != nil {
+		err = multierr.Append(err, webhookErr)
+	}
+	if metricsErr := c.Metrics.Validate(); metricsErr != nil {
+		err = multierr.Append(err, metricsErr)
+	}
+	if logErr := c.Log.Validate(); logErr != nil {
+		err = multierr.Append(err, logErr)
+	}
+	if apiErr := c.API.Validate(); apiErr != nil {
+		err = multierr.Append(err, apiErr)
+	}
+	if homeKitErr := c.HomeKit.Validate(); homeKitErr != nil {
+		err = multierr.Append(err, homeKitErr)
+	}
+	if watchdogErr := c.GuestWLANWatchdog.Validate(); watchdogErr != nil {
+		err = multierr.Append(err, watchdogErr)
+	}
+	for i, rule := range c.StandbyKiller {
+		err = multierr.Append(err, rule.Validate(fmt.Sprintf("standby_killer[%d]", i)))
+	}
+	if len(c.FritzBoxes) == 0 {
+		err = multierr.Append(err, c.FritzBox.Validate("fritzbox"))
+	}
+
+	names := map[string]bool{}
+	for i, box := range c.FritzBoxes {
+		err = multierr.Append(err, box.Validate(fmt.Sprintf("fritzboxes[%d]", i)))
+		if names[box.DisplayName()] {
+			err = multierr.Append(err, fmt.Errorf("fritzboxes[%d]: duplicate name %q", i, box.DisplayName()))
+		}
+		names[box.DisplayName()] = true
 	}
 
 	return err
 }
+
+// Validate checks the configuration of a single FRITZ!Box. The prefix is used
+// to identify the box in error messages.
+func (c FritzBoxConfig) Validate(prefix string) error {
+	var err error
+
+	if c.Username == "" {
+		err = multierr.Append(err, fmt.Errorf("missing %s.username", prefix))
+	}
+	if c.Password == "" {
+		err = multierr.Append(err, fmt.Errorf("missing %s.password or %s.password_file", prefix, prefix))
+	}
+	if c.TLSFingerprint != "" {
+		if _, fpErr := parseFingerprint(c.TLSFingerprint); fpErr != nil {
+			err = multierr.Append(err, fmt.Errorf("%s.%w", prefix, fpErr))
+		}
+	}
+	if c.BaseURL == "" {
+		err = multierr.Append(err, fmt.Errorf("%s.base_url cannot be empty", prefix))
+	}
+	if c.InsecureSkipVerify && (c.CAFile != "" || c.TLSFingerprint != "") {
+		err = multierr.Append(err, fmt.Errorf("%s.insecure_skip_verify cannot be combined with ca_file or tls_fingerprint", prefix))
+	}
+	switch c.Mode {
+	case "", ModeAuto, ModeRouter, ModeIPClient:
+	default:
+		err = multierr.Append(err, fmt.Errorf("%s.mode must be %q, %q or %q", prefix, ModeAuto, ModeRouter, ModeIPClient))
+	}
+	for name, interval := range c.Intervals {
+		if !isCollectorName(name) {
+			err = multierr.Append(err, fmt.Errorf("%s.intervals: unknown collector %q (valid collectors are %s)", prefix, name, strings.Join(collectorNames, ", ")))
+		}
+		if interval < 0 {
+			err = multierr.Append(err, fmt.Errorf("%s.intervals.%s cannot be negative", prefix, name))
+		}
+	}
+
+	return err
+}
+
+// readPasswordFiles sets the password of all FRITZ!Boxes which are configured
+// with a password_file.
+func (c *Config) readPasswordFiles() error {
+	err := c.FritzBox.readPasswordFile("fritzbox")
+	for i := range c.FritzBoxes {
+		err = multierr.Append(err, c.FritzBoxes[i].readPasswordFile(fmt.Sprintf("fritzboxes[%d]", i)))
+	}
+
+	return err
+}
+
+func (c *FritzBoxConfig) readPasswordFile(prefix string) error {
+	if c.PasswordFile == "" {
+		return nil
+	}
+
+	if c.Password != "" {
+		return fmt.Errorf("%s.password cannot be combined with password_file", prefix)
+	}
+
+	data, err := ioutil.ReadFile(c.PasswordFile)
+	if err != nil {
+		return fmt.Errorf("failed to read %s.password_file: %w", prefix, err)
+	}
+
+	// Secrets are often written with a trailing newline which is not part of
+	// the password.
+	c.Password = strings.TrimRight(string(data), "\r\n")
+	return nil
+}
+
+func isCollectorName(name string) bool {
+	for _, n := range collectorNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
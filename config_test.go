@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigBoxInterval(t *testing.T) {
+	disabled, enabled := false, true
+
+	conf := DefaultConfig()
+	conf.Collectors = map[string]CollectorConfig{
+		"wan":  {Enabled: &disabled},
+		"lan":  {Enabled: &enabled, Interval: 2 * time.Minute},
+		"mesh": {Interval: 5 * time.Minute},
+	}
+	box := FritzBoxConfig{Intervals: map[string]time.Duration{
+		"wan":    10 * time.Second,
+		"lan":    20 * time.Second,
+		"mobile": time.Minute,
+	}}
+
+	cases := []struct {
+		name string
+		want time.Duration
+	}{
+		{"wan", 0},                // disabled for all boxes
+		{"lan", 20 * time.Second}, // box overrides the collectors section
+		{"mobile", time.Minute},   // box enables a collector without global interval
+		{"mesh", 5 * time.Minute}, // collectors section without box override
+		{"system", time.Minute},   // global interval
+		{"fiber", 0},              // disabled by default
+	}
+
+	for _, c := range cases {
+		if got := conf.boxInterval(box, c.name); got != c.want {
+			t.Errorf("boxInterval(%q) = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
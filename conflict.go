@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Another client which logs in with the same account as fritz-mon invalidates
+// the session of fritz-mon each time. If this happens at least
+// conflictThreshold times within conflictWindow, fritz-mon logs a hint to use
+// a dedicated account.
+const (
+	conflictThreshold = 3
+	conflictWindow    = time.Hour
+)
+
+// accountConflicts detects other clients which use the same FRITZ!Box account.
+type accountConflicts struct {
+	mu       sync.Mutex
+	logouts  uint64      // number of unexpected logouts seen so far
+	recent   []time.Time // times of the unexpected logouts within the window
+	lastHint time.Time
+}
+
+// checkAccountConflicts updates the logout metrics of the FRITZ!Box and logs a
+// hint if fritz-mon was logged out unusually often.
+func (b *Box) checkAccountConflicts() {
+	logouts := b.FritzBox.UnexpectedLogouts()
+	b.Metrics.System.UnexpectedLogouts.Observe(float64(logouts))
+
+	c := &b.conflicts
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for ; c.logouts < logouts; c.logouts++ {
+		c.recent = append(c.recent, now)
+	}
+
+	for len(c.recent) > 0 && now.Sub(c.recent[0]) > conflictWindow {
+		c.recent = c.recent[1:]
+	}
+
+	if len(c.recent) < conflictThreshold || now.Sub(c.lastHint) < conflictWindow {
+		return
+	}
+
+	c.lastHint = now
+	b.Logger.Warn("FRITZ!Box logged out fritz-mon unusually often. Probably another client uses the same account. Please create a dedicated FRITZ!Box user for fritz-mon.",
+		zap.String("username", b.Config.Username),
+		zap.Int("logouts", len(c.recent)),
+		zap.Duration("within", conflictWindow),
+	)
+}
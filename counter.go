@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// counterVec is a prometheus.Collector for counters which are maintained by
+// the FRITZ!Box itself, e.g. the accumulated energy of a smart plug. Unlike a
+// prometheus.CounterVec it is not incremented but set to the latest reading of
+// the FRITZ!Box. The FRITZ!Box starts counting from zero again when a device
+// is re-paired, so any decrease of a reading is treated as a counter reset and
+// the previous reading is carried over to keep the exported value monotonic.
+type counterVec struct {
+	desc *prometheus.Desc
+
+	mu     sync.Mutex
+	series map[string]*counterSeries // by joined label values
+}
+
+type counterSeries struct {
+	labelValues []string
+	last        float64 // latest reading of the FRITZ!Box
+	offset      float64 // sum of the readings before each counter reset
+}
+
+func newCounterVec(opts prometheus.CounterOpts, labelNames []string) *counterVec {
+	fqName := prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name)
+	return &counterVec{
+		desc:   prometheus.NewDesc(fqName, opts.Help, labelNames, opts.ConstLabels),
+		series: map[string]*counterSeries{},
+	}
+}
+
+func (c *counterVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *counterVec) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.series {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, s.offset+s.last, s.labelValues...)
+	}
+}
+
+// Observe records the latest reading of the FRITZ!Box and returns the value
+// which is exported to Prometheus.
+func (c *counterVec) Observe(reading float64, labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.get(labelValues)
+	if reading < s.last {
+		s.offset += s.last
+	}
+	s.last = reading
+
+	return s.offset + s.last
+}
+
+// Offset returns the sum of all readings before the last counter reset.
+func (c *counterVec) Offset(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.series[strings.Join(labelValues, "\xff")]; ok {
+		return s.offset
+	}
+	return 0
+}
+
+// Restore sets the state of a series as it was saved before fritz-mon was
+// restarted.
+func (c *counterVec) Restore(reading, offset float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.get(labelValues)
+	s.last = reading
+	s.offset = offset
+}
+
+// DeleteLabelValues removes the series with the given label values and returns
+// true if it existed.
+func (c *counterVec) DeleteLabelValues(labelValues ...string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := strings.Join(labelValues, "\xff")
+	_, ok := c.series[key]
+	delete(c.series, key)
+	return ok
+}
+
+func (c *counterVec) get(labelValues []string) *counterSeries {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{labelValues: labelValues}
+		c.series[key] = s
+	}
+	return s
+}
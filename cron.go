@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression with the five standard fields
+// minute, hour, day of month, month and day of week. Each field supports "*",
+// single values, ranges ("1-5"), lists ("0,30") and steps ("*/5" or "8-18/2").
+// Days of the week are 0 to 6 starting with Sunday, 7 is also accepted for
+// Sunday.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit n is set if value n matches
+
+	domAny, dowAny bool // whether the day fields start with "*", e.g. "*" or "*/2"
+}
+
+// cronFields are the names and ranges of the fields of a cron expression.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// maxCronSearch limits how far next looks into the future, e.g. for
+// expressions such as "0 0 31 2 *" which never match.
+const maxCronSearch = 5 * 366 * 24 * time.Hour
+
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields but has %d", expr, len(cronFields), len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %s: %w", expr, cronFields[i].name, err)
+		}
+	}
+
+	// Sunday is both 0 and 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		from, to := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			i := strings.Index(rangePart, "-")
+			var err1, err2 error
+			from, err1 = strconv.Atoi(rangePart[:i])
+			to, err2 = strconv.Atoi(rangePart[i+1:])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			var err error
+			from, err = strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", rangePart)
+			}
+			to = from
+			if step > 1 {
+				to = max // e.g. "5/15" means every 15 starting at 5
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// next returns the first time after t which matches the expression. It returns
+// the zero time if there is no such time within the next five years.
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.Add(maxCronSearch)
+
+	for t.Before(end) {
+		switch {
+		case !c.matches(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.matches(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !c.matches(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (c *cronSchedule) matches(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+// matchesDay implements the rule of cron that a day matches if either the day
+// of month or the day of week matches when both are restricted. Like in Vixie
+// cron, a field which starts with "*" such as "*/2" does not count as
+// restricted, so both fields must match then.
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	dom := c.matches(c.dom, t.Day())
+	dow := c.matches(c.dow, int(t.Weekday()))
+
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2020-01-01 was a Wednesday.
+	start := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		want []string // the next dates after start
+	}{
+		{"0 0 * * *", []string{"2020-01-02", "2020-01-03", "2020-01-04"}},
+		{"0 0 * * 1", []string{"2020-01-06", "2020-01-13", "2020-01-20"}},
+		{"0 0 15 * *", []string{"2020-01-15", "2020-02-15", "2020-03-15"}},
+
+		// Both day fields are restricted, so either of them must match.
+		{"0 0 15 * 1", []string{"2020-01-06", "2020-01-13", "2020-01-15"}},
+
+		// A day field starting with "*" is unrestricted, so both must match.
+		{"0 0 */2 * 1", []string{"2020-01-13", "2020-01-27", "2020-02-03"}},
+		{"0 0 1-7 * */7", []string{"2020-01-05", "2020-02-02", "2020-03-01"}},
+	}
+
+	for _, c := range cases {
+		schedule, err := parseCron(c.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", c.expr, err)
+		}
+
+		next := start
+		for _, want := range c.want {
+			next = schedule.next(next)
+			if got := next.Format("2006-01-02"); got != want {
+				t.Errorf("%q: got %s, want %s", c.expr, got, want)
+				break
+			}
+		}
+	}
+}
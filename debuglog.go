@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// logReadings logs the collected readings of all devices at debug level. If a
+// FullLogInterval is configured, only the readings which changed since they
+// were logged the last time are logged in between two full logs. This keeps
+// the debug log readable when many devices are monitored.
+func (m *DeviceMetrics) logReadings(readings map[string]map[string]float64, now time.Time) {
+	if m.logger.Check(zap.DebugLevel, "") == nil {
+		return
+	}
+
+	full := m.FullLogInterval <= 0 || now.Sub(m.lastFullLog) >= m.FullLogInterval
+	if full {
+		m.lastFullLog = now
+	}
+
+	names := make([]string, 0, len(readings))
+	for name := range readings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		changed := readings[name]
+		if !full {
+			changed = changedReadings(m.loggedReadings[name], readings[name])
+		}
+		m.loggedReadings[name] = readings[name]
+
+		if len(changed) == 0 {
+			continue
+		}
+
+		logFields := metricsToLogFields(name, changed)
+		if full {
+			m.logger.Debug("Collected device metrics", logFields...)
+		} else {
+			m.logger.Debug("Device metrics changed", logFields...)
+		}
+	}
+}
+
+// changedReadings returns the readings which differ from the previous ones.
+func changedReadings(previous, current map[string]float64) map[string]float64 {
+	changed := map[string]float64{}
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || old != value {
+			changed[key] = value
+		}
+	}
+
+	return changed
+}
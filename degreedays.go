@@ -0,0 +1,40 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+)
+
+// defaultHeatingBaseTemperature is the base temperature in °C below which a
+// room is considered to need heating (see VDI 3807).
+const defaultHeatingBaseTemperature = 15.0
+
+// maxDegreeDayGap is the longest time between two readings which is still
+// accounted for in the heating degree days. Larger gaps (e.g. when fritz-mon
+// was not running or the device was disconnected) are skipped since we do not
+// know the temperature in between.
+const maxDegreeDayGap = time.Hour
+
+// collectDegreeDays accumulates the heating degree days of a device. For each
+// period between two readings, the difference between the base temperature
+// and the measured temperature (if positive) is weighted by the length of the
+// period in days.
+func (m *DeviceMetrics) collectDegreeDays(device fritzbox.Device, celsius float64, now time.Time) {
+	last, ok := m.lastDegreeDayUpdate[device.Name]
+	m.lastDegreeDayUpdate[device.Name] = now
+
+	counter := m.HeatingDegreeDays.WithLabelValues(m.labels.values(device)...)
+	if !ok {
+		return
+	}
+
+	elapsed := now.Sub(last)
+	if elapsed <= 0 || elapsed > maxDegreeDayGap {
+		return
+	}
+
+	if diff := m.HeatingBaseTemperature - celsius; diff > 0 {
+		counter.Add(diff * elapsed.Hours() / 24)
+	}
+}
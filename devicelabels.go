@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// deviceLabelNames maps the keys of metrics.device_labels to the names of the
+// labels of all smart home device metrics.
+var deviceLabelNames = map[string]string{
+	"name":         "device_name",
+	"ain":          "ain",
+	"product":      "product",
+	"manufacturer": "manufacturer",
+}
+
+// defaultDeviceNameTemplate is the name of devices which were not named in the
+// FRITZ!Box UI yet, e.g. "FRITZ!DECT 200 5678".
+const defaultDeviceNameTemplate = "{product} {ain_suffix}"
+
+// defaultDeviceLabels contains the stable AIN in addition to the name, which
+// can be changed in the FRITZ!Box UI at any time.
+var defaultDeviceLabels = deviceLabels{"name", "ain"}
+
+type MetricsConfig struct {
+	DeviceLabels deviceLabels   `yaml:"device_labels"` // labels of the smart home device metrics, any of name, ain, product and manufacturer
+	Profile      metricsProfile `yaml:"profile"`       // which metrics are exported, full or minimal
+
+	// DefaultDeviceName is the name of devices without a name, using the
+	// placeholders {product}, {manufacturer}, {ain} and {ain_suffix}. An empty
+	// template keeps the empty name.
+	DefaultDeviceName string `yaml:"default_device_name"`
+
+	Anonymize    bool   `yaml:"anonymize"`               // replace device and host names, MAC addresses and other household details with pseudonyms
+	AnonymizeKey string `yaml:"anonymize_key,omitempty"` // secret key from which the pseudonyms are derived
+}
+
+func (c MetricsConfig) Validate() error {
+	if err := c.Profile.Validate(); err != nil {
+		return err
+	}
+
+	if err := validateDeviceNameTemplate(c.DefaultDeviceName); err != nil {
+		return err
+	}
+
+	if c.Anonymize && c.AnonymizeKey == "" {
+		return fmt.Errorf("metrics.anonymize_key is required to anonymize labels")
+	}
+
+	if len(c.DeviceLabels) == 0 {
+		return fmt.Errorf("metrics.device_labels cannot be empty")
+	}
+
+	seen := map[string]bool{}
+	for _, key := range c.DeviceLabels {
+		if _, ok := deviceLabelNames[key]; !ok {
+			return fmt.Errorf("metrics.device_labels: unknown label %q (must be one of name, ain, product or manufacturer)", key)
+		}
+		if seen[key] {
+			return fmt.Errorf("metrics.device_labels: duplicate label %q", key)
+		}
+		seen[key] = true
+	}
+
+	return nil
+}
+
+// gatherer returns g with the metrics profile and the anonymization of the
+// configuration applied.
+func (c MetricsConfig) gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	g = c.Profile.gatherer(g)
+	if c.Anonymize {
+		g = anonymizingGatherer{Gatherer: g, pseudonyms: c.pseudonymizer()}
+	}
+	return g
+}
+
+// pseudonymizer returns the pseudonymizer of the configuration or nil if
+// anonymization is disabled.
+func (c MetricsConfig) pseudonymizer() *pseudonymizer {
+	if !c.Anonymize {
+		return nil
+	}
+	return &pseudonymizer{key: []byte(c.AnonymizeKey)}
+}
+
+// deviceLabels are the keys of the labels which identify a smart home device
+// in all device metrics.
+type deviceLabels []string
+
+// names returns the label names in the order of the label values.
+func (l deviceLabels) names() []string {
+	names := make([]string, len(l))
+	for i, key := range l {
+		names[i] = deviceLabelNames[key]
+	}
+	return names
+}
+
+// values returns the label values of the given device.
+func (l deviceLabels) values(d fritzbox.Device) []string {
+	values := make([]string, len(l))
+	for i, key := range l {
+		values[i] = deviceLabelValue(d, key)
+	}
+	return values
+}
+
+// fromMap returns the label values from a map by label key. It returns false
+// if any value is missing, e.g. in a state file which was written with fewer
+// labels.
+func (l deviceLabels) fromMap(m map[string]string) ([]string, bool) {
+	values := make([]string, len(l))
+	for i, key := range l {
+		v, ok := m[key]
+		if !ok {
+			return nil, false
+		}
+		values[i] = v
+	}
+	return values, true
+}
+
+// deviceLabelValues returns all possible label values of a device by key. The
+// AIN is returned without the space after the vendor prefix.
+func deviceLabelValues(d fritzbox.Device) map[string]string {
+	values := make(map[string]string, len(deviceLabelNames))
+	for key := range deviceLabelNames {
+		values[key] = deviceLabelValue(d, key)
+	}
+	return values
+}
+
+// deviceLabelValue returns the value of a single label of a device. It is
+// called for every metric of every device, so unlike deviceLabelValues it
+// does not build a map.
+func deviceLabelValue(d fritzbox.Device, key string) string {
+	switch key {
+	case "name":
+		return d.Name
+	case "ain":
+		return strings.ReplaceAll(d.Identifier, " ", "")
+	case "product":
+		return d.ProductName
+	case "manufacturer":
+		return d.Manufacturer
+	default:
+		return ""
+	}
+}
+
+// deviceNamePlaceholders are the placeholders of metrics.default_device_name.
+var deviceNamePlaceholders = []string{"{product}", "{manufacturer}", "{ain}", "{ain_suffix}"}
+
+// ainSuffixLength is the number of trailing digits of the AIN in {ain_suffix}.
+const ainSuffixLength = 4
+
+func validateDeviceNameTemplate(template string) error {
+	rest := template
+	for _, placeholder := range deviceNamePlaceholders {
+		rest = strings.ReplaceAll(rest, placeholder, "")
+	}
+
+	if strings.Contains(rest, "{") {
+		return fmt.Errorf("metrics.default_device_name: unknown placeholder in %q (must be one of %s)", template, strings.Join(deviceNamePlaceholders, ", "))
+	}
+
+	return nil
+}
+
+// defaultDeviceName returns the name of a device without a name from the
+// given template. If the template does not yield a name, e.g. because the
+// product is unknown, the AIN is used so every device has a usable identity.
+func defaultDeviceName(template string, d fritzbox.Device) string {
+	ain := strings.ReplaceAll(d.Identifier, " ", "")
+	suffix := ain
+	if len(suffix) > ainSuffixLength {
+		suffix = suffix[len(suffix)-ainSuffixLength:]
+	}
+
+	name := strings.NewReplacer(
+		"{product}", d.ProductName,
+		"{manufacturer}", d.Manufacturer,
+		"{ain}", ain,
+		"{ain_suffix}", suffix,
+	).Replace(template)
+
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || name == suffix {
+		return ain
+	}
+
+	return name
+}
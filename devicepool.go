@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+)
+
+const (
+	defaultDeviceWorkers        = 4
+	defaultDeviceRequestTimeout = 10 * time.Second
+)
+
+// devicePool runs follow-up requests for individual smart home devices (e.g.
+// switching off a device in standby) after the device list was fetched. The
+// FRITZ!Box handles only few requests at once, so the number of concurrent
+// requests is bounded and each request gets its own timeout so a single
+// unresponsive device cannot stall the whole collection.
+type devicePool struct {
+	Workers int           // maximum number of concurrent requests, at least 1
+	Timeout time.Duration // timeout of each request, zero only uses the collection deadline
+}
+
+// run calls fn for each device and returns the errors by index of the device
+// in devices. A device which was not processed because ctx was done gets the
+// error of the context.
+func (p devicePool) run(ctx context.Context, devices []fritzbox.Device, fn func(ctx context.Context, device fritzbox.Device) error) []error {
+	errs := make([]error, len(devices))
+
+	workers := p.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(devices) {
+		workers = len(devices)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = p.call(ctx, devices[i], fn)
+			}
+		}()
+	}
+
+	for i := range devices {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			errs[i] = ctx.Err()
+		}
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
+
+func (p devicePool) call(ctx context.Context, device fritzbox.Device, fn func(ctx context.Context, device fritzbox.Device) error) error {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+
+	return fn(ctx, device)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+)
+
+// energyPeriods are the periods of the energy usage metric by grid of the
+// energy series and index of the value in the series (0 is the newest one).
+var energyPeriods = []struct {
+	name  string
+	grid  int
+	index int
+}{
+	{"today", fritzbox.StatsGridDay, 0},
+	{"yesterday", fritzbox.StatsGridDay, 1},
+	{"this_month", fritzbox.StatsGridMonth, 0},
+	{"last_month", fritzbox.StatsGridMonth, 1},
+}
+
+// deviceTasks returns the enabled requests for individual devices which run
+// through the pipeline after the device list was fetched.
+func (m *DeviceMetrics) deviceTasks(client *fritzbox.Client) []deviceTask {
+	var tasks []deviceTask
+	if m.StatsInterval > 0 {
+		tasks = append(tasks, m.statsTask(client))
+	}
+	return tasks
+}
+
+// statsTask fetches the statistics of all connected devices which measure
+// their power or temperature and exports the energy usage per day and month
+// as well as the lowest and highest temperature of the last 24 hours. The
+// FRITZ!Box keeps these statistics itself, so dashboards can show consumption
+// trends independent of the retention of Prometheus.
+func (m *DeviceMetrics) statsTask(client *fritzbox.Client) deviceTask {
+	return deviceTask{
+		name: "device statistics",
+		ttl:  m.StatsInterval,
+		filter: func(d fritzbox.Device) bool {
+			return d.Present == 1 && (d.CanMeasurePower() || d.CanMeasureTemperature())
+		},
+		fetch: func(ctx context.Context, d fritzbox.Device) (interface{}, error) {
+			return client.DeviceStats(ctx, d.Identifier)
+		},
+		apply: func(d fritzbox.Device, data interface{}) {
+			stats := data.(*fritzbox.DeviceStats)
+			labels := m.labels.values(d)
+			m.collectEnergyUsage(stats, labels)
+			m.collectTemperatureRange(stats, labels, time.Now())
+		},
+	}
+}
+
+func (m *DeviceMetrics) collectEnergyUsage(stats *fritzbox.DeviceStats, labels []string) {
+	for _, period := range energyPeriods {
+		periodLabels := append(labels[:len(labels):len(labels)], period.name)
+
+		var value float64
+		var ok bool
+		for _, series := range stats.Energy {
+			if series.Grid == period.grid {
+				value, ok = series.Value(period.index, 1)
+				break
+			}
+		}
+
+		if ok {
+			m.EnergyUsage.WithLabelValues(periodLabels...).Set(value)
+		} else {
+			m.EnergyUsage.DeleteLabelValues(periodLabels...)
+		}
+	}
+}
+
+func (m *DeviceMetrics) collectTemperatureRange(stats *fritzbox.DeviceStats, labels []string, now time.Time) {
+	if len(stats.Temperature) == 0 {
+		return
+	}
+
+	samples, err := selectSamples(stats, "temperature", 24*time.Hour, now)
+	if err != nil || len(samples) == 0 {
+		m.TemperatureMin.DeleteLabelValues(labels...)
+		m.TemperatureMax.DeleteLabelValues(labels...)
+		return
+	}
+
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, s := range samples {
+		min = math.Min(min, s.Value)
+		max = math.Max(max, s.Value)
+	}
+
+	m.TemperatureMin.WithLabelValues(labels...).Set(min)
+	m.TemperatureMax.WithLabelValues(labels...).Set(max)
+}
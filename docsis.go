@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type DOCSISMetrics struct {
+	Frequency           *prometheus.GaugeVec
+	PowerLevel          *prometheus.GaugeVec
+	MER                 *prometheus.GaugeVec
+	CorrectedErrors     *counterVec
+	UncorrectableErrors *counterVec
+	Locked              *prometheus.GaugeVec
+	ChannelInfo         *prometheus.GaugeVec
+
+	// channels contains the labels of all channels which were ever reported so
+	// channels which the modem lost its lock on are exported as unlocked.
+	channels map[string][]string // label values by joined label values
+
+	logger *zap.Logger
+}
+
+var docsisLabels = []string{"direction", "channel_id", "docsis_version"}
+
+func NewDOCSISMetrics(logger *zap.Logger) *DOCSISMetrics {
+	namespace := "fritzbox"
+	subsystem := "docsis"
+
+	return &DOCSISMetrics{
+		logger:   logger,
+		channels: map[string][]string{},
+		Frequency: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "frequency_hertz",
+				Help:      "Frequency of the channel in Hz.",
+			},
+			docsisLabels,
+		),
+		PowerLevel: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "power_level_dbuv",
+				Help:      "Power level of the channel in dBµV.",
+			},
+			docsisLabels,
+		),
+		MER: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "mer_db",
+				Help:      "Modulation error ratio (SNR) of the channel in dB.",
+			},
+			docsisLabels,
+		),
+		CorrectedErrors: newCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "corrected_errors_total",
+				Help:      "Number of errors which were corrected via forward error correction (downstream channels only).",
+			},
+			docsisLabels,
+		),
+		UncorrectableErrors: newCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "uncorrectable_errors_total",
+				Help:      "Number of errors which could not be corrected (downstream channels only).",
+			},
+			docsisLabels,
+		),
+		Locked: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "channel_locked_bool",
+				Help:      "Either 0 or 1 to indicate if the cable modem is locked to the channel.",
+			},
+			docsisLabels,
+		),
+		ChannelInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "channel_info",
+				Help:      "Always 1. The modulation label describes the current modulation of the channel.",
+			},
+			append(docsisLabels, "modulation"),
+		),
+	}
+}
+
+func (m *DOCSISMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.Frequency,
+		m.PowerLevel,
+		m.MER,
+		m.CorrectedErrors,
+		m.UncorrectableErrors,
+		m.Locked,
+		m.ChannelInfo,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *DOCSISMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client) ([]fritzbox.DOCSISChannel, error) {
+	channels, err := client.DOCSISChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The FRITZ!Box only reports the channels the modem is locked to, so all
+	// other channels we have seen before are exported as unlocked and their
+	// signal values are removed.
+	for _, labels := range m.channels {
+		m.Locked.WithLabelValues(labels...).Set(0)
+		m.Frequency.DeleteLabelValues(labels...)
+		m.PowerLevel.DeleteLabelValues(labels...)
+		m.MER.DeleteLabelValues(labels...)
+	}
+	m.ChannelInfo.Reset() // only ever export the current modulation
+
+	var downstream, upstream int
+	for _, ch := range channels {
+		labels := []string{ch.Direction, ch.ChannelID, ch.Version}
+		m.channels[strings.Join(labels, "\xff")] = labels
+
+		m.Locked.WithLabelValues(labels...).Set(1)
+		m.Frequency.WithLabelValues(labels...).Set(ch.Frequency * 1e6)
+		m.PowerLevel.WithLabelValues(labels...).Set(ch.PowerLevel)
+		if ch.HasMER {
+			m.MER.WithLabelValues(labels...).Set(ch.MER)
+		}
+		if ch.HasErrors {
+			m.CorrectedErrors.Observe(ch.CorrectedErrors, labels...)
+			m.UncorrectableErrors.Observe(ch.UncorrectableErrors, labels...)
+		}
+		m.ChannelInfo.WithLabelValues(append(labels, ch.Modulation)...).Set(1)
+
+		if ch.Direction == fritzbox.Downstream {
+			downstream++
+		} else {
+			upstream++
+		}
+	}
+
+	m.logger.Debug("Collected DOCSIS metrics",
+		zap.Int("downstream_channels", downstream),
+		zap.Int("upstream_channels", upstream),
+	)
+	return channels, nil
+}
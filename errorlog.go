@@ -0,0 +1,72 @@
+package main
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// errorLog logs the errors of a single collector. If the same error occurs
+// repeatedly (e.g. because the FRITZ!Box is down), it is only logged once and
+// then summarized at most once per window instead of being logged again each
+// interval. An errorLog is not safe for concurrent use.
+type errorLog struct {
+	logger *zap.Logger
+	window time.Duration // zero disables deduplication
+
+	last     string    // message of the last logged error
+	repeated int       // how often the last error was suppressed since lastLog
+	lastLog  time.Time // when the last error or summary was logged
+}
+
+func newErrorLog(logger *zap.Logger, window time.Duration) *errorLog {
+	return &errorLog{logger: logger, window: window}
+}
+
+// Error logs a failed collection.
+func (l *errorLog) Error(msg string, err error) {
+	if l.window <= 0 {
+		l.logger.Error(msg, zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	if err.Error() != l.last {
+		l.flush()
+		l.logger.Error(msg, zap.Error(err))
+		l.last = err.Error()
+		l.lastLog = now
+		return
+	}
+
+	l.repeated++
+	if now.Sub(l.lastLog) >= l.window {
+		l.flush()
+		l.lastLog = now
+	}
+}
+
+// Resolve must be called after each successful collection.
+func (l *errorLog) Resolve() {
+	if l.last == "" {
+		return
+	}
+
+	l.flush()
+	l.logger.Info("Collection succeeded again")
+	l.last = ""
+}
+
+// flush logs a summary of all suppressed errors.
+func (l *errorLog) flush() {
+	if l.repeated == 0 {
+		return
+	}
+
+	l.logger.Error("Error repeated",
+		zap.String("error", l.last),
+		zap.Int("times", l.repeated),
+		zap.Duration("since", time.Since(l.lastLog).Round(time.Second)),
+	)
+	l.repeated = 0
+}
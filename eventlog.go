@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type EventLogMetrics struct {
+	Events         *prometheus.CounterVec // entries of the event log by class (system, internet, telephony, wlan, usb, other)
+	FailedLogins   *prometheus.CounterVec // failed login attempts by source (web, vpn, sip, other)
+	WPSActivations prometheus.Counter     // WPS was activated e.g. via the button on the box
+	GuestLogins    prometheus.Counter     // devices which logged on to the guest access
+
+	LogEvents bool // log each new entry
+
+	logger   *zap.Logger
+	lastTime time.Time       // timestamp of the newest log entry we have seen so far
+	lastSeen map[string]bool // messages of all entries at lastTime
+}
+
+func NewEventLogMetrics(logger *zap.Logger) *EventLogMetrics {
+	namespace := "fritzbox"
+	subsystem := "event_log"
+
+	return &EventLogMetrics{
+		logger: logger,
+		Events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "events_total",
+				Help:      "Number of entries of the FRITZ!Box event log by class.",
+			},
+			[]string{"class"},
+		),
+		FailedLogins: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "failed_logins_total",
+				Help:      "Number of failed login attempts at the FRITZ!Box found in its event log.",
+			},
+			[]string{"source"},
+		),
+		WPSActivations: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "wps_activations_total",
+				Help:      "Number of WPS activations at the FRITZ!Box found in its event log.",
+			},
+		),
+		GuestLogins: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "guest_logins_total",
+				Help:      "Number of devices which logged on to the guest access of the FRITZ!Box found in its event log.",
+			},
+		),
+	}
+}
+
+func (m *EventLogMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.Events,
+		m.FailedLogins,
+		m.WPSActivations,
+		m.GuestLogins,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FetchFrom reads the event log of the FRITZ!Box and counts all entries which
+// have been added since the last call. The very first call only remembers the
+// newest entry so restarting fritz-mon does not count old events again.
+func (m *EventLogMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client) ([]fritzbox.EventLogEntry, error) {
+	entries, err := client.EventLog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	initial := m.lastSeen == nil
+	newEntries := m.newEntries(entries)
+	if initial {
+		m.logger.Debug("Initialized event log monitoring", zap.Time("newest_entry", m.lastTime))
+		for _, class := range fritzbox.EventClasses {
+			m.Events.WithLabelValues(class)
+		}
+		for _, source := range []string{"web", "vpn", "sip", "other"} {
+			m.FailedLogins.WithLabelValues(source)
+		}
+		return nil, nil
+	}
+
+	for _, entry := range newEntries {
+		m.collectEntry(entry)
+	}
+
+	m.logger.Debug("Collected event log metrics", zap.Int("new_entries", len(newEntries)))
+	return newEntries, nil
+}
+
+// newEntries returns all entries which have not been seen before. The
+// FRITZ!Box returns its log newest first and timestamps have only a resolution
+// of one second so we have to remember all messages of the newest second.
+func (m *EventLogMetrics) newEntries(entries []fritzbox.EventLogEntry) []fritzbox.EventLogEntry {
+	var result []fritzbox.EventLogEntry
+	for _, entry := range entries {
+		if entry.Time.Before(m.lastTime) {
+			break
+		}
+		if entry.Time.Equal(m.lastTime) && m.lastSeen[entry.Message] {
+			continue
+		}
+		result = append(result, entry)
+	}
+
+	if len(entries) > 0 && entries[0].Time.After(m.lastTime) {
+		m.lastTime = entries[0].Time
+		m.lastSeen = map[string]bool{}
+	}
+
+	if m.lastSeen == nil {
+		m.lastSeen = map[string]bool{}
+	}
+
+	for _, entry := range entries {
+		if !entry.Time.Equal(m.lastTime) {
+			break
+		}
+		m.lastSeen[entry.Message] = true
+	}
+
+	return result
+}
+
+func (m *EventLogMetrics) collectEntry(entry fritzbox.EventLogEntry) {
+	m.Events.WithLabelValues(entry.Class).Inc()
+	if m.LogEvents {
+		m.logger.Info("FRITZ!Box event",
+			zap.String("class", entry.Class),
+			zap.String("id", entry.ID),
+			zap.Time("time", entry.Time),
+			zap.String("message", entry.Message),
+		)
+	}
+
+	if source, ok := failedLoginSource(entry.Message); ok {
+		m.FailedLogins.WithLabelValues(source).Inc()
+		m.logger.Warn("Detected failed login attempt at FRITZ!Box",
+			zap.String("source", source),
+			zap.Time("time", entry.Time),
+			zap.String("message", entry.Message),
+		)
+	}
+
+	switch {
+	case isWPSActivation(entry.Message):
+		m.WPSActivations.Inc()
+		m.logger.Info("Detected WPS activation at FRITZ!Box",
+			zap.Time("time", entry.Time),
+			zap.String("message", entry.Message),
+		)
+	case isGuestLogin(entry.Message):
+		m.GuestLogins.Inc()
+	}
+}
+
+// failedLoginSource checks if the given event log message reports a failed
+// login attempt at the FRITZ!Box and if so returns where it came from. The
+// FRITZ!Box writes its log in the language of the user interface so we need
+// to match the German and the English wording.
+func failedLoginSource(msg string) (source string, ok bool) {
+	msg = strings.ToLower(msg)
+	if !containsAny(msg, "anmeldung", "login", "log-in") {
+		return "", false
+	}
+
+	if !containsAny(msg, "gescheitert", "fehlgeschlagen", "nicht erfolgreich", "failed", "unsuccessful") {
+		return "", false
+	}
+
+	// Failed registrations of the FRITZ!Box at the telephony provider are
+	// reported in a similar way but are no login attempts at the box.
+	if containsAny(msg, "internetrufnummer", "internet telephone number") {
+		return "", false
+	}
+
+	switch {
+	case containsAny(msg, "vpn"):
+		return "vpn", true
+	case containsAny(msg, "sip", "ip-telefon", "ip telephone"):
+		return "sip", true
+	case containsAny(msg, "benutzeroberfläche", "user interface", "web"):
+		return "web", true
+	default:
+		return "other", true
+	}
+}
+
+// isWPSActivation checks if the given event log message reports that WPS was
+// activated, e.g. by pressing the WPS button on the FRITZ!Box.
+func isWPSActivation(msg string) bool {
+	msg = strings.ToLower(msg)
+	if !strings.Contains(msg, "wps") {
+		return false
+	}
+
+	if containsAny(msg, "deaktiviert", "beendet", "deactivated", "disabled", "stopped", "ended") {
+		return false
+	}
+
+	return containsAny(msg, "aktiviert", "gestartet", "activated", "enabled", "started")
+}
+
+// isGuestLogin checks if the given event log message reports that a device
+// logged on to the guest access of the FRITZ!Box.
+func isGuestLogin(msg string) bool {
+	msg = strings.ToLower(msg)
+	if !containsAny(msg, "gastzugang", "guest access") {
+		return false
+	}
+
+	return containsAny(msg, "angemeldet", "logged on", "logged in")
+}
+
+func containsAny(s string, substrings ...string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
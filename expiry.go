@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+// defaultExpireDevicesAfter is the default number of consecutive collections
+// after which the series of a device which disappeared are removed.
+const defaultExpireDevicesAfter = 3
+
+// knownDevice is a device which was part of a previous collection.
+type knownDevice struct {
+	device  fritzbox.Device
+	missing int // number of consecutive collections without the device
+}
+
+// expireDevices removes all series of devices which were not part of the last
+// ExpireAfter collections, e.g. because they were removed from the FRITZ!Box
+// or renamed while their name is a label. Without this the old series would
+// keep reporting their last value forever. Devices which are only disconnected
+// are still part of the device list and are therefore never expired.
+func (m *DeviceMetrics) expireDevices(devices []fritzbox.Device) {
+	if m.ExpireAfter <= 0 {
+		return
+	}
+
+	current := make(map[string]bool, len(devices))
+	names := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		key := strings.Join(m.labels.values(d), "\xff")
+		current[key] = true
+		names[d.Name] = true
+		m.knownDevices[key] = &knownDevice{device: d}
+	}
+
+	for key, known := range m.knownDevices {
+		if current[key] {
+			continue
+		}
+
+		known.missing++
+		if known.missing < m.ExpireAfter {
+			continue
+		}
+
+		m.logger.Info("Removing metrics of device which disappeared from the FRITZ!Box",
+			zap.String("device_name", known.device.Name),
+			zap.String("ain", known.device.Identifier),
+			zap.Int("collections", known.missing),
+		)
+
+		m.deleteSeries(known.device)
+		if !names[known.device.Name] {
+			m.forget(known.device.Name)
+		}
+		delete(m.knownDevices, key)
+	}
+}
+
+// deleteSeries deletes all series of the given device.
+func (m *DeviceMetrics) deleteSeries(device fritzbox.Device) {
+	labels := m.labels.values(device)
+
+	vecs := []interface {
+		DeleteLabelValues(...string) bool
+	}{
+		m.IsPoweredOn,
+		m.IsConnected,
+		m.Temperature,
+		m.Humidity,
+		m.Battery,
+		m.Power,
+		m.Voltage,
+		m.Energy,
+		m.BoostActive,
+		m.BoostEndTime,
+		m.HolidayActive,
+		m.SummerActive,
+		m.Level,
+		m.ColorHue,
+		m.ColorSaturation,
+		m.ColorTemp,
+		m.BlindPosition,
+		m.BlindEndPositionsSet,
+		m.Alert,
+		m.LastAlert,
+		m.SwitchOnTransitions,
+		m.HeatingDegreeDays,
+		m.TargetTemperature,
+		m.TemperatureDeviation,
+		m.OutsideTolerance,
+		m.WindowOpen,
+		m.TemperatureMin,
+		m.TemperatureMax,
+		m.Standby.SwitchOffs,
+	}
+	for _, vec := range vecs {
+		vec.DeleteLabelValues(labels...)
+	}
+
+	for _, period := range energyPeriods {
+		m.EnergyUsage.DeleteLabelValues(append(labels[:len(labels):len(labels)], period.name)...)
+	}
+	for i, button := range device.Buttons {
+		m.ButtonLastPressed.DeleteLabelValues(append(labels[:len(labels):len(labels)], buttonName(button, i))...)
+	}
+}
+
+// forget removes the state which is kept by device name for a device which
+// disappeared.
+func (m *DeviceMetrics) forget(name string) {
+	delete(m.switchState, name)
+	delete(m.pendingEnergy, name)
+	delete(m.lastDegreeDayUpdate, name)
+	delete(m.lastThermostatUpdate, name)
+	delete(m.lastWindowUpdate, name)
+	delete(m.loggedReadings, name)
+	delete(m.Standby.standbySince, name)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type FiberMetrics struct {
+	RXPower     prometheus.Gauge
+	TXPower     prometheus.Gauge
+	Operational prometheus.Gauge
+	StateInfo   *prometheus.GaugeVec
+
+	logger *zap.Logger
+}
+
+func NewFiberMetrics(logger *zap.Logger) *FiberMetrics {
+	namespace := "fritzbox"
+	subsystem := "fiber"
+
+	return &FiberMetrics{
+		logger: logger,
+		RXPower: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "rx_power_dbm",
+				Help:      "Received optical power in dBm.",
+			},
+		),
+		TXPower: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "tx_power_dbm",
+				Help:      "Transmitted optical power in dBm.",
+			},
+		),
+		Operational: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "operational_bool",
+				Help:      "Either 0 or 1 to indicate if the optical network termination is operational (GPON state O5).",
+			},
+		),
+		StateInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "state_info",
+				Help:      "Always 1. The labels describe the type and activation state of the fiber connection.",
+			},
+			[]string{"type", "state"},
+		),
+	}
+}
+
+func (m *FiberMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.RXPower,
+		m.TXPower,
+		m.Operational,
+		m.StateInfo,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *FiberMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client) (*fritzbox.FiberStatus, error) {
+	status, err := client.FiberStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.RXPower.Set(status.RXPower)
+	m.TXPower.Set(status.TXPower)
+	m.Operational.Set(prometheusBool(status.IsOperational()))
+
+	m.StateInfo.Reset() // only ever export the current state
+	m.StateInfo.WithLabelValues(status.Type, status.State).Set(1)
+
+	m.logger.Debug("Collected fiber metrics",
+		zap.String("type", status.Type),
+		zap.String("state", status.State),
+		zap.Float64("rx_power_dbm", status.RXPower),
+		zap.Float64("tx_power_dbm", status.TXPower),
+	)
+	return status, nil
+}
@@ -0,0 +1,47 @@
+package fritzbox
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type BlindInfo struct {
+	EndPositionsSet string `xml:"endpositionsset"` // "1" if the end positions of the blind are calibrated, "0" if not.
+	Mode            string `xml:"mode"`            // "auto" if the blind follows a schedule, "manuell" otherwise.
+}
+
+// ETSIUnitInfo describes the HAN-FUN unit of a device.
+type ETSIUnitInfo struct {
+	ETSIDeviceID string `xml:"etsideviceid"` // Internal ID of the HAN-FUN device the unit belongs to.
+	UnitType     string `xml:"unittype"`     // HAN-FUN unit type, e.g. "281" for blinds.
+	Interfaces   string `xml:"interfaces"`   // Comma separated list of HAN-FUN interfaces, e.g. "256,513,516,517".
+}
+
+// ETSIUnitTypeBlind is the HAN-FUN unit type of blinds and shutters.
+const ETSIUnitTypeBlind = "281"
+
+func (i BlindInfo) AreEndPositionsSet() bool {
+	return i.EndPositionsSet == "1"
+}
+
+// BlindTarget is the target of a setblind command.
+type BlindTarget string
+
+// Known targets of a setblind command.
+const (
+	BlindOpen  BlindTarget = "open"
+	BlindClose BlindTarget = "close"
+	BlindStop  BlindTarget = "stop"
+)
+
+// SetBlind opens or closes the blind with the given AIN or stops it while it
+// is moving. Note that the AIN of a HAN-FUN blind is the AIN of its unit,
+// usually the AIN of the device followed by "-1".
+func (c *Client) SetBlind(ctx context.Context, ain string, target BlindTarget) error {
+	c.logger.Debug("Moving blind", zap.String("target", string(target)))
+
+	_, err := c.doCommand(ctx, "setblind", "ain", strings.ReplaceAll(ain, " ", ""), "target", string(target))
+	return err
+}
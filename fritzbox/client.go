@@ -3,25 +3,44 @@ package fritzbox
 import (
 	"bytes"
 	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 )
 
 type Client struct {
+	// 64-bit fields which are accessed atomically come first to guarantee
+	// their alignment on 32-bit platforms (e.g. older Raspberry Pis).
+	lastRequest       int64  // unix nano time of the last request
+	blockedUntil      int64  // unix nano time until which logins are blocked
+	unexpectedLogouts uint64 // sessions invalidated before they timed out
+
 	Username string
 	Password string
 	BaseURL  url.URL // must not be a pointer to avoid modifying this URL during our requests
 
+	// Location is the time zone of the FRITZ!Box which is used for the times
+	// in its event log. If nil, the system time zone is used.
+	Location *time.Location
+
 	http   *http.Client
 	logger *zap.Logger
 
-	mu      sync.Mutex
-	session Session
+	mu           sync.Mutex
+	session      Session
+	sessionStart time.Time // when the current session was created
+
+	sessionInvalid int32        // set to 1 if the FRITZ!Box rejected the session, accessed atomically
+	invalidSID     atomic.Value // last session ID which the FRITZ!Box rejected
+	waiting        int32        // set to 1 while the FRITZ!Box is unavailable (see SetWaiting), accessed atomically
 }
 
 func New(baseURL, username, password string, logger *zap.Logger) (*Client, error) {
@@ -40,37 +59,149 @@ func New(baseURL, username, password string, logger *zap.Logger) (*Client, error
 	}, nil
 }
 
+// SetHTTPClient replaces the http.DefaultClient which is used by default.
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.http = client
+}
+
+// SetPassword replaces the password which is used for the next login. The
+// current session stays valid.
+func (c *Client) SetPassword(password string) {
+	c.mu.Lock()
+	c.Password = password
+	c.mu.Unlock()
+}
+
+// SetWaiting tells the client whether the FRITZ!Box is currently unavailable,
+// e.g. because it reboots. A rebooted FRITZ!Box rejects all sessions, so
+// while waiting, rejected sessions are not counted as unexpected logouts.
+func (c *Client) SetWaiting(waiting bool) {
+	var v int32
+	if waiting {
+		v = 1
+	}
+	atomic.StoreInt32(&c.waiting, v)
+}
+
+// ErrPartial is returned together with the devices which were received before
+// the context of Devices expired.
+var ErrPartial = errors.New("device list is incomplete")
+
+// Devices returns all smart home devices. The device list is decoded while it
+// is received from the FRITZ!Box, so if the context expires in the middle of
+// a large list, the devices received so far are returned with an error that
+// wraps ErrPartial.
 func (c *Client) Devices(ctx context.Context) ([]Device, error) {
 	c.logger.Debug("Requesting list of devices")
 
-	var response DeviceList
-	err := c.doXMLCommand(ctx, &response, "getdevicelistinfos")
-	return response.Devices, err
+	var devices []Device
+	err := c.withSession(ctx, func(sessionID string) error {
+		var err error
+		devices, err = c.devices(ctx, sessionID)
+		return err
+	})
+
+	return devices, err
 }
 
-func (c *Client) doCommand(ctx context.Context, cmd string, args ...string) (*bytes.Buffer, error) {
-	args, err := c.prepareCommand(ctx, cmd, args)
+func (c *Client) devices(ctx context.Context, sessionID string) ([]Device, error) {
+	args := commandArgs(sessionID, "getdevicelistinfos", nil)
+	body, previousRequest, err := c.open(ctx, "GET", "/webservices/homeautoswitch.lua", args...)
 	if err != nil {
 		return nil, err
 	}
-	return c.get(ctx, "/webservices/homeautoswitch.lua", args...)
+	defer discardBody(body)
+
+	devices, err := parseDeviceList(io.LimitReader(body, maxResponseSize))
+	switch {
+	case err == nil:
+		return devices, nil
+	case errors.Is(err, errLoginPage):
+		c.invalidateSession(sessionID, previousRequest)
+		return nil, ErrSessionInvalid
+	case len(devices) > 0 && ctx.Err() != nil:
+		return devices, fmt.Errorf("%w: received %d devices before %v", ErrPartial, len(devices), ctx.Err())
+	default:
+		return nil, err
+	}
 }
 
-func (c *Client) doXMLCommand(ctx context.Context, target interface{}, cmd string, args ...string) error {
-	args, err := c.prepareCommand(ctx, cmd, args)
-	if err != nil {
-		return err
+// errLoginPage is returned by parseDeviceList if the FRITZ!Box responded with
+// the HTML login page instead of the device list.
+var errLoginPage = errors.New("FRITZ!Box responded with the login page")
+
+// parseDeviceList decodes the response of getdevicelistinfos one device at a
+// time. If the response breaks off, the devices which were decoded until then
+// are returned together with the error.
+func parseDeviceList(r io.Reader) ([]Device, error) {
+	var devices []Device
+	dec := xml.NewDecoder(r)
+	root := true
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return devices, nil
+		}
+		if err != nil {
+			return devices, fmt.Errorf("failed to parse device list: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if root {
+			root = false
+			switch start.Name.Local {
+			case "devicelist":
+				continue
+			case "html":
+				return nil, errLoginPage
+			default:
+				return nil, fmt.Errorf("unexpected element %q instead of device list", start.Name.Local)
+			}
+		}
+
+		if start.Name.Local != "device" {
+			if err := dec.Skip(); err != nil { // e.g. device groups
+				return devices, fmt.Errorf("failed to parse device list: %w", err)
+			}
+			continue
+		}
+
+		var device Device
+		if err := dec.DecodeElement(&device, &start); err != nil {
+			return devices, fmt.Errorf("failed to parse device list: %w", err)
+		}
+
+		devices = append(devices, device)
 	}
-	return c.getXML(ctx, target, "/webservices/homeautoswitch.lua", args...)
 }
 
-func (c *Client) prepareCommand(ctx context.Context, cmd string, args []string) ([]string, error) {
-	sessionID, err := c.getSession(ctx)
-	if err != nil {
-		return nil, err
-	}
+func (c *Client) doCommand(ctx context.Context, cmd string, args ...string) (*bytes.Buffer, error) {
+	var resp *bytes.Buffer
+	err := c.withSession(ctx, func(sessionID string) error {
+		var err error
+		resp, err = c.get(ctx, "/webservices/homeautoswitch.lua", commandArgs(sessionID, cmd, args)...)
+		return err
+	})
+
+	return resp, err
+}
+
+func (c *Client) doXMLCommand(ctx context.Context, target interface{}, cmd string, args ...string) error {
+	return c.withSession(ctx, func(sessionID string) error {
+		return c.getXML(ctx, target, "/webservices/homeautoswitch.lua", commandArgs(sessionID, cmd, args)...)
+	})
+}
 
-	return append(args, "sid", sessionID, "switchcmd", cmd), nil
+// commandArgs returns the query arguments of a command of the AHA HTTP
+// interface. The given args are copied so they can be reused on a retry.
+func commandArgs(sessionID, cmd string, args []string) []string {
+	result := make([]string, 0, len(args)+4)
+	result = append(result, args...)
+	return append(result, "sid", sessionID, "switchcmd", cmd)
 }
 
 func (c *Client) Close() error {
@@ -0,0 +1,83 @@
+package fritzbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dataLua requests a page of the data.lua endpoint which is used by the web
+// interface of the FRITZ!Box to load its content. The response is a JSON
+// object whose "data" field is decoded into target.
+//
+// Note that data.lua is not an official API so the structure of the responses
+// may change between FRITZ!OS versions.
+func (c *Client) dataLua(ctx context.Context, page string, target interface{}, args ...string) error {
+	var resp *bytes.Buffer
+	err := c.withSession(ctx, func(sessionID string) error {
+		var err error
+		resp, err = c.post(ctx, "/data.lua", append(args[:len(args):len(args)],
+			"sid", sessionID,
+			"xhr", "1",
+			"lang", "en",
+			"page", page,
+		)...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("data.lua page %q: %w", page, err)
+	}
+
+	return decodeDataLua(resp, page, target)
+}
+
+// decodeDataLua decodes the "data" field of a data.lua response into target.
+func decodeDataLua(r io.Reader, page string, target interface{}) error {
+	var result struct {
+		Data json.RawMessage `json:"data"`
+	}
+
+	err := json.NewDecoder(r).Decode(&result)
+	if err != nil {
+		return fmt.Errorf("failed to decode data.lua response as JSON: %w", err)
+	}
+
+	err = json.Unmarshal(result.Data, target)
+	if err != nil {
+		return fmt.Errorf("failed to decode data.lua page %q: %w", page, err)
+	}
+
+	return nil
+}
+
+// flexFloat is a number in a data.lua response. Depending on the FRITZ!OS
+// version, numbers are encoded either as JSON numbers or as strings.
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" || s == "-" {
+		*f = 0
+		return nil
+	}
+
+	v, err := parseFinite(s)
+	if err != nil {
+		return fmt.Errorf("invalid number %s", b)
+	}
+
+	*f = flexFloat(v)
+	return nil
+}
+
+// flexString is a string in a data.lua response which may also be encoded
+// as a JSON number.
+type flexString string
+
+func (s *flexString) UnmarshalJSON(b []byte) error {
+	*s = flexString(strings.Trim(string(b), `"`))
+	return nil
+}
@@ -1,6 +1,9 @@
 package fritzbox
 
-import "strconv"
+import (
+	"strconv"
+	"time"
+)
 
 // Capability enumerates the device capabilities.
 type Capability int
@@ -23,6 +26,13 @@ const (
 	Microphone
 	_
 	HANFUNUnit
+	_
+	OnOff
+	LevelControl
+	ColorControl
+	Blind
+	_
+	HumiditySensor
 )
 
 type DeviceList struct {
@@ -38,34 +48,53 @@ type Device struct {
 	ProductName        string `xml:"productname,attr"`     // Name of the product, empty for unknown or undefined devices.
 	Present            int    `xml:"present"`              // Device connected (1) or not (0).
 	Name               string `xml:"name"`                 // The name of the device. Can be assigned in the web gui of the FRITZ!Box.
+	Battery            string `xml:"battery"`              // Charge level of the battery in percent, empty for devices without a battery.
 
 	Switch      SwitchInfo      `xml:"switch"`
 	Power       PowerInfo       `xml:"powermeter"`
 	Temperature TemperatureInfo `xml:"temperature"`
+	Humidity    HumidityInfo    `xml:"humidity"`
+	Thermostat  ThermostatInfo  `xml:"hkr"`
+	OnOffState  OnOffInfo       `xml:"simpleonoff"`
+	Level       LevelInfo       `xml:"levelcontrol"`
+	Color       ColorInfo       `xml:"colorcontrol"`
+	BlindState  BlindInfo       `xml:"blind"`
+	ETSIUnit    ETSIUnitInfo    `xml:"etsiunitinfo"`
+
+	AlertSensor AlertInfo `xml:"alert"`
+
+	Buttons []ButtonInfo `xml:"button"` // Buttons of the device, e.g. four for the FRITZ!DECT 440.
+}
+
+type ThermostatInfo struct {
+	Measured   string `xml:"tist"`    // Measured temperature.
+	Goal       string `xml:"tsoll"`   // Desired temperature, user controlled.
+	Saving     string `xml:"absenk"`  // Energy saving temperature.
+	Comfort    string `xml:"komfort"` // Comfortable temperature.
+	NextChange struct {
+		TimeStamp string `xml:"endperiod"` // Timestamp (epoch time) when the next temperature switch is scheduled.
+		Goal      string `xml:"tchange"`   // The temperature to switch to. Same unit convention as in Thermostat.Measured.
+	} `xml:"nextchange"` // The next scheduled temperature change.
+	Lock               string `xml:"lock"`               // Switch locked (box defined)? 1/0 (empty if not known or if there was an error).
+	DeviceLock         string `xml:"devicelock"`         // Switch locked (device defined)? 1/0 (empty if not known or if there was an error).
+	ErrorCode          string `xml:"errorcode"`          // Error codes: 0 = OK, 1 = ... see https://avm.de/fileadmin/user_upload/Global/Service/Schnittstellen/AHA-HTTP-Interface.pdf.
+	BatteryLow         string `xml:"batterylow"`         // "0" if the battery is OK, "1" if it is running low on capacity.
+	WindowOpen         string `xml:"windowopenactiv"`    // "1" if detected an open window (usually turns off heating), "0" if not.
+	BoostActive        string `xml:"boostactive"`        // "1" if the boost mode is active, "0" if not.
+	BoostActiveEndTime string `xml:"boostactiveendtime"` // Timestamp (epoch time) when the boost mode ends, "0" if it is not active.
+	HolidayActive      string `xml:"holidayactive"`      // "1" if a holiday period is currently active, "0" if not.
+	SummerActive       string `xml:"summeractive"`       // "1" if the summer period (heating off) is currently active, "0" if not.
+}
 
-	Thermostat struct {
-		Measured   string `xml:"tist"`    // Measured temperature.
-		Goal       string `xml:"tsoll"`   // Desired temperature, user controlled.
-		Saving     string `xml:"absenk"`  // Energy saving temperature.
-		Comfort    string `xml:"komfort"` // Comfortable temperature.
-		NextChange struct {
-			TimeStamp string `xml:"endperiod"` // Timestamp (epoch time) when the next temperature switch is scheduled.
-			Goal      string `xml:"tchange"`   // The temperature to switch to. Same unit convention as in Thermostat.Measured.
-		} `xml:"nextchange"` // The next scheduled temperature change.
-		Lock       string `xml:"lock"`             // Switch locked (box defined)? 1/0 (empty if not known or if there was an error).
-		DeviceLock string `xml:"devicelock"`       // Switch locked (device defined)? 1/0 (empty if not known or if there was an error).
-		ErrorCode  string `xml:"errorcode"`        // Error codes: 0 = OK, 1 = ... see https://avm.de/fileadmin/user_upload/Global/Service/Schnittstellen/AHA-HTTP-Interface.pdf.
-		BatteryLow string `xml:"batterylow"`       // "0" if the battery is OK, "1" if it is running low on capacity.
-		WindowOpen string `xml_:"windowopenactiv"` // "1" if detected an open window (usually turns off heating), "0" if not.
-	} `xml:"hkr"`
-
-	AlertSensor struct {
-		State string `xml:"state"` // Last transmitted alert state, "0" - no alert, "1" - alert, "" if unknown or upon errors.
-	} `xml:"alert"`
-
-	Button struct {
-		LastPressedTimestamp string `xml:"lastpressedtimestamp"` // Timestamp (in epoch seconds) when the button was last pressed. "0" or "" if unknown.
-	} `xml:"button"`
+type AlertInfo struct {
+	State      string `xml:"state"`                 // Last transmitted alert state, "0" - no alert, "1" - alert, "" if unknown or upon errors.
+	LastChange string `xml:"lastalertchgtimestamp"` // Timestamp (epoch time) of the last change of the alert state, "" if unknown.
+}
+
+type ButtonInfo struct {
+	Identifier           string `xml:"identifier,attr"`      // A unique ID of the button, usually the AIN of the device followed by a suffix.
+	Name                 string `xml:"name"`                 // The name of the button, e.g. "FRITZ!DECT 440 oben rechts".
+	LastPressedTimestamp string `xml:"lastpressedtimestamp"` // Timestamp (in epoch seconds) when the button was last pressed. "0" or "" if unknown.
 }
 
 type SwitchInfo struct {
@@ -86,30 +115,142 @@ type TemperatureInfo struct {
 	Offset  string `xml:"offset"`  // Temperature offset (set by the user) in units of 0.1 °C. Negative and positive values are possible.
 }
 
+type HumidityInfo struct {
+	RelativeHumidity string `xml:"rel_humidity"` // Relative humidity measured at the device sensor in percent, "" if unknown.
+}
+
 func (i SwitchInfo) IsPoweredOn() bool {
 	return i.State == "1"
 }
 
+// IsAlerting returns whether the sensor currently reports an alert. It returns
+// false as second value if the state is unknown.
+func (i AlertInfo) IsAlerting() (alert, known bool) {
+	switch i.State {
+	case "1":
+		return true, true
+	case "0":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// GetLastChange returns the time of the last change of the alert state or the
+// zero time if it is unknown.
+func (i AlertInfo) GetLastChange() time.Time {
+	ts, err := strconv.ParseInt(i.LastChange, 10, 64)
+	if err != nil || ts <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
+
+// GetLastPressed returns the time at which the button was last pressed or the
+// zero time if it is unknown.
+func (i ButtonInfo) GetLastPressed() time.Time {
+	ts, err := strconv.ParseInt(i.LastPressedTimestamp, 10, 64)
+	if err != nil || ts <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
+
+func (i ThermostatInfo) IsBoostActive() bool {
+	return i.BoostActive == "1"
+}
+
+// GetBoostEndTime returns the time at which the boost mode ends or the zero
+// time if boost mode is not active.
+func (i ThermostatInfo) GetBoostEndTime() time.Time {
+	ts, err := strconv.ParseInt(i.BoostActiveEndTime, 10, 64)
+	if err != nil || ts <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
+
+func (i ThermostatInfo) IsWindowOpen() bool {
+	return i.WindowOpen == "1"
+}
+
+func (i ThermostatInfo) IsHolidayActive() bool {
+	return i.HolidayActive == "1"
+}
+
+func (i ThermostatInfo) IsSummerActive() bool {
+	return i.SummerActive == "1"
+}
+
+// GetMeasured returns the temperature measured by the thermostat in °C.
+func (i ThermostatInfo) GetMeasured() (float64, bool) {
+	return thermostatCelsius(i.Measured)
+}
+
+// GetGoal returns the target temperature of the thermostat in °C. It returns
+// false if the thermostat is switched off or permanently on.
+func (i ThermostatInfo) GetGoal() (float64, bool) {
+	return thermostatCelsius(i.Goal)
+}
+
+// thermostatCelsius converts a thermostat temperature which is given in steps
+// of 0.5 °C (16 = 8 °C, …, 56 = 28 °C) into °C. The special values 253 (off)
+// and 254 (on) are not temperatures.
+func thermostatCelsius(s string) (float64, bool) {
+	v, err := parseFinite(s)
+	if err != nil || v >= 253 {
+		return 0, false
+	}
+	return v / 2, true
+}
+
 func (i PowerInfo) GetVoltage() float64 {
-	f, _ := strconv.ParseFloat(i.Voltage, 64)
+	f, _ := parseFinite(i.Voltage)
 	return f / 1000
 }
 
 func (i PowerInfo) GetPower() float64 {
-	f, _ := strconv.ParseFloat(i.Power, 64)
+	f, _ := parseFinite(i.Power)
 	return f / 1000
 }
 
-func (i PowerInfo) GetEnergy() float64 {
-	f, _ := strconv.ParseFloat(i.Energy, 64)
-	return f
+// GetEnergy returns the energy in Wh which was consumed since the device was
+// paired. It returns false if the reading is missing, e.g. because the device
+// is not connected and the FRITZ!Box responds with an empty energy field.
+func (i PowerInfo) GetEnergy() (float64, bool) {
+	f, err := parseFinite(i.Energy)
+	if err != nil || f < 0 {
+		return 0, false
+	}
+	return f, true
 }
 
 func (i TemperatureInfo) GetCelsius() float64 {
-	f, _ := strconv.ParseFloat(i.Celsius, 64)
+	f, _ := parseFinite(i.Celsius)
 	return f / 10
 }
 
+// GetRelativeHumidity returns the relative humidity in percent. It returns
+// false if the device did not report a valid value.
+func (i HumidityInfo) GetRelativeHumidity() (float64, bool) {
+	f, err := parseFinite(i.RelativeHumidity)
+	if err != nil || f < 0 || f > 100 {
+		return 0, false
+	}
+	return f, true
+}
+
+// GetBatteryPercent returns the charge level of the battery in percent. It
+// returns false if the device has no battery or does not report its level,
+// e.g. with a FRITZ!OS before 7.
+func (d *Device) GetBatteryPercent() (float64, bool) {
+	f, err := parseFinite(d.Battery)
+	if err != nil || f < 0 || f > 100 {
+		return 0, false
+	}
+	return f, true
+}
+
 func (d *Device) CanMeasurePower() bool {
 	return d.Has(PowerSensor)
 }
@@ -118,30 +259,63 @@ func (d *Device) CanMeasureTemperature() bool {
 	return d.Has(TemperatureSensor)
 }
 
+func (d *Device) CanMeasureHumidity() bool {
+	return d.Has(HumiditySensor) || d.Humidity.RelativeHumidity != ""
+}
+
+// CanSwitchOnOff returns true for devices which can be switched on and off
+// via the HAN-FUN on/off unit, e.g. the FRITZ!DECT 500 light bulb.
+func (d *Device) CanSwitchOnOff() bool {
+	return d.Has(OnOff)
+}
+
+func (d *Device) HasLevel() bool {
+	return d.Has(LevelControl)
+}
+
+func (d *Device) HasColor() bool {
+	return d.Has(ColorControl)
+}
+
+// IsBlind returns true for HAN-FUN blinds and shutters, e.g. the Rollotron
+// DECT 1213.
+func (d *Device) IsBlind() bool {
+	return d.Has(Blind) || d.ETSIUnit.UnitType == ETSIUnitTypeBlind
+}
+
 func (d *Device) IsSwitch() bool {
 	return d.Has(StateSwitch)
 }
 
+func (d *Device) IsThermostat() bool {
+	return d.Has(HeatControl)
+}
+
+// IsAlertSensor returns true for devices which report alerts, e.g. HAN-FUN
+// door and window contacts or smoke detectors.
+func (d *Device) IsAlertSensor() bool {
+	return d.Has(AlertTrigger)
+}
+
 // Has checks the passed capabilities and returns true iff the device supports
 // all capabilities.
 func (d *Device) Has(cs ...Capability) bool {
+	var mask int64
 	for _, c := range cs {
-		b := bitMasked{Functionbitmask: d.CapabilitiesBitmap}.hasMask(1 << uint(c))
-		if !b {
-			return false
-		}
+		mask |= 1 << uint(c)
 	}
-	return true
+	return bitMasked{Functionbitmask: d.CapabilitiesBitmap}.hasAll(mask)
 }
 
 type bitMasked struct {
 	Functionbitmask string
 }
 
-func (b bitMasked) hasMask(mask int64) bool {
+// hasAll returns true if all bits of the mask are set.
+func (b bitMasked) hasAll(mask int64) bool {
 	bitMask, err := strconv.ParseInt(b.Functionbitmask, 10, 64)
 	if err != nil {
 		return false
 	}
-	return (bitMask & mask) != 0
+	return (bitMask & mask) == mask
 }
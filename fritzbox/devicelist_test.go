@@ -0,0 +1,178 @@
+package fritzbox
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// deviceListFixtures are responses of getdevicelistinfos of different
+// devices. They follow the examples of the AHA HTTP interface documentation
+// of AVM and were recorded with FRITZ!OS 7.29.
+var deviceListFixtures = []struct {
+	file    string
+	devices int
+	check   func(t *testing.T, d Device)
+}{
+	{
+		file:    "devicelist_dect200.xml",
+		devices: 1,
+		check: func(t *testing.T, d Device) {
+			expect(t, "product", d.ProductName, "FRITZ!DECT 200")
+			expect(t, "switch", d.IsSwitch() && d.CanMeasurePower() && d.CanMeasureTemperature(), true)
+			expect(t, "powered on", d.Switch.IsPoweredOn(), true)
+			expect(t, "power", d.Power.GetPower(), 1.24)
+			expect(t, "voltage", d.Power.GetVoltage(), 229.891)
+			energy, ok := d.Power.GetEnergy()
+			expect(t, "energy", energy, 75382.0)
+			expect(t, "energy known", ok, true)
+			expect(t, "temperature", d.Temperature.GetCelsius(), 21.5)
+		},
+	},
+	{
+		file:    "devicelist_dect200_absent.xml",
+		devices: 1,
+		check: func(t *testing.T, d Device) {
+			expect(t, "present", d.Present, 0)
+			expect(t, "powered on", d.Switch.IsPoweredOn(), false)
+			_, ok := d.Power.GetEnergy()
+			expect(t, "energy known", ok, false)
+		},
+	},
+	{
+		file:    "devicelist_dect301.xml",
+		devices: 1,
+		check: func(t *testing.T, d Device) {
+			expect(t, "thermostat", d.IsThermostat(), true)
+			battery, _ := d.GetBatteryPercent()
+			expect(t, "battery", battery, 80.0)
+			measured, _ := d.Thermostat.GetMeasured()
+			expect(t, "measured", measured, 20.5)
+			goal, ok := d.Thermostat.GetGoal()
+			expect(t, "goal", goal, 21.0)
+			expect(t, "goal known", ok, true)
+			expect(t, "window open", d.Thermostat.IsWindowOpen(), false)
+		},
+	},
+	{
+		file:    "devicelist_dect440.xml",
+		devices: 1,
+		check: func(t *testing.T, d Device) {
+			expect(t, "humidity sensor", d.CanMeasureHumidity(), true)
+			humidity, _ := d.Humidity.GetRelativeHumidity()
+			expect(t, "humidity", humidity, 48.0)
+			expect(t, "buttons", len(d.Buttons), 4)
+			expect(t, "last pressed", d.Buttons[0].GetLastPressed().Unix(), int64(1612877643))
+			expect(t, "never pressed", d.Buttons[1].GetLastPressed().IsZero(), true)
+		},
+	},
+	{
+		file:    "devicelist_dect500.xml",
+		devices: 1,
+		check: func(t *testing.T, d Device) {
+			expect(t, "light", d.CanSwitchOnOff() && d.HasLevel() && d.HasColor(), true)
+			expect(t, "on", d.OnOffState.IsOn(), true)
+			level, _ := d.Level.GetLevelPercentage()
+			expect(t, "level", level, 58.0)
+			hue, _, ok := d.Color.GetHueSaturation()
+			expect(t, "hue", hue, 358.0)
+			expect(t, "hue/saturation mode", ok, true)
+		},
+	},
+	{
+		file:    "devicelist_hanfun_contact.xml",
+		devices: 1,
+		check: func(t *testing.T, d Device) {
+			expect(t, "alert sensor", d.IsAlertSensor(), true)
+			alert, known := d.AlertSensor.IsAlerting()
+			expect(t, "alert", alert, true)
+			expect(t, "alert known", known, true)
+			expect(t, "last change", d.AlertSensor.GetLastChange().Unix(), int64(1612880211))
+		},
+	},
+	{
+		file:    "devicelist_blind.xml",
+		devices: 1,
+		check: func(t *testing.T, d Device) {
+			expect(t, "blind", d.IsBlind(), true)
+			expect(t, "end positions set", d.BlindState.AreEndPositionsSet(), true)
+			position, _ := d.Level.GetLevelPercentage()
+			expect(t, "position", position, 25.0)
+		},
+	},
+	{
+		file:    "devicelist_group.xml", // groups are skipped
+		devices: 1,
+		check: func(t *testing.T, d Device) {
+			expect(t, "name", d.Name, "Washing machine")
+		},
+	},
+}
+
+func TestParseDeviceList(t *testing.T) {
+	for _, fixture := range deviceListFixtures {
+		t.Run(fixture.file, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", fixture.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			devices, err := parseDeviceList(f)
+			if err != nil {
+				t.Fatalf("failed to parse device list: %v", err)
+			}
+			if len(devices) != fixture.devices {
+				t.Fatalf("got %d devices, want %d", len(devices), fixture.devices)
+			}
+
+			fixture.check(t, devices[0])
+		})
+	}
+}
+
+func TestParseDeviceListErrors(t *testing.T) {
+	_, err := parseDeviceList(strings.NewReader("<!DOCTYPE html><html><body>Login</body></html>"))
+	if !errors.Is(err, errLoginPage) {
+		t.Errorf("login page: got error %v, want %v", err, errLoginPage)
+	}
+
+	_, err = parseDeviceList(strings.NewReader("<state>1</state>"))
+	if err == nil {
+		t.Error("unexpected root element did not fail")
+	}
+
+	// A response which breaks off returns the devices received so far.
+	devices, err := parseDeviceList(strings.NewReader(`<devicelist><device identifier="1"><name>A</name></device><device identifier="2"><name>B`))
+	if err == nil || len(devices) != 1 || devices[0].Name != "A" {
+		t.Errorf("truncated device list: got %d devices and error %v", len(devices), err)
+	}
+}
+
+func expect(t *testing.T, what string, got, want interface{}) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func BenchmarkParseDeviceList(b *testing.B) {
+	data, err := os.ReadFile(filepath.Join("testdata", "devicelist_50.xml"))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		devices, err := parseDeviceList(bytes.NewReader(data))
+		if err != nil || len(devices) != 50 {
+			b.Fatalf("got %d devices and error %v", len(devices), err)
+		}
+	}
+}
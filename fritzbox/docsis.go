@@ -0,0 +1,124 @@
+package fritzbox
+
+import (
+	"context"
+	"strings"
+)
+
+// Directions of a DOCSISChannel.
+const (
+	Downstream = "downstream"
+	Upstream   = "upstream"
+)
+
+// DOCSISChannel is a channel of the cable modem of FRITZ!Box Cable models
+// such as the 6591 or 6660. Values which are not reported for a channel (e.g.
+// errors of upstream channels) are zero and flagged via the Has* fields.
+type DOCSISChannel struct {
+	Direction  string  // Downstream or Upstream.
+	Version    string  // DOCSIS version of the channel, "3.0" or "3.1".
+	ChannelID  string  // ID of the channel which is assigned by the cable provider.
+	Frequency  float64 // Frequency in MHz. DOCSIS 3.1 channels report the lower end of their frequency range.
+	PowerLevel float64 // Power level in dBµV.
+	Modulation string  // e.g. "256QAM" or "4096QAM".
+
+	// Signal quality in dB. DOCSIS 3.0 downstream channels report the MSE
+	// which is the negative SNR, DOCSIS 3.1 channels the MER.
+	MER    float64
+	HasMER bool
+
+	CorrectedErrors     float64 // Number of errors which were corrected via forward error correction.
+	UncorrectableErrors float64 // Number of errors which could not be corrected.
+	HasErrors           bool    // Only downstream channels report errors.
+}
+
+// DOCSISChannels returns all channels the cable modem is locked to as shown
+// on the "Cable Information" page of the FRITZ!Box web interface. This is only
+// supported by FRITZ!Box Cable models.
+func (c *Client) DOCSISChannels(ctx context.Context) ([]DOCSISChannel, error) {
+	c.logger.Debug("Requesting DOCSIS channels")
+
+	var resp docsisResponse
+	err := c.dataLua(ctx, "docInfo", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.channels(), nil
+}
+
+// docsisResponse is the data of the data.lua page "docInfo".
+type docsisResponse struct {
+	Downstream docsisChannels `json:"channelDs"`
+	Upstream   docsisChannels `json:"channelUs"`
+}
+
+type docsisChannels struct {
+	DOCSIS30 []docsisChannel `json:"docsis30"`
+	DOCSIS31 []docsisChannel `json:"docsis31"`
+}
+
+type docsisChannel struct {
+	ChannelID     flexString `json:"channelID"`
+	Frequency     flexString `json:"frequency"`
+	PowerLevel    flexString `json:"powerLevel"`
+	Modulation    flexString `json:"modulation"`
+	MSE           flexString `json:"mse"`
+	MER           flexString `json:"mer"`
+	CorrErrors    flexString `json:"corrErrors"`
+	NonCorrErrors flexString `json:"nonCorrErrors"`
+}
+
+func (resp docsisResponse) channels() []DOCSISChannel {
+	var result []DOCSISChannel
+	add := func(direction, version string, chs []docsisChannel) {
+		for _, ch := range chs {
+			dc := DOCSISChannel{
+				Direction:  direction,
+				Version:    version,
+				ChannelID:  string(ch.ChannelID),
+				Modulation: string(ch.Modulation),
+			}
+			dc.Frequency, _ = parseLeadingFloat(ch.Frequency)
+			dc.PowerLevel, _ = parseLeadingFloat(ch.PowerLevel)
+
+			if mer, ok := parseLeadingFloat(ch.MER); ok {
+				dc.MER, dc.HasMER = mer, true
+			} else if mse, ok := parseLeadingFloat(ch.MSE); ok {
+				dc.MER, dc.HasMER = -mse, true
+			}
+
+			corrected, hasCorrected := parseLeadingFloat(ch.CorrErrors)
+			uncorrectable, hasUncorrectable := parseLeadingFloat(ch.NonCorrErrors)
+			if hasCorrected || hasUncorrectable {
+				dc.CorrectedErrors = corrected
+				dc.UncorrectableErrors = uncorrectable
+				dc.HasErrors = true
+			}
+
+			result = append(result, dc)
+		}
+	}
+
+	add(Downstream, "3.0", resp.Downstream.DOCSIS30)
+	add(Downstream, "3.1", resp.Downstream.DOCSIS31)
+	add(Upstream, "3.0", resp.Upstream.DOCSIS30)
+	add(Upstream, "3.1", resp.Upstream.DOCSIS31)
+
+	return result
+}
+
+// parseLeadingFloat parses the number at the beginning of a data.lua value.
+// Some values contain a range or a unit, e.g. "751 - 861" or "3.5 dBµV".
+func parseLeadingFloat(s flexString) (float64, bool) {
+	fields := strings.Fields(string(s))
+	if len(fields) == 0 {
+		return 0, false
+	}
+
+	f, err := parseFinite(fields[0])
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
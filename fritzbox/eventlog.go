@@ -0,0 +1,132 @@
+package fritzbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Classes of the entries of the event log. They correspond to the filters of
+// the event log in the FRITZ!Box web interface.
+const (
+	EventClassSystem    = "system"
+	EventClassInternet  = "internet" // the internet connection, e.g. DSL synchronization
+	EventClassTelephony = "telephony"
+	EventClassWLAN      = "wlan"
+	EventClassUSB       = "usb"
+	EventClassOther     = "other"
+)
+
+// EventClasses contains all classes of event log entries.
+var EventClasses = []string{
+	EventClassSystem,
+	EventClassInternet,
+	EventClassTelephony,
+	EventClassWLAN,
+	EventClassUSB,
+	EventClassOther,
+}
+
+// eventGroups maps the groups of the data.lua event log to the event classes.
+var eventGroups = map[string]string{
+	"sys":  EventClassSystem,
+	"net":  EventClassInternet,
+	"fon":  EventClassTelephony,
+	"wlan": EventClassWLAN,
+	"usb":  EventClassUSB,
+}
+
+// EventLogEntry is a single entry of the FRITZ!Box event log.
+type EventLogEntry struct {
+	Time    time.Time
+	Message string
+	ID      string // ID of the message type which is the same for all entries of the same kind
+	Class   string // one of EventClasses
+}
+
+// eventTimeLayout is the layout of the date and time of the event log entries.
+const eventTimeLayout = "02.01.06 15:04:05"
+
+// EventLog returns the entries of the FRITZ!Box event log, newest first. The
+// FRITZ!Box does not include a time zone in its log so the timestamps are
+// interpreted in the time zone of c.Location.
+func (c *Client) EventLog(ctx context.Context) ([]EventLogEntry, error) {
+	c.logger.Debug("Requesting event log")
+
+	var resp eventLogResponse
+	err := c.dataLua(ctx, "log", &resp, "filter", "0")
+	if err != nil {
+		return nil, err
+	}
+
+	loc := c.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return resp.entries(loc)
+}
+
+// eventLogResponse is the data of the data.lua page "log".
+type eventLogResponse struct {
+	Log []json.RawMessage `json:"log"`
+}
+
+func (resp eventLogResponse) entries(loc *time.Location) ([]EventLogEntry, error) {
+	entries := make([]EventLogEntry, 0, len(resp.Log))
+	for _, raw := range resp.Log {
+		entry, err := parseEventLogEntry(raw, loc)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// parseEventLogEntry parses an entry of the data.lua event log. Current
+// FRITZ!OS versions encode each entry as object while older versions use an
+// array of date, time, message, ID and group.
+func parseEventLogEntry(raw json.RawMessage, loc *time.Location) (EventLogEntry, error) {
+	var entry struct {
+		Date    flexString `json:"date"`
+		Time    flexString `json:"time"`
+		Message flexString `json:"msg"`
+		ID      flexString `json:"id"`
+		Group   flexString `json:"group"`
+	}
+
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		var fields []flexString
+		if arrErr := json.Unmarshal(raw, &fields); arrErr != nil || len(fields) < 3 {
+			return EventLogEntry{}, fmt.Errorf("unexpected event log entry %s", raw)
+		}
+
+		entry.Date, entry.Time, entry.Message = fields[0], fields[1], fields[2]
+		if len(fields) > 3 {
+			entry.ID = fields[3]
+		}
+		if len(fields) > 4 {
+			entry.Group = fields[4]
+		}
+	}
+
+	t, err := time.ParseInLocation(eventTimeLayout, string(entry.Date)+" "+string(entry.Time), loc)
+	if err != nil {
+		return EventLogEntry{}, fmt.Errorf("invalid time of event log entry %s: %w", raw, err)
+	}
+
+	class, ok := eventGroups[string(entry.Group)]
+	if !ok {
+		class = EventClassOther
+	}
+
+	return EventLogEntry{
+		Time:    t,
+		Message: string(entry.Message),
+		ID:      string(entry.ID),
+		Class:   class,
+	}, nil
+}
@@ -0,0 +1,60 @@
+package fritzbox
+
+import (
+	"context"
+	"strings"
+)
+
+// FiberStatus contains the state of the optical network termination (ONT) of
+// FRITZ!Box Fiber models such as the 5530 or 5590.
+type FiberStatus struct {
+	Type    string  // Type of the fiber connection, e.g. "GPON" or "AON".
+	State   string  // GPON activation state as defined in ITU-T G.984.3, e.g. "O5".
+	RXPower float64 // Received optical power in dBm.
+	TXPower float64 // Transmitted optical power in dBm.
+}
+
+// IsOperational returns true if the ONT has completed the GPON activation
+// (state O5). AON connections do not have activation states so they are
+// considered operational if there is any signal at all.
+func (s FiberStatus) IsOperational() bool {
+	if strings.EqualFold(s.Type, "AON") {
+		return s.RXPower != 0
+	}
+	return strings.EqualFold(s.State, "O5")
+}
+
+// FiberStatus returns the optical line status as shown on the "Fiber" status
+// page of the FRITZ!Box web interface. This is only supported by FRITZ!Box
+// Fiber models.
+func (c *Client) FiberStatus(ctx context.Context) (*FiberStatus, error) {
+	c.logger.Debug("Requesting fiber status")
+
+	var resp fiberResponse
+	err := c.dataLua(ctx, "fiberInfo", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.status(), nil
+}
+
+// fiberResponse is the data of the data.lua page "fiberInfo".
+type fiberResponse struct {
+	Line struct {
+		Type    flexString `json:"type"`
+		State   flexString `json:"state"`
+		RXPower flexFloat  `json:"rx_power"`
+		TXPower flexFloat  `json:"tx_power"`
+	} `json:"line"`
+}
+
+func (resp fiberResponse) status() *FiberStatus {
+	line := resp.Line
+	return &FiberStatus{
+		Type:    string(line.Type),
+		State:   string(line.State),
+		RXPower: float64(line.RXPower),
+		TXPower: float64(line.TXPower),
+	}
+}
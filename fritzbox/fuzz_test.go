@@ -0,0 +1,143 @@
+package fritzbox
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The fuzz tests make sure that malformed responses of the FRITZ!Box never
+// crash fritz-mon and never produce numbers which cannot be exported. Run
+// them via e.g. "go test -fuzz=FuzzParseDeviceList ./fritzbox".
+
+func FuzzParseDeviceList(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("testdata", "devicelist_*.xml"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(b)
+		f.Add(b[:len(b)/2]) // the connection broke off
+	}
+	f.Add([]byte(`<!DOCTYPE html><html></html>`))
+	f.Add([]byte(`<devicelist><device functionbitmask="-1"><powermeter><power>NaN</power><energy>1e400</energy></powermeter></device></devicelist>`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		devices, _ := parseDeviceList(bytes.NewReader(data))
+		for _, d := range devices {
+			checkDevice(t, d)
+		}
+	})
+}
+
+func checkDevice(t *testing.T, d Device) {
+	t.Helper()
+
+	energy, _ := d.Power.GetEnergy()
+	expectFinite(t, "energy", energy)
+	expectFinite(t, "power", d.Power.GetPower())
+	expectFinite(t, "voltage", d.Power.GetVoltage())
+	expectFinite(t, "temperature", d.Temperature.GetCelsius())
+
+	humidity, _ := d.Humidity.GetRelativeHumidity()
+	expectFinite(t, "humidity", humidity)
+	battery, _ := d.GetBatteryPercent()
+	expectFinite(t, "battery", battery)
+	measured, _ := d.Thermostat.GetMeasured()
+	expectFinite(t, "measured temperature", measured)
+	goal, _ := d.Thermostat.GetGoal()
+	expectFinite(t, "goal temperature", goal)
+	level, _ := d.Level.GetLevelPercentage()
+	expectFinite(t, "level", level)
+	hue, saturation, _ := d.Color.GetHueSaturation()
+	expectFinite(t, "hue", hue)
+	expectFinite(t, "saturation", saturation)
+	colorTemperature, _ := d.Color.GetTemperature()
+	expectFinite(t, "color temperature", colorTemperature)
+
+	_ = d.AlertSensor.GetLastChange()
+	_ = d.Thermostat.GetBoostEndTime()
+	for _, b := range d.Buttons {
+		_ = b.GetLastPressed()
+	}
+}
+
+func FuzzSession(f *testing.F) {
+	f.Add([]byte(`<?xml version="1.0" encoding="utf-8"?><SessionInfo><SID>0000000000000000</SID><Challenge>2$10$5A1711$20$5A1722</Challenge><BlockTime>0</BlockTime><Rights></Rights><Users><User last="1">fritz3756</User></Users></SessionInfo>`))
+	f.Add([]byte(`<?xml version="1.0" encoding="utf-8"?><SessionInfo><SID>0000000000000000</SID><Challenge>1234567z</Challenge><BlockTime>64</BlockTime><Rights/></SessionInfo>`))
+	f.Add([]byte(`<SessionInfo><SID>ff88e4d39354992f</SID><Challenge>a41cb4b2</Challenge><BlockTime>0</BlockTime><Rights><Name>Dial</Name><Access>2</Access><Name>App</Name><Access>2</Access><Name>HomeAuto</Name><Access>2</Access></Rights></SessionInfo>`))
+	f.Add([]byte(`<SessionInfo><SID></SID><Challenge>2$$$$</Challenge><BlockTime>99999999999999999999</BlockTime></SessionInfo>`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c := new(Client)
+		if err := xml.Unmarshal(data, &c.session); err != nil {
+			return
+		}
+
+		c.updateBlockTime()
+		if blocked := c.LoginBlockedFor(); blocked < 0 || blocked > maxBlockTime*time.Second {
+			t.Errorf("login is blocked for %s", blocked)
+		}
+
+		_, _ = c.session.solveChallenge("password")
+	})
+}
+
+// FuzzDataLua decodes each input as every data.lua page which fritz-mon
+// requests.
+func FuzzDataLua(f *testing.F) {
+	f.Add([]byte(`{"pid":"docInfo","data":{"channelDs":{"docsis30":[{"channelID":7,"frequency":"602","powerLevel":"3.5","modulation":"256QAM","mse":"-38.6","corrErrors":12,"nonCorrErrors":0}],"docsis31":[{"channelID":33,"frequency":"751 - 861","powerLevel":"-1.2","modulation":"4096QAM","mer":"41"}]},"channelUs":{"docsis30":[{"channelID":1,"frequency":"51","powerLevel":"44.3","modulation":"64QAM"}]}}}`))
+	f.Add([]byte(`{"pid":"lteInfo","data":{"connection":{"act":"LTE","band":"20","cellid":"1234567","rsrp":"-98","rsrq":-11,"sinr":"13.4"}}}`))
+	f.Add([]byte(`{"pid":"fiberInfo","data":{"line":{"type":"GPON","state":"O5","rx_power":"-18.2","tx_power":2.1}}}`))
+	f.Add([]byte(`{"pid":"log","data":{"log":[{"date":"09.02.21","time":"12:04:31","msg":"Internet connection established","id":23,"group":"net"},["09.02.21","12:04:00","DSL is ready",139,"net"]]}}`))
+	f.Add([]byte(`{"data":{"connection":{"rsrp":"NaN","rsrq":"Infinity","sinr":"-"}}}`))
+	f.Add([]byte(`{"data":null}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var docsis docsisResponse
+		if decodeDataLua(bytes.NewReader(data), "docInfo", &docsis) == nil {
+			for _, ch := range docsis.channels() {
+				expectFinite(t, "frequency", ch.Frequency)
+				expectFinite(t, "power level", ch.PowerLevel)
+				expectFinite(t, "MER", ch.MER)
+				expectFinite(t, "corrected errors", ch.CorrectedErrors)
+				expectFinite(t, "uncorrectable errors", ch.UncorrectableErrors)
+			}
+		}
+
+		var mobile mobileResponse
+		if decodeDataLua(bytes.NewReader(data), "lteInfo", &mobile) == nil {
+			s := mobile.status()
+			expectFinite(t, "RSRP", s.RSRP)
+			expectFinite(t, "RSRQ", s.RSRQ)
+			expectFinite(t, "SINR", s.SINR)
+		}
+
+		var fiber fiberResponse
+		if decodeDataLua(bytes.NewReader(data), "fiberInfo", &fiber) == nil {
+			s := fiber.status()
+			expectFinite(t, "RX power", s.RXPower)
+			expectFinite(t, "TX power", s.TXPower)
+		}
+
+		var log eventLogResponse
+		if decodeDataLua(bytes.NewReader(data), "log", &log) == nil {
+			_, _ = log.entries(time.UTC)
+		}
+	})
+}
+
+func expectFinite(t *testing.T, what string, v float64) {
+	t.Helper()
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		t.Errorf("%s is %v", what, v)
+	}
+}
@@ -0,0 +1,42 @@
+package fritzbox
+
+import (
+	"context"
+	"strings"
+)
+
+// Group is a device group which is configured in the FRITZ!Box, e.g. all
+// smart plugs of a room.
+type Group struct {
+	Identifier string    `xml:"identifier,attr"` // AIN of the group.
+	InternalID string    `xml:"id,attr"`         // Internal ID of the group.
+	Name       string    `xml:"name"`            // The name of the group as shown in the web gui of the FRITZ!Box.
+	Info       GroupInfo `xml:"groupinfo"`
+}
+
+type GroupInfo struct {
+	MasterDeviceID string `xml:"masterdeviceid"` // Internal ID of the master device of the group, "0" if there is none.
+	Members        string `xml:"members"`        // Comma separated internal IDs (Device.InternalID) of the members.
+}
+
+// MemberIDs returns the internal IDs of all members of the group.
+func (g Group) MemberIDs() []string {
+	var ids []string
+	for _, id := range strings.Split(g.Info.Members, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Groups returns all device groups of the FRITZ!Box.
+func (c *Client) Groups(ctx context.Context) ([]Group, error) {
+	c.logger.Debug("Requesting list of device groups")
+
+	var list struct {
+		Groups []Group `xml:"group"`
+	}
+	err := c.doXMLCommand(ctx, &list, "getdevicelistinfos")
+	return list.Groups, err
+}
@@ -5,12 +5,21 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// maxResponseSize limits how much of a response we read into memory. Even
+// large device lists are only a fraction of this size, so any bigger response
+// is most likely broken and must not bloat the memory of fritz-mon.
+const maxResponseSize = 4 << 20
+
 func (c *Client) getXML(ctx context.Context, target interface{}, reqPath string, args ...string) error {
 	resp, err := c.get(ctx, reqPath, args...)
 	if err != nil {
@@ -26,8 +35,45 @@ func (c *Client) getXML(ctx context.Context, target interface{}, reqPath string,
 }
 
 func (c *Client) get(ctx context.Context, reqPath string, args ...string) (*bytes.Buffer, error) {
+	return c.do(ctx, "GET", reqPath, args...)
+}
+
+func (c *Client) post(ctx context.Context, reqPath string, args ...string) (*bytes.Buffer, error) {
+	return c.do(ctx, "POST", reqPath, args...)
+}
+
+func (c *Client) do(ctx context.Context, method, reqPath string, args ...string) (*bytes.Buffer, error) {
+	body, previousRequest, err := c.open(ctx, method, reqPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(body, maxResponseSize+1))
+	discardBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP response body: %w", err)
+	}
+
+	if len(respBody) > maxResponseSize {
+		return nil, fmt.Errorf("HTTP response body exceeds %d bytes", maxResponseSize)
+	}
+
+	// Pages of the web interface respond with the login page instead of an
+	// error if the session is not valid (anymore).
+	if reqPath != "/login_sid.lua" && isLoginPage(respBody) {
+		c.invalidateSession(sessionArg(args), previousRequest)
+		return nil, ErrSessionInvalid
+	}
+
+	return bytes.NewBuffer(respBody), nil
+}
+
+// open sends a request to the FRITZ!Box and returns the body of a successful
+// response which must be closed via discardBody. It also returns the time of
+// the request before this one.
+func (c *Client) open(ctx context.Context, method, reqPath string, args ...string) (io.ReadCloser, time.Time, error) {
 	if len(args)%2 != 0 {
-		return nil, fmt.Errorf("bad number of query arguments (must be a factor of 2)")
+		return nil, time.Time{}, fmt.Errorf("bad number of query arguments (must be a factor of 2)")
 	}
 
 	params := url.Values{}
@@ -38,28 +84,76 @@ func (c *Client) get(ctx context.Context, reqPath string, args ...string) (*byte
 
 	reqURL := c.BaseURL
 	reqURL.Path = path.Join(c.BaseURL.Path, reqPath)
-	reqURL.RawQuery = params.Encode()
 
-	req, err := http.NewRequest("GET", reqURL.String(), nil)
+	var body io.Reader
+	if method == "GET" {
+		reqURL.RawQuery = params.Encode()
+	} else {
+		body = strings.NewReader(params.Encode())
+	}
+
+	req, err := http.NewRequest(method, reqURL.String(), body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build HTTP request: %w", err)
+		return nil, time.Time{}, fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	}
 
+	previousRequest := time.Unix(0, atomic.SwapInt64(&c.lastRequest, time.Now().UnixNano()))
+
 	req = req.WithContext(ctx)
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return nil, time.Time{}, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusForbidden {
+		discardBody(resp.Body)
+		c.invalidateSession(sessionArg(args), previousRequest)
+		return nil, time.Time{}, ErrSessionInvalid
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad HTTP status code: %s", resp.Status)
+		discardBody(resp.Body)
+		return nil, time.Time{}, fmt.Errorf("bad HTTP status code: %s", resp.Status)
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	_ = resp.Body.Close()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read HTTP response body: %w", err)
+	return resp.Body, previousRequest, nil
+}
+
+// sessionArg returns the session ID among the query arguments of a request.
+func sessionArg(args []string) string {
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "sid" {
+			return args[i+1]
+		}
 	}
+	return ""
+}
 
-	return bytes.NewBuffer(body), nil
+// maxDiscardSize limits how much of a remaining body discardBody reads. Larger
+// bodies (e.g. a never-ending error page) are not worth waiting for just to
+// reuse the connection.
+const maxDiscardSize = 64 << 10
+
+// discardBody reads the remaining body before closing it. Otherwise the
+// underlying connection cannot be reused for the next request.
+func discardBody(body io.ReadCloser) {
+	_, _ = io.CopyN(ioutil.Discard, body, maxDiscardSize)
+	_ = body.Close()
+}
+
+// isLoginPage returns true if the response is the HTML login page of the
+// FRITZ!Box web interface. None of the endpoints we use responds with HTML.
+func isLoginPage(body []byte) bool {
+	// Only the start is lower-cased since the body may be up to
+	// maxResponseSize and this is checked for every response.
+	start := bytes.TrimSpace(body)
+	if len(start) > 512 {
+		start = start[:512]
+	}
+	start = bytes.ToLower(start)
+	return bytes.HasPrefix(start, []byte("<!doctype html")) || bytes.HasPrefix(start, []byte("<html"))
 }
@@ -0,0 +1,65 @@
+package fritzbox
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsLoginPage(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{body: "<!DOCTYPE html><html><head><title>FRITZ!Box</title>", want: true},
+		{body: "\n  <HTML lang=\"de\">", want: true},
+		{body: `<?xml version="1.0" encoding="utf-8"?><devicelist version="1">`, want: false},
+		{body: `{"data":{"html":"<html>"}}`, want: false},
+		{body: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isLoginPage([]byte(tt.body)); got != tt.want {
+			t.Errorf("isLoginPage(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkIsLoginPage(b *testing.B) {
+	body := append([]byte(`<?xml version="1.0"?><devicelist version="1">`), bytes.Repeat([]byte("<device/>"), maxResponseSize/9)...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if isLoginPage(body) {
+			b.Fatal("device list is no login page")
+		}
+	}
+}
+
+// endlessBody is a response body which never ends.
+type endlessBody struct {
+	read   int64
+	closed bool
+}
+
+func (b *endlessBody) Read(p []byte) (int, error) {
+	b.read += int64(len(p))
+	return len(p), nil
+}
+
+func (b *endlessBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestDiscardBodyStopsAfterLimit(t *testing.T) {
+	body := new(endlessBody)
+	discardBody(body)
+
+	if !body.closed {
+		t.Error("body was not closed")
+	}
+	if body.read > maxDiscardSize {
+		t.Errorf("discarded %d bytes, want at most %d", body.read, maxDiscardSize)
+	}
+}
@@ -0,0 +1,140 @@
+package fritzbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type OnOffInfo struct {
+	State string `xml:"state"` // Switch state 1/0 on/off (empty if not known or if there was an error).
+}
+
+type LevelInfo struct {
+	Level           string `xml:"level"`           // Level or brightness from 0 to 255.
+	LevelPercentage string `xml:"levelpercentage"` // Level or brightness in percent from 0 to 100.
+}
+
+type ColorInfo struct {
+	SupportedModes string `xml:"supported_modes,attr"` // Bitmask of the supported color modes: 0x01 hue/saturation, 0x04 color temperature.
+	CurrentMode    string `xml:"current_mode,attr"`    // The active color mode, "1" for hue/saturation, "4" for color temperature, "" if unknown.
+	Hue            string `xml:"hue"`                  // Hue in degrees from 0 to 359.
+	Saturation     string `xml:"saturation"`           // Saturation from 0 to 255.
+	Temperature    string `xml:"temperature"`          // Color temperature in Kelvin.
+}
+
+// Color modes of ColorInfo.CurrentMode.
+const (
+	ColorModeHueSaturation = "1"
+	ColorModeTemperature   = "4"
+)
+
+func (i OnOffInfo) IsOn() bool {
+	return i.State == "1"
+}
+
+// GetLevelPercentage returns the level (e.g. the brightness of a light bulb)
+// in percent. It returns false if the device did not report a level.
+func (i LevelInfo) GetLevelPercentage() (float64, bool) {
+	f, err := parseFinite(i.LevelPercentage)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// GetHueSaturation returns the hue in degrees and the saturation in percent.
+// It returns false if the device is not in the hue/saturation color mode.
+func (i ColorInfo) GetHueSaturation() (hue, saturation float64, ok bool) {
+	if i.CurrentMode != ColorModeHueSaturation {
+		return 0, 0, false
+	}
+
+	hue, err := parseFinite(i.Hue)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	saturation, err = parseFinite(i.Saturation)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return hue, saturation / 255 * 100, true
+}
+
+// GetTemperature returns the color temperature in Kelvin. It returns false if
+// the device is not in the color temperature mode.
+func (i ColorInfo) GetTemperature() (float64, bool) {
+	if i.CurrentMode != ColorModeTemperature {
+		return 0, false
+	}
+
+	f, err := parseFinite(i.Temperature)
+	if err != nil || f <= 0 {
+		return 0, false
+	}
+	return f, true
+}
+
+// SetOnOff switches a device with the HAN-FUN on/off unit (e.g. a FRITZ!DECT
+// 500 light bulb) on or off.
+func (c *Client) SetOnOff(ctx context.Context, ain string, on bool) error {
+	c.logger.Debug("Switching device via on/off unit")
+
+	onoff := "0"
+	if on {
+		onoff = "1"
+	}
+
+	_, err := c.doCommand(ctx, "setsimpleonoff", "ain", strings.ReplaceAll(ain, " ", ""), "onoff", onoff)
+	return err
+}
+
+// SetLevelPercentage sets the level (e.g. the brightness of a light bulb) of
+// a device in percent.
+func (c *Client) SetLevelPercentage(ctx context.Context, ain string, percent int) error {
+	c.logger.Debug("Setting level of device")
+
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("level must be between 0 and 100 percent but got %d", percent)
+	}
+
+	_, err := c.doCommand(ctx, "setlevelpercentage", "ain", strings.ReplaceAll(ain, " ", ""), "level", strconv.Itoa(percent))
+	return err
+}
+
+// SetColor sets the hue in degrees and the saturation from 0 to 255 of a
+// light bulb. Note that the FRITZ!DECT 500 only supports the hue and
+// saturation values which are offered in the web interface of the FRITZ!Box.
+func (c *Client) SetColor(ctx context.Context, ain string, hue, saturation int) error {
+	c.logger.Debug("Setting color of device")
+
+	if hue < 0 || hue > 359 {
+		return fmt.Errorf("hue must be between 0 and 359 degrees but got %d", hue)
+	}
+	if saturation < 0 || saturation > 255 {
+		return fmt.Errorf("saturation must be between 0 and 255 but got %d", saturation)
+	}
+
+	_, err := c.doCommand(ctx, "setcolor",
+		"ain", strings.ReplaceAll(ain, " ", ""),
+		"hue", strconv.Itoa(hue),
+		"saturation", strconv.Itoa(saturation),
+		"duration", "0",
+	)
+	return err
+}
+
+// SetColorTemperature sets the color temperature of a light bulb in Kelvin.
+func (c *Client) SetColorTemperature(ctx context.Context, ain string, kelvin int) error {
+	c.logger.Debug("Setting color temperature of device")
+
+	_, err := c.doCommand(ctx, "setcolortemperature",
+		"ain", strings.ReplaceAll(ain, " ", ""),
+		"temperature", strconv.Itoa(kelvin),
+		"duration", "0",
+	)
+	return err
+}
@@ -0,0 +1,53 @@
+package fritzbox
+
+import "context"
+
+// MobileStatus contains the radio status of the mobile (LTE/5G) modem of
+// FRITZ!Box LTE models such as the 6820 or 6850.
+type MobileStatus struct {
+	Technology string  // Access technology, e.g. "LTE" or "5G".
+	Band       string  // Frequency band, e.g. "20".
+	CellID     string  // Identifier of the serving cell.
+	RSRP       float64 // Reference Signal Received Power in dBm.
+	RSRQ       float64 // Reference Signal Received Quality in dB.
+	SINR       float64 // Signal to Interference plus Noise Ratio in dB.
+}
+
+// MobileStatus returns the radio status of the mobile modem as shown on the
+// "Mobile Network" status page of the FRITZ!Box web interface. This is only
+// supported by FRITZ!Box LTE models.
+func (c *Client) MobileStatus(ctx context.Context) (*MobileStatus, error) {
+	c.logger.Debug("Requesting mobile network status")
+
+	var resp mobileResponse
+	err := c.dataLua(ctx, "lteInfo", &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.status(), nil
+}
+
+// mobileResponse is the data of the data.lua page "lteInfo".
+type mobileResponse struct {
+	Connection struct {
+		Technology flexString `json:"act"`
+		Band       flexString `json:"band"`
+		CellID     flexString `json:"cellid"`
+		RSRP       flexFloat  `json:"rsrp"`
+		RSRQ       flexFloat  `json:"rsrq"`
+		SINR       flexFloat  `json:"sinr"`
+	} `json:"connection"`
+}
+
+func (resp mobileResponse) status() *MobileStatus {
+	conn := resp.Connection
+	return &MobileStatus{
+		Technology: string(conn.Technology),
+		Band:       string(conn.Band),
+		CellID:     string(conn.CellID),
+		RSRP:       float64(conn.RSRP),
+		RSRQ:       float64(conn.RSRQ),
+		SINR:       float64(conn.SINR),
+	}
+}
@@ -1,9 +1,12 @@
 package fritzbox
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 )
 
 // 20 values representing the last 100 seconds in 20 buckets of 5 seconds each.
@@ -19,33 +22,88 @@ type TrafficMonitoringData struct {
 	UpstreamGuest           []float64 `json:"guest_us_bps"`
 }
 
+// maxTrafficBuckets is the number of buckets of each traffic series.
+const maxTrafficBuckets = 20
+
 func (c *Client) NetworkStats(ctx context.Context) (*TrafficMonitoringData, error) {
-	sessionID, err := c.getSession(ctx)
+	var resp *bytes.Buffer
+	err := c.withSession(ctx, func(sessionID string) error {
+		var err error
+		resp, err = c.get(ctx, "/internet/inetstat_monitor.lua",
+			"sid", sessionID,
+			"myXhr", "1",
+			"xhr", "1",
+			"useajax", "1",
+			"action", "get_graphic",
+		)
+		return err
+	})
+
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("inetstat_monitor.lua: %w", err)
 	}
 
-	resp, err := c.get(ctx, "/internet/inetstat_monitor.lua",
-		"sid", sessionID,
-		"myXhr", "1",
-		"xhr", "1",
-		"useajax", "1",
-		"action", "get_graphic",
-	)
+	return parseTrafficMonitoringData(resp)
+}
+
+// parseTrafficMonitoringData decodes the first element of the JSON array that
+// is returned by inetstat_monitor.lua. Only this element is decoded, so any
+// following elements cannot make us allocate more memory. Some firmwares
+// occasionally return garbage, so each series is checked before it is used.
+func parseTrafficMonitoringData(r io.Reader) (*TrafficMonitoringData, error) {
+	dec := json.NewDecoder(r)
 
+	tok, err := dec.Token()
 	if err != nil {
-		return nil, fmt.Errorf("inetstat_monitor.lua: %w", err)
+		return nil, fmt.Errorf("failed to decode response as JSON: %w", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("failed to decode response as JSON: expected array but got %v", tok)
 	}
 
-	var result []*TrafficMonitoringData
-	err = json.NewDecoder(resp).Decode(&result)
+	if !dec.More() {
+		return nil, fmt.Errorf("FRITZ!Box returned no monitoring data")
+	}
+
+	var data TrafficMonitoringData
+	err = dec.Decode(&data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode response as JSON: %w", err)
 	}
 
-	if len(result) == 0 {
-		return nil, fmt.Errorf("FRITZ!Box returned no monitoring data: %w", err)
+	err = data.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid monitoring data: %w", err)
+	}
+
+	return &data, nil
+}
+
+func (d *TrafficMonitoringData) validate() error {
+	series := map[string][]float64{
+		"ds_bps_curr":            d.DownstreamInternet,
+		"ds_mc_bps_curr":         d.DownStreamMedia,
+		"ds_guest_bps_curr":      d.DownStreamGuest,
+		"us_realtime_bps_curr":   d.UpstreamRealtime,
+		"us_important_bps_curr":  d.UpstreamHighPriority,
+		"us_default_bps_curr":    d.UpstreamDefaultPriority,
+		"us_background_bps_curr": d.UpstreamLowPriority,
+		"guest_us_bps":           d.UpstreamGuest,
+	}
+
+	for name, values := range series {
+		if len(values) == 0 {
+			return fmt.Errorf("%s is missing", name)
+		}
+		if len(values) > maxTrafficBuckets {
+			return fmt.Errorf("%s has %d values but at most %d are expected", name, len(values), maxTrafficBuckets)
+		}
+		for _, v := range values {
+			if v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+				return fmt.Errorf("%s contains invalid value %v", name, v)
+			}
+		}
 	}
 
-	return result[0], nil
+	return nil
 }
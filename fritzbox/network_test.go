@@ -0,0 +1,80 @@
+package fritzbox
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const inetstatSeries = `"ds_mc_bps_curr":[0,0],"ds_guest_bps_curr":[0,0],"us_realtime_bps_curr":[12,40],` +
+	`"us_important_bps_curr":[2104,1931],"us_default_bps_curr":[5339,4880],"us_background_bps_curr":[0,0],"guest_us_bps":[0,0]`
+
+func TestParseTrafficMonitoringData(t *testing.T) {
+	data, err := parseTrafficMonitoringData(strings.NewReader(`[{"ds_bps_curr":[157311,148213],` + inetstatSeries + `}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data.DownstreamInternet) != 2 || data.DownstreamInternet[0] != 157311 {
+		t.Errorf("downstream is %v", data.DownstreamInternet)
+	}
+	if len(data.UpstreamDefaultPriority) != 2 || data.UpstreamDefaultPriority[1] != 4880 {
+		t.Errorf("upstream is %v", data.UpstreamDefaultPriority)
+	}
+}
+
+func TestParseTrafficMonitoringDataIgnoresFollowingElements(t *testing.T) {
+	// Only the first element is decoded, so garbage after it does not matter.
+	body := `[{"ds_bps_curr":[1],` + inetstatSeries + `},{"ds_bps_curr":[` + strings.Repeat("1,", 10000) + `1]}, garbage`
+	if _, err := parseTrafficMonitoringData(strings.NewReader(body)); err != nil {
+		t.Errorf("failed to parse: %v", err)
+	}
+}
+
+func TestParseTrafficMonitoringDataErrors(t *testing.T) {
+	cases := map[string]string{
+		"empty response":    ``,
+		"object":            `{"ds_bps_curr":[1]}`,
+		"empty array":       `[]`,
+		"missing series":    `[{` + inetstatSeries + `}]`,
+		"negative value":    `[{"ds_bps_curr":[-1],` + inetstatSeries + `}]`,
+		"too many buckets":  `[{"ds_bps_curr":[` + strings.Repeat("1,", maxTrafficBuckets) + `1],` + inetstatSeries + `}]`,
+		"number overflow":   `[{"ds_bps_curr":[1e400],` + inetstatSeries + `}]`,
+		"string value":      `[{"ds_bps_curr":["1"],` + inetstatSeries + `}]`,
+		"truncated element": `[{"ds_bps_curr":[1,2`,
+	}
+
+	for name, body := range cases {
+		if _, err := parseTrafficMonitoringData(strings.NewReader(body)); err == nil {
+			t.Errorf("%s: parsing did not fail", name)
+		}
+	}
+}
+
+func FuzzParseTrafficMonitoringData(f *testing.F) {
+	f.Add([]byte(`[{"ds_bps_curr":[1,2,3],"ds_mc_bps_curr":[0],"ds_guest_bps_curr":[0],"us_realtime_bps_curr":[5],"us_important_bps_curr":[6],"us_default_bps_curr":[7],"us_background_bps_curr":[8],"guest_us_bps":[0]}]`))
+	f.Add([]byte(`[{"ds_bps_curr":[-1]}]`))
+	f.Add([]byte(`[]`))
+	f.Add([]byte(`{"ds_bps_curr":[1]}`))
+	f.Add([]byte(`[{"ds_bps_curr":[1e400]}]`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, err := parseTrafficMonitoringData(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+
+		for _, series := range [][]float64{
+			d.DownstreamInternet, d.DownStreamMedia, d.DownStreamGuest,
+			d.UpstreamRealtime, d.UpstreamHighPriority, d.UpstreamDefaultPriority,
+			d.UpstreamLowPriority, d.UpstreamGuest,
+		} {
+			if len(series) > maxTrafficBuckets {
+				t.Errorf("series has %d buckets", len(series))
+			}
+			for _, v := range series {
+				expectFinite(t, "traffic", v)
+			}
+		}
+	})
+}
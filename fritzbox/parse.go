@@ -0,0 +1,21 @@
+package fritzbox
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// parseFinite parses a number of a FRITZ!Box response. Unlike
+// strconv.ParseFloat it rejects "NaN" and "Inf" so broken firmware responses
+// cannot poison counters which are derived from the readings.
+func parseFinite(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return f, nil
+}
@@ -0,0 +1,54 @@
+package fritzbox
+
+import "testing"
+
+// The challenges and responses are the examples of the AVM technical note
+// "Session IDs im FRITZ!Box Webinterface".
+func TestSolveChallenge(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		password  string
+		want      string
+	}{
+		{
+			name:      "PBKDF2",
+			challenge: "2$10000$5A1711$2000$5A1722",
+			password:  "1example!",
+			want:      "5A1722$1798a1672bca7c6463d6b245f82b53703b0f50813401b03e4045a5861e689adb",
+		},
+		{
+			name:      "MD5 fallback",
+			challenge: "1234567z",
+			password:  "äbc",
+			want:      "1234567z-9e224a41eeefa284df7bb0f26c2913e2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Session{Challenge: tt.challenge}.solveChallenge(tt.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("response = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSolvePBKDF2ChallengeErrors(t *testing.T) {
+	for _, challenge := range []string{
+		"2$10000$5A1711$2000",         // missing salt
+		"2$x$5A1711$2000$5A1722",      // bad iterations
+		"2$0$5A1711$2000$5A1722",      // too few iterations
+		"2$10000$5A1711$9999999$5A17", // too many iterations
+		"2$10000$5A171$2000$5A1722",   // odd salt
+		"2$10000$5A1711$2000$XYZ0",    // no hex salt
+	} {
+		if _, err := solvePBKDF2Challenge(challenge, "1example!"); err == nil {
+			t.Errorf("solvePBKDF2Challenge(%q) did not fail", challenge)
+		}
+	}
+}
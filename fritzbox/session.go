@@ -2,7 +2,11 @@ package fritzbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -20,6 +24,16 @@ type Permissions struct {
 	AccessLevels []string `xml:"Access"`
 }
 
+// ErrSessionInvalid is returned if the FRITZ!Box rejects the session ID of a
+// request. This happens if the session expired or the FRITZ!Box rebooted. The
+// next request logs in again.
+var ErrSessionInvalid = errors.New("FRITZ!Box session is not valid")
+
+// ErrLoginBlocked is returned if the FRITZ!Box refuses logins for longer than
+// the context of a request allows to wait. Trying to log in anyway would only
+// extend the lockout.
+var ErrLoginBlocked = errors.New("FRITZ!Box blocks logins")
+
 // zeroSessionID is the session ID issued by the FRITZ!Box to indicate an
 // invalid or "no session".
 const zeroSessionID = "0000000000000000"
@@ -28,22 +42,47 @@ func (c *Client) getSession(ctx context.Context) (string, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.session.SID != "" {
+	if atomic.CompareAndSwapInt32(&c.sessionInvalid, 1, 0) {
+		c.session = Session{}
+	}
+
+	lastRequest := time.Unix(0, atomic.LoadInt64(&c.lastRequest))
+	if c.session.SID != "" && time.Since(lastRequest) < sessionRefreshAge {
 		return c.session.SID, nil
 	}
 
-	err := c.getXML(ctx, &c.session, "/login_sid.lua", "sid", c.session.SID)
+	// Asking for version 2 makes newer FRITZ!OS versions offer a PBKDF2
+	// challenge. Older versions ignore the parameter and use MD5. If we still
+	// have a session which was idle for a while, the FRITZ!Box either
+	// refreshes it or responds with a new challenge if it already expired.
+	err := c.getXML(ctx, &c.session, "/login_sid.lua", "version", "2", "sid", c.session.SID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get login challenge: %w", err)
 	}
 
-	if c.session.SID != zeroSessionID {
-		return "", nil // session is still valid
+	c.updateBlockTime()
+
+	if c.session.SID != "" && c.session.SID != zeroSessionID {
+		return c.session.SID, nil // session is still valid
+	}
+
+	err = c.waitForLogin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	c.logger.Debug("Authenticating new session at FRITZ!Box API",
+		zap.String("base_url", c.BaseURL.String()),
+		zap.Bool("pbkdf2", isPBKDF2Challenge(c.session.Challenge)),
+		zap.Duration("previous_session_age", c.sessionAge()),
+	)
+	challengeResponse, err := c.session.solveChallenge(c.Password)
+	if err != nil {
+		return "", err
 	}
 
-	c.logger.Debug("Authenticating new session at FRITZ!Box API", zap.String("base_url", c.BaseURL.String()))
-	challengeResponse := c.session.solveChallenge(c.Password)
 	err = c.getXML(ctx, &c.session, "/login_sid.lua",
+		"version", "2",
 		"response", challengeResponse,
 		"username", c.Username,
 	)
@@ -51,16 +90,193 @@ func (c *Client) getSession(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("failed to submit challenge response: %w", err)
 	}
 
+	c.updateBlockTime()
+
 	if c.session.SID == "" || c.session.SID == zeroSessionID {
+		if blocked := c.LoginBlockedFor(); blocked > 0 {
+			return "", fmt.Errorf("failed to solve authentication challenge, check username and password (further logins are blocked for %s)", blocked.Round(time.Second))
+		}
 		return "", fmt.Errorf("failed to solve authentication challenge, check username and password")
 	}
 
+	c.sessionStart = time.Now()
 	return c.session.SID, nil
 }
 
-func (s Session) solveChallenge(password string) string {
+// sessionAge returns how long ago the current session was created. The
+// caller must hold the lock.
+func (c *Client) sessionAge() time.Duration {
+	if c.sessionStart.IsZero() {
+		return 0
+	}
+	return time.Since(c.sessionStart)
+}
+
+// withSession calls fn with the ID of the current session. If the FRITZ!Box
+// rejects the session because it expired or the FRITZ!Box rebooted, fn is
+// retried once with a new session instead of failing the whole collection.
+func (c *Client) withSession(ctx context.Context, fn func(sessionID string) error) error {
+	for attempt := 1; ; attempt++ {
+		sessionID, err := c.getSession(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = fn(sessionID)
+		if attempt == 1 && errors.Is(err, ErrSessionInvalid) {
+			c.logger.Debug("FRITZ!Box rejected session, logging in again")
+			continue
+		}
+
+		return err
+	}
+}
+
+// maxBlockTime is the longest BlockTime in seconds we accept from the FRITZ!Box.
+const maxBlockTime = 24 * 60 * 60
+
+// updateBlockTime remembers until when the FRITZ!Box refuses logins according
+// to the BlockTime of the last login response.
+func (c *Client) updateBlockTime() {
+	var blockedUntil int64
+	seconds, err := strconv.Atoi(c.session.BlockTime)
+	if err == nil && seconds > maxBlockTime {
+		seconds = maxBlockTime // protects against garbage which would overflow the duration
+	}
+	if err == nil && seconds > 0 {
+		blockedUntil = time.Now().Add(time.Duration(seconds) * time.Second).UnixNano()
+	}
+
+	atomic.StoreInt64(&c.blockedUntil, blockedUntil)
+}
+
+// waitForLogin delays a login attempt until the BlockTime of the FRITZ!Box
+// expired. Each failed login while logins are blocked increases the BlockTime,
+// so logging in too early (e.g. with a wrong password or while another tool
+// fails to log in with the same account) locks us out for longer and longer.
+// If the context expires before, it returns ErrLoginBlocked without trying.
+func (c *Client) waitForLogin(ctx context.Context) error {
+	blocked := c.LoginBlockedFor()
+	if blocked <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < blocked {
+		return fmt.Errorf("%w for %s", ErrLoginBlocked, blocked.Round(time.Second))
+	}
+
+	c.logger.Debug("Waiting until FRITZ!Box accepts logins again", zap.Duration("block_time", blocked))
+
+	t := time.NewTimer(blocked)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w for %s: %v", ErrLoginBlocked, c.LoginBlockedFor().Round(time.Second), ctx.Err())
+	}
+}
+
+// LoginBlockedFor returns how long the FRITZ!Box still refuses to log us in.
+// The FRITZ!Box blocks logins for an increasing time after each failed login,
+// e.g. if another tool uses the same account with a different password.
+func (c *Client) LoginBlockedFor() time.Duration {
+	blockedUntil := atomic.LoadInt64(&c.blockedUntil)
+	if blockedUntil == 0 {
+		return 0
+	}
+
+	d := time.Until(time.Unix(0, blockedUntil))
+	if d < 0 {
+		return 0
+	}
+
+	return d
+}
+
+// solveChallenge computes the response to the login challenge using the
+// strongest method which is offered by the FRITZ!Box.
+func (s Session) solveChallenge(password string) (string, error) {
+	if isPBKDF2Challenge(s.Challenge) {
+		return solvePBKDF2Challenge(s.Challenge, password)
+	}
+
 	challengeAndPassword := s.Challenge + "-" + password
-	return s.Challenge + "-" + toUTF16andMD5(challengeAndPassword)
+	return s.Challenge + "-" + toUTF16andMD5(challengeAndPassword), nil
+}
+
+// sessionTimeout is the time after which the FRITZ!Box invalidates a session
+// which was not used.
+const sessionTimeout = 20 * time.Minute
+
+// sessionRefreshAge is the idle time after which we check the session with the
+// FRITZ!Box before using it. This refreshes the session shortly before it
+// times out and avoids a failing request if it already did.
+const sessionRefreshAge = sessionTimeout - 2*time.Minute
+
+// invalidateSession makes the next request log in again after the FRITZ!Box
+// rejected the given session. It must not acquire the lock since it may be
+// called while the session is being requested.
+func (c *Client) invalidateSession(sessionID string, previousRequest time.Time) {
+	// Concurrent requests with the same session are all rejected, but the
+	// session was only invalidated once. Requests which were still on their
+	// way with the old session may also be rejected after we logged in again.
+	if !atomic.CompareAndSwapInt32(&c.sessionInvalid, 0, 1) {
+		return
+	}
+	if previous := c.invalidSID.Swap(sessionID); previous == sessionID {
+		return
+	}
+
+	// A rebooted FRITZ!Box no longer knows any session.
+	if atomic.LoadInt32(&c.waiting) == 1 {
+		return
+	}
+
+	// A session which was used recently did not time out. Most likely another
+	// client logged in with the same account, which logs out fritz-mon.
+	if time.Since(previousRequest) < sessionTimeout {
+		atomic.AddUint64(&c.unexpectedLogouts, 1)
+	}
+}
+
+// UnexpectedLogouts returns how often the FRITZ!Box invalidated a session
+// before it timed out.
+func (c *Client) UnexpectedLogouts() uint64 {
+	return atomic.LoadUint64(&c.unexpectedLogouts)
+}
+
+// KeepAlive refreshes the current session if the FRITZ!Box was not accessed
+// for the given idle duration. The FRITZ!Box invalidates sessions which were
+// not used for 20 minutes. Keeping the session alive avoids logging in again
+// after each idle period, which counts against the brute-force protection.
+func (c *Client) KeepAlive(ctx context.Context, idle time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.session.SID == "" {
+		return nil // we don't have a session yet
+	}
+
+	lastRequest := time.Unix(0, atomic.LoadInt64(&c.lastRequest))
+	if time.Since(lastRequest) < idle {
+		return nil // the session is in use anyway
+	}
+
+	c.logger.Debug("Refreshing FRITZ!Box session")
+	var session Session
+	err := c.getXML(ctx, &session, "/login_sid.lua", "version", "2", "sid", c.session.SID)
+	if err != nil {
+		return fmt.Errorf("failed to refresh session: %w", err)
+	}
+
+	if session.SID == "" || session.SID == zeroSessionID {
+		c.logger.Debug("FRITZ!Box session has expired")
+		c.session = Session{} // log in again on the next request
+	}
+
+	return nil
 }
 
 func (c *Client) logout(ctx context.Context) error {
@@ -0,0 +1,57 @@
+package fritzbox
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestInvalidateSessionCountsEachSessionOnce(t *testing.T) {
+	c, err := New("http://fritz.box", "user", "password", zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recently := time.Now().Add(-time.Minute)
+	logIn := func() { atomic.CompareAndSwapInt32(&c.sessionInvalid, 1, 0) } // like getSession
+
+	// All collectors which use the session at the same time are rejected.
+	wg := new(sync.WaitGroup)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.invalidateSession("sid-1", recently)
+		}()
+	}
+	wg.Wait()
+	expect(t, "logouts after concurrent rejections", c.UnexpectedLogouts(), uint64(1))
+
+	// A request which was still on its way with the old session.
+	logIn()
+	c.invalidateSession("sid-1", recently)
+	expect(t, "logouts after late rejection", c.UnexpectedLogouts(), uint64(1))
+
+	logIn()
+	c.invalidateSession("sid-2", recently)
+	expect(t, "logouts after rejection of a new session", c.UnexpectedLogouts(), uint64(2))
+
+	// The session timed out regularly.
+	logIn()
+	c.invalidateSession("sid-3", time.Now().Add(-sessionTimeout))
+	expect(t, "logouts after timeout", c.UnexpectedLogouts(), uint64(2))
+
+	// The FRITZ!Box reboots.
+	logIn()
+	c.SetWaiting(true)
+	c.invalidateSession("sid-4", recently)
+	expect(t, "logouts while waiting", c.UnexpectedLogouts(), uint64(2))
+
+	logIn()
+	c.SetWaiting(false)
+	c.invalidateSession("sid-5", recently)
+	expect(t, "logouts after waiting", c.UnexpectedLogouts(), uint64(3))
+}
@@ -0,0 +1,129 @@
+package fritzbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeviceStats contains the history of the measurements of a device as it is
+// stored on the FRITZ!Box. Each metric may have multiple series with a
+// different resolution (e.g. 96 values every 15 minutes and 31 values every day).
+type DeviceStats struct {
+	Temperature []StatsSeries `xml:"temperature>stats"` // in 0.1 °C
+	Voltage     []StatsSeries `xml:"voltage>stats"`     // in mV
+	Power       []StatsSeries `xml:"power>stats"`       // in 0.01 W
+	Energy      []StatsSeries `xml:"energy>stats"`      // in Wh
+	Humidity    []StatsSeries `xml:"humidity>stats"`    // in %
+}
+
+// StatsSeries is a single series of measurements. The values are sorted
+// newest first.
+type StatsSeries struct {
+	Count    int    `xml:"count,attr"`
+	Grid     int    `xml:"grid,attr"`     // seconds between two values
+	DataTime int64  `xml:"datatime,attr"` // Unix timestamp of the newest value (not reported by older FRITZ!OS versions)
+	Values   string `xml:",chardata"`     // comma separated, "-" marks a missing value
+}
+
+// Grids of the energy series of smart plugs: one value per day (the last 31
+// days) and one value per month (the last 24 months).
+const (
+	StatsGridDay   = 86400
+	StatsGridMonth = 2678400
+)
+
+// Value returns the i-th newest value of the series (0 is the newest one)
+// multiplied with scale. It returns false if the value is missing.
+func (s StatsSeries) Value(i int, scale float64) (float64, bool) {
+	values := strings.Split(strings.TrimSpace(s.Values), ",")
+	if i < 0 || i >= len(values) {
+		return 0, false
+	}
+
+	f, err := parseFinite(strings.TrimSpace(values[i]))
+	if err != nil {
+		return 0, false
+	}
+	return f * scale, true
+}
+
+// Sample is a single measurement in the base unit of its metric (e.g. Watt).
+type Sample struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+}
+
+// StatsMetrics contains the names of all metrics in DeviceStats with their
+// unit after scaling.
+var StatsMetrics = map[string]string{
+	"temperature": "celsius",
+	"voltage":     "volts",
+	"power":       "watts",
+	"energy":      "watt_hours",
+	"humidity":    "percent",
+}
+
+func (c *Client) DeviceStats(ctx context.Context, ain string) (*DeviceStats, error) {
+	c.logger.Debug("Requesting device statistics")
+
+	var stats DeviceStats
+	err := c.doXMLCommand(ctx, &stats, "getbasicdevicestats", "ain", ain)
+	return &stats, err
+}
+
+// Series returns all series of the given metric together with the factor
+// which converts the raw values into the base unit of the metric.
+func (s *DeviceStats) Series(metric string) ([]StatsSeries, float64, error) {
+	switch metric {
+	case "temperature":
+		return s.Temperature, 0.1, nil
+	case "voltage":
+		return s.Voltage, 0.001, nil
+	case "power":
+		return s.Power, 0.01, nil
+	case "energy":
+		return s.Energy, 1, nil
+	case "humidity":
+		return s.Humidity, 1, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown metric %q", metric)
+	}
+}
+
+// Samples parses the values of the series and multiplies them with scale.
+// Missing values are skipped. If the FRITZ!Box did not report the time of the
+// newest value, now is used instead. The result is sorted oldest first.
+func (s StatsSeries) Samples(scale float64, now time.Time) ([]Sample, error) {
+	newest := now
+	if s.DataTime > 0 {
+		newest = time.Unix(s.DataTime, 0)
+	}
+
+	values := strings.Split(strings.TrimSpace(s.Values), ",")
+	samples := make([]Sample, 0, len(values))
+	for i := len(values) - 1; i >= 0; i-- {
+		v := strings.TrimSpace(values[i])
+		if v == "" || v == "-" {
+			continue
+		}
+
+		f, err := parseFinite(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q in device statistics: %w", v, err)
+		}
+
+		samples = append(samples, Sample{
+			Time:  newest.Add(-time.Duration(i*s.Grid) * time.Second),
+			Value: f * scale,
+		})
+	}
+
+	return samples, nil
+}
+
+// Duration returns the time span which is covered by the series.
+func (s StatsSeries) Duration() time.Duration {
+	return time.Duration(s.Count*s.Grid) * time.Second
+}
@@ -0,0 +1,55 @@
+package fritzbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SwitchOn switches on the switchable socket with the given AIN.
+func (c *Client) SwitchOn(ctx context.Context, ain string) error {
+	c.logger.Debug("Switching on device")
+	return c.setSwitch(ctx, "setswitchon", ain, "1")
+}
+
+// SwitchOff switches off the switchable socket with the given AIN.
+func (c *Client) SwitchOff(ctx context.Context, ain string) error {
+	c.logger.Debug("Switching off device")
+	return c.setSwitch(ctx, "setswitchoff", ain, "0")
+}
+
+// SwitchToggle toggles the switchable socket with the given AIN and returns
+// whether it is switched on afterwards.
+func (c *Client) SwitchToggle(ctx context.Context, ain string) (bool, error) {
+	c.logger.Debug("Toggling device")
+
+	resp, err := c.doCommand(ctx, "setswitchtoggle", "ain", strings.ReplaceAll(ain, " ", ""))
+	if err != nil {
+		return false, err
+	}
+
+	switch state := strings.TrimSpace(resp.String()); state {
+	case "1":
+		return true, nil
+	case "0":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unexpected switch state %q after setswitchtoggle", state)
+	}
+}
+
+// setSwitch executes the given switch command and checks that the FRITZ!Box
+// responds with the expected new state. The AIN may be given as it appears in
+// the device list, i.e. with a space after the vendor prefix.
+func (c *Client) setSwitch(ctx context.Context, cmd, ain, expected string) error {
+	resp, err := c.doCommand(ctx, cmd, "ain", strings.ReplaceAll(ain, " ", ""))
+	if err != nil {
+		return err
+	}
+
+	if state := strings.TrimSpace(resp.String()); state != expected {
+		return fmt.Errorf("unexpected switch state %q after %s", state, cmd)
+	}
+
+	return nil
+}
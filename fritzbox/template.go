@@ -0,0 +1,58 @@
+package fritzbox
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Template is a smart home template which is configured in the FRITZ!Box,
+// e.g. setting all radiators to the economy temperature.
+type Template struct {
+	Identifier string `xml:"identifier,attr"` // AIN of the template, used to apply it.
+	InternalID string `xml:"id,attr"`         // Internal ID of the template.
+	Name       string `xml:"name"`            // The name of the template as shown in the web gui of the FRITZ!Box.
+	Devices    []struct {
+		Identifier string `xml:"identifier,attr"`
+	} `xml:"devices>device"` // Devices and groups which are changed by the template.
+}
+
+// DeviceIdentifiers returns the AINs of all devices and groups which are
+// changed by the template.
+func (t Template) DeviceIdentifiers() []string {
+	ains := make([]string, len(t.Devices))
+	for i, d := range t.Devices {
+		ains[i] = d.Identifier
+	}
+	return ains
+}
+
+// Templates returns all smart home templates of the FRITZ!Box.
+func (c *Client) Templates(ctx context.Context) ([]Template, error) {
+	c.logger.Debug("Requesting list of templates")
+
+	var list struct {
+		Templates []Template `xml:"template"`
+	}
+	err := c.doXMLCommand(ctx, &list, "gettemplatelistinfos")
+	return list.Templates, err
+}
+
+// ApplyTemplate applies the template with the given AIN. The AIN may be given
+// as it appears in the template list.
+func (c *Client) ApplyTemplate(ctx context.Context, ain string) error {
+	c.logger.Debug("Applying template")
+
+	resp, err := c.doCommand(ctx, "applytemplate", "ain", strings.ReplaceAll(ain, " ", ""))
+	if err != nil {
+		return err
+	}
+
+	// The FRITZ!Box responds with the internal ID of the applied template
+	// and with an empty response if it does not know the template.
+	if strings.TrimSpace(resp.String()) == "" {
+		return fmt.Errorf("unknown template %q", ain)
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package fritzbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Range of target temperatures in °C which can be set on a thermostat.
+const (
+	ThermostatMinCelsius = 8
+	ThermostatMaxCelsius = 28
+)
+
+// GetComfort returns the comfort temperature of the thermostat in °C.
+func (i ThermostatInfo) GetComfort() (float64, bool) {
+	return thermostatCelsius(i.Comfort)
+}
+
+// IsOff returns true if the thermostat is switched off, i.e. it does not heat
+// at all.
+func (i ThermostatInfo) IsOff() bool {
+	return i.Goal == "253"
+}
+
+// SetTargetTemperature sets the target temperature of the thermostat with the
+// given AIN in °C. The FRITZ!Box only supports steps of 0.5 °C, so the value
+// is rounded accordingly.
+func (c *Client) SetTargetTemperature(ctx context.Context, ain string, celsius float64) error {
+	c.logger.Debug("Setting target temperature of thermostat")
+
+	if celsius < ThermostatMinCelsius || celsius > ThermostatMaxCelsius {
+		return fmt.Errorf("target temperature must be between %d and %d °C but got %.1f",
+			ThermostatMinCelsius, ThermostatMaxCelsius, celsius)
+	}
+
+	steps := int(celsius*2 + 0.5)
+	return c.setThermostat(ctx, ain, strconv.Itoa(steps))
+}
+
+// SetThermostatOff switches off the thermostat with the given AIN.
+func (c *Client) SetThermostatOff(ctx context.Context, ain string) error {
+	c.logger.Debug("Switching off thermostat")
+	return c.setThermostat(ctx, ain, "253")
+}
+
+func (c *Client) setThermostat(ctx context.Context, ain, param string) error {
+	_, err := c.doCommand(ctx, "sethkrtsoll", "ain", strings.ReplaceAll(ain, " ", ""), "param", param)
+	return err
+}
@@ -1,11 +1,38 @@
 module github.com/fgrosse/fritz-mon
 
-go 1.13
+go 1.18
 
 require (
-	github.com/pkg/errors v0.8.1
+	github.com/brutella/hap v0.0.17
 	github.com/prometheus/client_golang v1.3.0
+	github.com/prometheus/client_model v0.1.0
 	go.uber.org/multierr v1.3.0
 	go.uber.org/zap v1.13.0
 	gopkg.in/yaml.v2 v2.2.2
 )
+
+require (
+	github.com/BurntSushi/toml v0.3.1 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/brutella/dnssd v1.2.3 // indirect
+	github.com/cespare/xxhash/v2 v2.1.1 // indirect
+	github.com/go-chi/chi v1.5.4 // indirect
+	github.com/golang/protobuf v1.3.2 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/miekg/dns v1.1.50 // indirect
+	github.com/prometheus/common v0.7.0 // indirect
+	github.com/prometheus/procfs v0.0.8 // indirect
+	github.com/tadglines/go-pkgs v0.0.0-20210623144937-b983b20f54f9 // indirect
+	github.com/xiam/to v0.0.0-20200126224905-d60d31e03561 // indirect
+	go.uber.org/atomic v1.5.0 // indirect
+	go.uber.org/tools v0.0.0-20190618225709-2cfd321de3ee // indirect
+	golang.org/x/crypto v0.0.0-20220131195533-30dcbda58838 // indirect
+	golang.org/x/lint v0.0.0-20190930215403-16217165b5de // indirect
+	golang.org/x/mod v0.4.2 // indirect
+	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2 // indirect
+	golang.org/x/sys v0.0.0-20210630005230-0f9fa26af87c // indirect
+	golang.org/x/text v0.3.7 // indirect
+	golang.org/x/tools v0.1.6-0.20210726203631-07bc1bf47fb2 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
+	honnef.co/go/tools v0.0.1-2019.2.3 // indirect
+)
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+)
+
+// switchResult is the outcome of switching a single device of a group.
+type switchResult struct {
+	Device string `json:"device"`
+	AIN    string `json:"ain"`
+	On     bool   `json:"on"`
+	Error  string `json:"error,omitempty"`
+}
+
+// groupSwitches returns the switches of the group with the given name. Groups
+// of the configuration (see Config.Groups) take precedence over the device
+// groups of the FRITZ!Box. Members of a configured group which are unknown to
+// this FRITZ!Box are ignored since they may belong to another one.
+func groupSwitches(ctx context.Context, client *fritzbox.Client, devices []fritzbox.Device, groups map[string][]string, name string) ([]fritzbox.Device, error) {
+	var switches []fritzbox.Device
+	if members, ok := groups[name]; ok {
+		for _, d := range devices {
+			if d.IsSwitch() && isGroupMember(d, members) {
+				switches = append(switches, d)
+			}
+		}
+
+		return switches, nil
+	}
+
+	boxGroups, err := client.Groups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch device groups: %w", err)
+	}
+
+	for _, group := range boxGroups {
+		if group.Name != name {
+			continue
+		}
+
+		for _, id := range group.MemberIDs() {
+			for _, d := range devices {
+				if d.InternalID == id && d.IsSwitch() {
+					switches = append(switches, d)
+				}
+			}
+		}
+	}
+
+	return switches, nil
+}
+
+// isGroupMember returns true if the device is listed by its name or AIN.
+// Spaces in the AIN are optional.
+func isGroupMember(d fritzbox.Device, members []string) bool {
+	for _, member := range members {
+		if d.Name == member || strings.ReplaceAll(d.Identifier, " ", "") == strings.ReplaceAll(member, " ", "") {
+			return true
+		}
+	}
+	return false
+}
+
+// switchAll executes the switch action ("on", "off" or "toggle") for all given
+// devices concurrently. The results are in the order of the devices.
+func switchAll(ctx context.Context, client *fritzbox.Client, devices []fritzbox.Device, action string) []switchResult {
+	results := make([]switchResult, len(devices))
+
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		wg.Add(1)
+		go func(i int, device fritzbox.Device) {
+			defer wg.Done()
+
+			on := action == "on"
+			var err error
+			switch action {
+			case "on":
+				err = client.SwitchOn(ctx, device.Identifier)
+			case "off":
+				err = client.SwitchOff(ctx, device.Identifier)
+			case "toggle":
+				on, err = client.SwitchToggle(ctx, device.Identifier)
+			default:
+				err = fmt.Errorf("unknown action %q", action)
+			}
+
+			results[i] = switchResult{Device: device.Name, AIN: device.Identifier, On: on}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, device)
+	}
+
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/fgrosse/fritz-mon/tr064"
+	"go.uber.org/zap"
+)
+
+// serveGuestWLAN implements the control endpoint for the guest WLAN:
+//
+//	POST /api/v1/guest_wlan  {"state": "on"|"off"|"toggle", "box": "<name>"}
+//
+// The box is only required if multiple FRITZ!Boxes are monitored.
+func (s *Server) serveGuestWLAN(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxAPIRequestSize))
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		State string `json:"state"`
+		Box   string `json:"box"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("bad request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.State != "on" && req.State != "off" && req.State != "toggle" {
+		http.Error(w, fmt.Sprintf("state must be on, off or toggle but got %q", req.State), http.StatusBadRequest)
+		return
+	}
+
+	box, err := s.findBox(req.Box)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if !s.confirmAction(w, r, "guest_wlan", box.Name, body) || !s.limitAction(w, "guest_wlan", box.Name) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), apiActionTimeout)
+	defer cancel()
+
+	guest, err := setGuestWLAN(ctx, box.TR064, req.State)
+	if err != nil {
+		box.Logger.Error("Guest WLAN action of the API failed", zap.String("state", req.State), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	box.Logger.Info("Switched guest WLAN via the API",
+		zap.String("ssid", guest.SSID),
+		zap.Bool("enabled", guest.Enabled),
+	)
+
+	s.writeJSON(w, map[string]interface{}{
+		"box":     box.Name,
+		"ssid":    guest.SSID,
+		"enabled": guest.Enabled,
+	})
+}
+
+// findBox returns the monitored FRITZ!Box with the given name. The name may be
+// empty if only a single FRITZ!Box is monitored.
+func (s *Server) findBox(name string) (*Box, error) {
+	if name == "" {
+		if len(s.Boxes) != 1 {
+			return nil, fmt.Errorf("box is required when monitoring multiple FRITZ!Boxes")
+		}
+		return s.Boxes[0], nil
+	}
+
+	for _, box := range s.Boxes {
+		if box.Name == name {
+			return box, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown box %q", name)
+}
+
+// setGuestWLAN switches the guest WLAN on or off or toggles it and returns its
+// new state. This requires a user with the permission to change the FRITZ!Box
+// settings.
+func setGuestWLAN(ctx context.Context, client *tr064.Client, state string) (*tr064.GuestWLAN, error) {
+	guest, err := client.GuestWLAN(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := state == "on"
+	if state == "toggle" {
+		enabled = !guest.Enabled
+	}
+
+	if enabled != guest.Enabled {
+		err = client.SetWLANEnabled(ctx, guest.Index, enabled)
+		if err != nil {
+			return nil, fmt.Errorf("failed to switch guest WLAN: %w", err)
+		}
+	}
+
+	guest.Enabled = enabled
+	return guest, nil
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// readiness tracks which collectors have not yet completed their first
+// collection. The server uses one readiness for the first successful
+// collections (see /readyz) and one for the first collection attempts
+// regardless of their result (see notifySystemd).
+type readiness struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	started bool          // true once all collectors were added via wait
+	ready   chan struct{} // closed once started and no collector is pending
+}
+
+func newReadiness() *readiness {
+	return &readiness{pending: map[string]bool{}, ready: make(chan struct{})}
+}
+
+func (r *readiness) wait(collector string) {
+	r.mu.Lock()
+	r.pending[collector] = true
+	r.mu.Unlock()
+}
+
+func (r *readiness) done(collector string) {
+	r.mu.Lock()
+	delete(r.pending, collector)
+	r.checkReady()
+	r.mu.Unlock()
+}
+
+// start must be called once all collectors were added via wait. Before, the
+// channel of Ready is never closed even if no collector is pending.
+func (r *readiness) start() {
+	r.mu.Lock()
+	r.started = true
+	r.checkReady()
+	r.mu.Unlock()
+}
+
+// Ready returns a channel which is closed once all collectors are done.
+func (r *readiness) Ready() <-chan struct{} {
+	return r.ready
+}
+
+func (r *readiness) checkReady() {
+	if !r.started || len(r.pending) > 0 {
+		return
+	}
+
+	select {
+	case <-r.ready:
+		// already closed
+	default:
+		close(r.ready)
+	}
+}
+
+func (r *readiness) isDone(collector string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.pending[collector]
+}
+
+// pendingCollectors returns the sorted names of all collectors which are not
+// yet done.
+func (r *readiness) pendingCollectors() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.pending))
+	for name := range r.pending {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP implements the /readyz endpoint.
+func (r *readiness) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	pending := r.pendingCollectors()
+	if len(pending) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "waiting for first collection of: %s\n", strings.Join(pending, ", "))
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+// staleIntervals is the number of intervals a collector may go without a
+// successful collection before fritz-mon reports itself as unhealthy.
+const staleIntervals = 3
+
+// staleCollectors returns the sorted names of all collectors which did not
+// succeed for more than staleIntervals intervals. Collectors without an
+// interval only run on scrapes and can therefore not become stale. Collectors
+// on a cron schedule may not run for much longer than their interval, so they
+// are stale after staleIntervals failed collections in a row instead.
+func (r *statusRegistry) staleCollectors(now time.Time) []string {
+	var names []string
+	for name, status := range r.snapshot() {
+		if status.Interval <= 0 {
+			continue
+		}
+
+		if status.scheduled {
+			if status.failures >= staleIntervals {
+				names = append(names, name)
+			}
+			continue
+		}
+
+		lastSuccess := status.LastSuccess
+		if lastSuccess.IsZero() {
+			lastSuccess = status.added
+		}
+
+		if now.Sub(lastSuccess) > staleIntervals*status.Interval {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// hungTimeouts is the number of timeouts after which a collection which did
+// not return is considered hung. Collections are canceled after their timeout,
+// so a collection which keeps running much longer means its loop is wedged.
+const hungTimeouts = 2
+
+// hungCollectors returns the sorted names of all collectors whose current
+// collection runs longer than hungTimeouts timeouts. Other than
+// staleCollectors, this does not depend on whether the FRITZ!Box is reachable
+// but only on whether the collector loops of fritz-mon still make progress.
+func (r *statusRegistry) hungCollectors(now time.Time) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var names []string
+	for name, status := range r.collectors {
+		if status.running.IsZero() || status.timeout <= 0 {
+			continue
+		}
+
+		if now.Sub(status.running) > hungTimeouts*status.timeout {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// healthy returns true if every collector succeeded at least once and none of
+// them is stale.
+func (s *Server) healthy(now time.Time) bool {
+	return len(s.ready.pendingCollectors()) == 0 && len(s.status.staleCollectors(now)) == 0
+}
+
+// healthyMetric returns the fritzmon_healthy gauge which summarizes the state
+// of all collectors, so dashboards do not need to combine the series of every
+// collector to tell if everything is fine.
+func (s *Server) healthyMetric() prometheus.Collector {
+	return prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "fritzmon_healthy",
+			Help: "Either 0 or 1 to indicate if all collectors succeeded within their expected intervals.",
+		},
+		func() float64 {
+			return prometheusBool(s.healthy(time.Now()))
+		},
+	)
+}
+
+// serveHealth implements the /healthz endpoint. In contrast to readiness it
+// fails if collections stop succeeding at any time, so a supervisor can
+// restart fritz-mon if it is wedged.
+func (s *Server) serveHealth(w http.ResponseWriter, _ *http.Request) {
+	stale := s.status.staleCollectors(time.Now())
+	if len(stale) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "no successful collection for %d intervals: %s\n", staleIntervals, strings.Join(stale, ", "))
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/brutella/hap"
+	"github.com/brutella/hap/accessory"
+	"github.com/brutella/hap/characteristic"
+	"github.com/brutella/hap/service"
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+// homeKitActionTimeout limits how long a change from the Home app may take
+// until the FRITZ!Box applied it.
+const homeKitActionTimeout = 10 * time.Second
+
+type HomeKitConfig struct {
+	PIN        string `yaml:"pin"`         // 8 digit setup code which is entered in the Home app, HomeKit is disabled if empty
+	Name       string `yaml:"name"`        // name of the bridge in the Home app
+	ListenAddr string `yaml:"listen_addr"` // address of the HomeKit server, a random port is used if empty
+	StorageDir string `yaml:"storage_dir"` // directory in which the pairings are stored, must be kept across restarts
+}
+
+func (c HomeKitConfig) Enabled() bool {
+	return c.PIN != ""
+}
+
+func (c HomeKitConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if len(c.PIN) != 8 {
+		return fmt.Errorf("homekit.pin must have exactly 8 digits")
+	}
+	for _, r := range c.PIN {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("homekit.pin must have exactly 8 digits")
+		}
+	}
+	if c.Name == "" {
+		return fmt.Errorf("homekit.name cannot be empty")
+	}
+	if c.StorageDir == "" {
+		return fmt.Errorf("homekit.storage_dir cannot be empty")
+	}
+
+	return nil
+}
+
+// homeKitBridge exposes the smart home devices of all FRITZ!Boxes as HomeKit
+// accessories. HomeKit requires all accessories to be known when the bridge is
+// started, so it waits until the devices of every box were collected once.
+// Devices which are added to the FRITZ!Box later appear after a restart.
+type homeKitBridge struct {
+	conf       HomeKitConfig
+	pseudonyms *pseudonymizer // replaces the names of the accessories if anonymization is enabled
+	logger     *zap.Logger
+
+	mu          sync.Mutex
+	ctx         context.Context // used for changes from the Home app
+	accessories map[string]*homeKitAccessory
+	pending     map[string]bool // boxes whose devices were not yet collected
+	ready       chan struct{}   // closed when all accessories are known
+}
+
+// homeKitAccessory is a FRITZ!Box device and its HomeKit services. Services
+// which are not supported by the device are nil.
+type homeKitAccessory struct {
+	a           *accessory.A
+	outlet      *service.Outlet
+	thermostat  *service.Thermostat
+	temperature *service.TemperatureSensor
+	humidity    *service.HumiditySensor
+	contact     *service.ContactSensor
+}
+
+func newHomeKitBridge(conf HomeKitConfig, boxes []*Box, pseudonyms *pseudonymizer, logger *zap.Logger) *homeKitBridge {
+	pending := map[string]bool{}
+	for _, box := range boxes {
+		pending[box.Name] = true
+	}
+
+	return &homeKitBridge{
+		conf:        conf,
+		pseudonyms:  pseudonyms,
+		logger:      logger.With(zap.String("component", "homekit")),
+		ctx:         context.Background(),
+		accessories: map[string]*homeKitAccessory{},
+		pending:     pending,
+		ready:       make(chan struct{}),
+	}
+}
+
+// Run starts the HomeKit server as soon as the devices of all boxes are known
+// and blocks until the context is canceled.
+func (b *homeKitBridge) Run(ctx context.Context) {
+	b.mu.Lock()
+	b.ctx = ctx
+	b.mu.Unlock()
+
+	select {
+	case <-b.ready:
+	case <-ctx.Done():
+		return
+	}
+
+	bridge := accessory.NewBridge(accessory.Info{
+		Name:         b.conf.Name,
+		Manufacturer: "fritz-mon",
+	})
+
+	b.mu.Lock()
+	var accessories []*accessory.A
+	for _, acc := range b.accessories {
+		accessories = append(accessories, acc.a)
+	}
+	b.mu.Unlock()
+
+	server, err := hap.NewServer(hap.NewFsStore(b.conf.StorageDir), bridge.A, accessories...)
+	if err != nil {
+		b.logger.Error("Failed to create HomeKit server", zap.Error(err))
+		return
+	}
+
+	server.Pin = b.conf.PIN
+	server.Addr = b.conf.ListenAddr
+
+	b.logger.Info("Starting HomeKit bridge", zap.Int("accessories", len(accessories)))
+	err = server.ListenAndServe(ctx)
+	if err != nil && ctx.Err() == nil {
+		b.logger.Error("HomeKit server failed", zap.Error(err))
+	}
+}
+
+// Update sets the characteristics of the accessories to the devices which were
+// collected from the given box. Until the bridge is started, unknown devices
+// are added as new accessories.
+func (b *homeKitBridge) Update(box *Box, devices []fritzbox.Device) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	started := len(b.pending) == 0
+	for _, device := range devices {
+		key := box.Name + "/" + device.Identifier
+		acc, ok := b.accessories[key]
+		if !ok {
+			if started {
+				continue
+			}
+
+			acc = b.newAccessory(box, device)
+			if acc == nil {
+				continue
+			}
+			b.accessories[key] = acc
+		}
+
+		acc.update(device)
+	}
+
+	if !started {
+		delete(b.pending, box.Name)
+		if len(b.pending) == 0 {
+			close(b.ready)
+		}
+	}
+}
+
+// newAccessory creates the accessory of a device or returns nil if the device
+// has no capabilities which can be exposed to HomeKit.
+func (b *homeKitBridge) newAccessory(box *Box, device fritzbox.Device) *homeKitAccessory {
+	info := accessory.Info{
+		Name:         b.pseudonyms.label("device_name", device.Name),
+		SerialNumber: device.Identifier,
+		Manufacturer: device.Manufacturer,
+		Model:        device.ProductName,
+		Firmware:     device.FirmwareVersion,
+	}
+
+	client, ain := box.FritzBox, device.Identifier
+	logger := b.logger.With(zap.String("box", box.Name), zap.String("device", device.Name))
+
+	acc := new(homeKitAccessory)
+	switch {
+	case device.IsThermostat():
+		a := accessory.NewThermostat(info)
+		acc.a, acc.thermostat = a.A, a.Thermostat
+
+		acc.thermostat.TargetTemperature.SetMinValue(fritzbox.ThermostatMinCelsius)
+		acc.thermostat.TargetTemperature.SetMaxValue(fritzbox.ThermostatMaxCelsius)
+		acc.thermostat.TargetTemperature.SetStepValue(0.5)
+		acc.thermostat.TargetTemperature.OnValueRemoteUpdate(func(celsius float64) {
+			b.do(logger, "Failed to set target temperature", func(ctx context.Context) error {
+				return client.SetTargetTemperature(ctx, ain, celsius)
+			})
+		})
+		acc.thermostat.TargetHeatingCoolingState.OnValueRemoteUpdate(func(state int) {
+			b.do(logger, "Failed to set heating state", func(ctx context.Context) error {
+				if state == characteristic.TargetHeatingCoolingStateOff {
+					return client.SetThermostatOff(ctx, ain)
+				}
+
+				// Any other mode continues heating at the comfort temperature.
+				comfort, ok := device.Thermostat.GetComfort()
+				if !ok {
+					return fmt.Errorf("comfort temperature is unknown")
+				}
+				return client.SetTargetTemperature(ctx, ain, comfort)
+			})
+		})
+	case device.IsSwitch():
+		a := accessory.NewOutlet(info)
+		acc.a, acc.outlet = a.A, a.Outlet
+
+		acc.outlet.On.OnValueRemoteUpdate(func(on bool) {
+			b.do(logger, "Failed to switch device", func(ctx context.Context) error {
+				if on {
+					return client.SwitchOn(ctx, ain)
+				}
+				return client.SwitchOff(ctx, ain)
+			})
+		})
+	case device.CanMeasureTemperature() || device.CanMeasureHumidity() || device.IsAlertSensor():
+		acc.a = accessory.New(info, accessory.TypeSensor)
+	default:
+		return nil
+	}
+
+	// Thermostats report the measured temperature themselves.
+	if device.CanMeasureTemperature() && acc.thermostat == nil {
+		acc.temperature = service.NewTemperatureSensor()
+		acc.a.AddS(acc.temperature.S)
+	}
+	if device.CanMeasureHumidity() {
+		acc.humidity = service.NewHumiditySensor()
+		acc.a.AddS(acc.humidity.S)
+	}
+	if device.IsAlertSensor() {
+		acc.contact = service.NewContactSensor()
+		acc.a.AddS(acc.contact.S)
+	}
+
+	// HomeKit identifies accessories by their ID, so it must not change when
+	// fritz-mon is restarted. The IDs 0 and 1 are reserved for the bridge.
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(box.Name + "/" + device.Identifier))
+	acc.a.Id = h.Sum64()
+	if acc.a.Id <= 1 {
+		acc.a.Id += 2
+	}
+
+	return acc
+}
+
+// do applies a change from the Home app. The new state is reported back to
+// HomeKit with the next collection of the devices.
+func (b *homeKitBridge) do(logger *zap.Logger, msg string, fn func(ctx context.Context) error) {
+	b.mu.Lock()
+	ctx, cancel := context.WithTimeout(b.ctx, homeKitActionTimeout)
+	b.mu.Unlock()
+	defer cancel()
+
+	if err := fn(ctx); err != nil {
+		logger.Error(msg, zap.Error(err))
+	}
+}
+
+func (acc *homeKitAccessory) update(device fritzbox.Device) {
+	if acc.outlet != nil {
+		acc.outlet.On.SetValue(device.Switch.IsPoweredOn())
+		acc.outlet.OutletInUse.SetValue(device.Power.GetPower() > 0)
+	}
+
+	if acc.thermostat != nil {
+		if measured, ok := device.Thermostat.GetMeasured(); ok {
+			acc.thermostat.CurrentTemperature.SetValue(measured)
+		}
+
+		state := characteristic.TargetHeatingCoolingStateHeat
+		if device.Thermostat.IsOff() {
+			state = characteristic.TargetHeatingCoolingStateOff
+		}
+		acc.thermostat.TargetHeatingCoolingState.SetValue(state)
+
+		current := characteristic.CurrentHeatingCoolingStateOff
+		goal, ok := device.Thermostat.GetGoal()
+		if ok {
+			acc.thermostat.TargetTemperature.SetValue(goal)
+			if measured, ok := device.Thermostat.GetMeasured(); ok && measured < goal {
+				current = characteristic.CurrentHeatingCoolingStateHeat
+			}
+		}
+		acc.thermostat.CurrentHeatingCoolingState.SetValue(current)
+	}
+
+	if acc.temperature != nil {
+		acc.temperature.CurrentTemperature.SetValue(device.Temperature.GetCelsius())
+	}
+
+	if acc.humidity != nil {
+		if humidity, ok := device.Humidity.GetRelativeHumidity(); ok {
+			acc.humidity.CurrentRelativeHumidity.SetValue(humidity)
+		}
+	}
+
+	if acc.contact != nil {
+		state := characteristic.ContactSensorStateContactDetected
+		if alert, known := device.AlertSensor.IsAlerting(); known && alert {
+			state = characteristic.ContactSensorStateContactNotDetected
+		}
+		acc.contact.ContactSensorState.SetValue(state)
+	}
+}
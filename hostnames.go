@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/tr064"
+	"go.uber.org/zap"
+)
+
+// hostNamesTTL is how long the host names of the FRITZ!Box are cached. Names
+// rarely change so there is no need to fetch the whole host list every time.
+const hostNamesTTL = 10 * time.Minute
+
+// hostNames resolves MAC addresses to the names which are configured in the
+// network overview of the FRITZ!Box. Names which are configured statically
+// take precedence over the names of the FRITZ!Box.
+type hostNames struct {
+	logger    *zap.Logger
+	lookup    bool              // fetch names from the FRITZ!Box
+	overrides map[string]string // static names by upper case MAC address
+
+	mu      sync.Mutex
+	names   map[string]string // host name by upper case MAC address
+	fetched time.Time
+}
+
+func newHostNames(logger *zap.Logger, lookup bool, overrides map[string]string) *hostNames {
+	h := &hostNames{
+		logger:    logger,
+		lookup:    lookup,
+		overrides: make(map[string]string, len(overrides)),
+	}
+
+	for mac, name := range overrides {
+		h.overrides[strings.ToUpper(mac)] = name
+	}
+
+	return h
+}
+
+// refresh fetches the host list from the FRITZ!Box if the cached names are
+// outdated.
+func (h *hostNames) refresh(ctx context.Context, client *tr064.Client) error {
+	if !h.lookup {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if time.Since(h.fetched) < hostNamesTTL {
+		return nil
+	}
+
+	hosts, err := client.Hosts(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.names = make(map[string]string, len(hosts))
+	for _, host := range hosts {
+		if host.MACAddress != "" && host.HostName != "" {
+			h.names[strings.ToUpper(host.MACAddress)] = host.HostName
+		}
+	}
+
+	h.fetched = time.Now()
+	h.logger.Debug("Updated host names", zap.Int("hosts", len(h.names)))
+	return nil
+}
+
+// Name returns the name of the host with the given MAC address. If the name is
+// unknown the MAC address is returned instead.
+func (h *hostNames) Name(mac string) string {
+	if h == nil {
+		return mac
+	}
+
+	mac = strings.ToUpper(mac)
+	if name, ok := h.overrides[mac]; ok {
+		return name
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if name, ok := h.names[mac]; ok {
+		return name
+	}
+
+	return mac
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/tr064"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type HostMetrics struct {
+	NewDevices    prometheus.Counter   // hosts which have never been seen before
+	NewDeviceInfo *prometheus.GaugeVec // details of all new hosts since fritz-mon started
+	Connected     *prometheus.GaugeVec // Hosts NewActive by connection type
+	LinkSpeed     *prometheus.GaugeVec // host list X_AVM-DE_Speed or the WLAN speed
+	Signal        *prometheus.GaugeVec // WLANConfiguration NewX_AVM-DE_SignalStrength
+
+	KnownHostsFile  string     // optional file to persist the known MAC addresses across restarts
+	AlertNewDevices bool       // log a warning whenever a new host is detected
+	Names           *hostNames // optional resolver for static host names
+
+	logger *zap.Logger
+	known  map[string]time.Time // time of first sighting by upper case MAC address
+}
+
+func NewHostMetrics(logger *zap.Logger) *HostMetrics {
+	namespace := "fritzbox"
+	subsystem := "network"
+
+	return &HostMetrics{
+		logger: logger,
+		NewDevices: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "new_devices_total",
+				Help:      "Number of devices which were seen in the network of the FRITZ!Box for the first time.",
+			},
+		),
+		NewDeviceInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "new_device_info",
+				Help:      "Always 1. The labels describe a device which was seen in the network for the first time.",
+			},
+			[]string{"mac", "name", "interface"},
+		),
+		Connected: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "host",
+				Name:      "connected_bool",
+				Help:      "Either 0 or 1 to indicate if the host is currently connected to the FRITZ!Box.",
+			},
+			[]string{"mac", "name", "connection"},
+		),
+		LinkSpeed: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "host",
+				Name:      "link_speed_mbps",
+				Help:      "Current link speed of the host in Mbit/s.",
+			},
+			[]string{"mac", "name"},
+		),
+		Signal: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: "host",
+				Name:      "signal_strength_percent",
+				Help:      "WLAN signal strength of the host.",
+			},
+			[]string{"mac", "name"},
+		),
+	}
+}
+
+func (m *HostMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.NewDevices,
+		m.NewDeviceInfo,
+		m.Connected,
+		m.LinkSpeed,
+		m.Signal,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FetchFrom reads all hosts which are known to the FRITZ!Box and counts the
+// ones which have never been seen before. If there is no known hosts file
+// yet, the first call only remembers all current hosts so existing devices
+// are not reported as new.
+func (m *HostMetrics) FetchFrom(ctx context.Context, client *tr064.Client) ([]tr064.Host, error) {
+	hosts, err := client.Hosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wlanClients, err := client.WLANClients(ctx)
+	if err != nil {
+		m.logger.Warn("Failed to fetch WLAN clients", zap.Error(err))
+	}
+
+	m.collectHosts(hosts, wlanClients)
+
+	if m.known == nil {
+		err = m.loadKnownHosts()
+		if err != nil {
+			m.logger.Warn("Failed to load known hosts", zap.Error(err))
+		}
+	}
+
+	initial := m.known == nil
+	if initial {
+		m.known = map[string]time.Time{}
+	}
+
+	var newHosts int
+	for _, host := range hosts {
+		mac := strings.ToUpper(host.MACAddress)
+		if mac == "" {
+			continue
+		}
+
+		if _, ok := m.known[mac]; ok {
+			continue
+		}
+
+		m.known[mac] = time.Now()
+		if !initial {
+			newHosts++
+			m.collectNewHost(host)
+		}
+	}
+
+	if initial {
+		m.logger.Info("Initialized known hosts", zap.Int("hosts", len(m.known)))
+	}
+
+	if (initial || newHosts > 0) && m.KnownHostsFile != "" {
+		err = m.saveKnownHosts()
+		if err != nil {
+			m.logger.Error("Failed to write known hosts file", zap.String("path", m.KnownHostsFile), zap.Error(err))
+		}
+	}
+
+	m.logger.Debug("Collected host metrics",
+		zap.Int("hosts", len(hosts)),
+		zap.Int("new_hosts", newHosts),
+	)
+	return hosts, nil
+}
+
+// collectHosts exports the connection state of all hosts. WLAN clients are
+// matched by their MAC address to determine their band and signal strength.
+func (m *HostMetrics) collectHosts(hosts []tr064.Host, wlanClients []tr064.WLANClient) {
+	wlan := map[string]tr064.WLANClient{}
+	for _, c := range wlanClients {
+		wlan[strings.ToUpper(c.MACAddress)] = c
+	}
+
+	// Names and connection types may change so we rebuild all series.
+	m.Connected.Reset()
+	m.LinkSpeed.Reset()
+	m.Signal.Reset()
+
+	for _, host := range hosts {
+		if host.MACAddress == "" {
+			continue
+		}
+
+		name := m.hostName(host)
+		wlanClient, isWLANClient := wlan[strings.ToUpper(host.MACAddress)]
+		connection := hostConnection(host, wlanClient, isWLANClient)
+
+		m.Connected.WithLabelValues(host.MACAddress, name, connection).Set(prometheusBool(host.Active))
+		if !host.Active {
+			continue
+		}
+
+		speed := host.Speed
+		if isWLANClient {
+			speed = wlanClient.Speed
+			m.Signal.WithLabelValues(host.MACAddress, name).Set(float64(wlanClient.SignalStrength))
+		}
+		if speed > 0 {
+			m.LinkSpeed.WithLabelValues(host.MACAddress, name).Set(float64(speed))
+		}
+	}
+}
+
+// hostConnection returns how the host is connected to the FRITZ!Box: "lan",
+// "wlan_2.4GHz", "wlan_5GHz" (or other bands) or "other".
+func hostConnection(host tr064.Host, wlanClient tr064.WLANClient, isWLANClient bool) string {
+	switch {
+	case isWLANClient:
+		return "wlan_" + wlanClient.Band
+	case host.IsWLAN():
+		return "wlan"
+	case host.InterfaceType == "Ethernet":
+		return "lan"
+	default:
+		return "other"
+	}
+}
+
+// hostName returns the static name of the host, its name in the FRITZ!Box or
+// its MAC address.
+func (m *HostMetrics) hostName(host tr064.Host) string {
+	if name := m.Names.Name(host.MACAddress); name != host.MACAddress {
+		return name
+	}
+	if host.HostName != "" {
+		return host.HostName
+	}
+	return host.MACAddress
+}
+
+func (m *HostMetrics) collectNewHost(host tr064.Host) {
+	name := m.hostName(host)
+
+	m.NewDevices.Inc()
+	m.NewDeviceInfo.WithLabelValues(host.MACAddress, name, host.InterfaceType).Set(1)
+
+	if m.AlertNewDevices {
+		m.logger.Warn("New device detected in network",
+			zap.String("mac", host.MACAddress),
+			zap.String("name", name),
+			zap.String("ip", host.IPAddress),
+			zap.String("interface", host.InterfaceType),
+		)
+	}
+}
+
+// knownHosts is the content of the known hosts file.
+type knownHosts struct {
+	Hosts map[string]time.Time `json:"hosts"` // time of first sighting by MAC address
+}
+
+func (m *HostMetrics) loadKnownHosts() error {
+	if m.KnownHostsFile == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(m.KnownHostsFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read known hosts file: %w", err)
+	}
+
+	var file knownHosts
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return fmt.Errorf("failed to parse known hosts file: %w", err)
+	}
+
+	m.known = map[string]time.Time{}
+	for mac, firstSeen := range file.Hosts {
+		m.known[strings.ToUpper(mac)] = firstSeen
+	}
+
+	return nil
+}
+
+func (m *HostMetrics) saveKnownHosts() error {
+	data, err := json.MarshalIndent(knownHosts{Hosts: m.known}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(m.KnownHostsFile, data)
+}
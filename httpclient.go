@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Settings of the connection pool to the FRITZ!Box. All requests of a client
+// go to a single host so we keep enough idle connections around for all
+// collectors that may run at the same time. This avoids a new TCP (and TLS)
+// handshake for each request which is noticeably slow on older boxes.
+const (
+	maxConnsPerHost = 8
+	idleConnTimeout = 60 * time.Second
+)
+
+// newHTTPClient creates the HTTP client that is used to talk to the FRITZ!Box.
+func newHTTPClient(conf FritzBoxConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxConnsPerHost
+	transport.MaxIdleConnsPerHost = maxConnsPerHost
+	transport.MaxConnsPerHost = maxConnsPerHost
+	transport.IdleConnTimeout = idleConnTimeout
+	transport.ForceAttemptHTTP2 = false // the FRITZ!Box only speaks HTTP/1.1
+	transport.DisableCompression = true // responses are small, compressing them only costs CPU on the FRITZ!Box
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+
+	if conf.CAFile != "" {
+		pem, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to read CA certificate: %s does not contain any PEM encoded certificate", conf.CAFile)
+		}
+
+		transport.TLSClientConfig.RootCAs = pool
+	}
+
+	if conf.TLSFingerprint != "" {
+		fingerprint, err := parseFingerprint(conf.TLSFingerprint)
+		if err != nil {
+			return nil, err
+		}
+
+		// The FRITZ!Box uses a self-signed certificate unless a Let's Encrypt
+		// certificate was requested via MyFRITZ. Instead of disabling the
+		// verification completely we pin the certificate by its fingerprint.
+		transport.TLSClientConfig.InsecureSkipVerify = true
+		transport.TLSClientConfig.VerifyPeerCertificate = verifyFingerprint(fingerprint)
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   30 * time.Second,
+	}, nil
+}
+
+// parseFingerprint parses a hex encoded SHA-256 fingerprint. Colons between
+// the bytes (as shown by browsers and openssl) are ignored.
+func parseFingerprint(s string) ([]byte, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ":", "")
+	fingerprint, err := hex.DecodeString(s)
+	if err != nil || len(fingerprint) != sha256.Size {
+		return nil, fmt.Errorf("invalid tls_fingerprint: must be a hex encoded SHA-256 hash")
+	}
+
+	return fingerprint, nil
+}
+
+func verifyFingerprint(expected []byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("FRITZ!Box did not present a TLS certificate")
+		}
+
+		actual := sha256.Sum256(rawCerts[0])
+		if !strings.EqualFold(hex.EncodeToString(actual[:]), hex.EncodeToString(expected)) {
+			return fmt.Errorf("TLS certificate fingerprint of FRITZ!Box does not match tls_fingerprint (got %x)", actual)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+func TestHTTPClientReusesConnections(t *testing.T) {
+	deviceList, err := ioutil.ReadFile(filepath.Join("fritzbox", "testdata", "devicelist_dect200.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login_sid.lua", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<SessionInfo><SID>0123456789abcdef</SID><Challenge>1234567z</Challenge><BlockTime>0</BlockTime></SessionInfo>`))
+	})
+	mux.HandleFunc("/webservices/homeautoswitch.lua", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(deviceList)
+	})
+
+	var connections int32
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	httpClient, err := newHTTPClient(FritzBoxConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := fritzbox.New(srv.URL, "user", "password", zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetHTTPClient(httpClient)
+
+	for i := 0; i < 5; i++ {
+		devices, err := client.Devices(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(devices) != 1 {
+			t.Fatalf("got %d devices, want 1", len(devices))
+		}
+	}
+
+	if n := atomic.LoadInt32(&connections); n != 1 {
+		t.Errorf("client opened %d connections, want 1", n)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// keepSessionAlive periodically refreshes the session of the FRITZ!Box API
+// while none of the collectors accesses it, so long collection intervals do
+// not require a new login on every run.
+func (s *Server) keepSessionAlive(ctx context.Context, wg *sync.WaitGroup, box *Box) {
+	defer wg.Done()
+
+	interval := s.Config.SessionKeepAlive
+	ticker := newTicker(ctx, interval, interval)
+	for {
+		select {
+		case <-ticker:
+			err := box.FritzBox.KeepAlive(ctx, interval)
+			if err != nil && ctx.Err() == nil {
+				box.Logger.Warn("Failed to keep FRITZ!Box session alive", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+
+	"github.com/fgrosse/fritz-mon/tr064"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type LANMetrics struct {
+	BytesSent       prometheus.Gauge // LANEthernetInterfaceConfig NewBytesSent
+	BytesReceived   prometheus.Gauge // LANEthernetInterfaceConfig NewBytesReceived
+	PacketsSent     prometheus.Gauge // LANEthernetInterfaceConfig NewPacketsSent
+	PacketsReceived prometheus.Gauge // LANEthernetInterfaceConfig NewPacketsReceived
+
+	logger *zap.Logger
+}
+
+func NewLANMetrics(logger *zap.Logger) *LANMetrics {
+	namespace := "fritzbox"
+	subsystem := "lan"
+
+	return &LANMetrics{
+		logger: logger,
+		BytesSent: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bytes_sent_total",
+				Help:      "Number of bytes sent via the LAN interfaces since the last reboot of the FRITZ!Box.",
+			},
+		),
+		BytesReceived: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bytes_received_total",
+				Help:      "Number of bytes received via the LAN interfaces since the last reboot of the FRITZ!Box.",
+			},
+		),
+		PacketsSent: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "packets_sent_total",
+				Help:      "Number of packets sent via the LAN interfaces since the last reboot of the FRITZ!Box.",
+			},
+		),
+		PacketsReceived: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "packets_received_total",
+				Help:      "Number of packets received via the LAN interfaces since the last reboot of the FRITZ!Box.",
+			},
+		),
+	}
+}
+
+func (m *LANMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.BytesSent,
+		m.BytesReceived,
+		m.PacketsSent,
+		m.PacketsReceived,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *LANMetrics) FetchFrom(ctx context.Context, client *tr064.Client) (*tr064.LANStatistics, error) {
+	stats, err := client.LANStatistics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.BytesSent.Set(float64(stats.BytesSent))
+	m.BytesReceived.Set(float64(stats.BytesReceived))
+	m.PacketsSent.Set(float64(stats.PacketsSent))
+	m.PacketsReceived.Set(float64(stats.PacketsReceived))
+
+	m.logger.Debug("Collected LAN metrics",
+		zap.Uint64("bytes_sent", stats.BytesSent),
+		zap.Uint64("bytes_received", stats.BytesReceived),
+	)
+	return stats, nil
+}
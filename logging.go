@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type LogConfig struct {
+	Format     string `yaml:"format"`      // "console" (default) or "json", e.g. for Loki or ELK
+	Level      string `yaml:"level"`       // "debug", "info" (default), "warn" or "error"
+	File       string `yaml:"file"`        // write logs to this file instead of stderr
+	MaxSizeMB  int    `yaml:"max_size_mb"` // rotate the file when it grows beyond this size, zero disables rotation
+	MaxBackups int    `yaml:"max_backups"` // number of rotated files which are kept
+}
+
+func (c LogConfig) Validate() error {
+	if c.Format != "console" && c.Format != "json" {
+		return fmt.Errorf("log.format must be console or json")
+	}
+
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return fmt.Errorf("log.level: %w", err)
+	}
+
+	if c.MaxSizeMB < 0 {
+		return fmt.Errorf("log.max_size_mb cannot be negative")
+	}
+	if c.MaxBackups < 0 {
+		return fmt.Errorf("log.max_backups cannot be negative")
+	}
+
+	return nil
+}
+
+// newLogger creates the logger of fritz-mon according to the configuration.
+func newLogger(conf LogConfig) (*zap.Logger, error) {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(conf.Level)); err != nil {
+		return nil, fmt.Errorf("bad log level: %w", err)
+	}
+
+	var enc zapcore.Encoder
+	switch conf.Format {
+	case "console":
+		enc = zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+			TimeKey:        "T",
+			LevelKey:       "L",
+			NameKey:        "N",
+			MessageKey:     "M",
+			LineEnding:     zapcore.DefaultLineEnding,
+			EncodeLevel:    zapcore.CapitalLevelEncoder,
+			EncodeTime:     zapcore.ISO8601TimeEncoder,
+			EncodeDuration: zapcore.StringDurationEncoder,
+			EncodeCaller:   zapcore.ShortCallerEncoder,
+		})
+	case "json":
+		enc = zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+			TimeKey:        "time",
+			LevelKey:       "level",
+			NameKey:        "logger",
+			MessageKey:     "msg",
+			LineEnding:     zapcore.DefaultLineEnding,
+			EncodeLevel:    zapcore.LowercaseLevelEncoder,
+			EncodeTime:     zapcore.ISO8601TimeEncoder,
+			EncodeDuration: zapcore.SecondsDurationEncoder,
+			EncodeCaller:   zapcore.ShortCallerEncoder,
+		})
+	default:
+		return nil, fmt.Errorf("unknown log format %q", conf.Format)
+	}
+
+	out := zapcore.Lock(os.Stderr)
+	if conf.File != "" {
+		f, err := openRotatingFile(conf.File, int64(conf.MaxSizeMB)<<20, conf.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		out = f
+	}
+
+	core := zapcore.NewCore(enc, out, level)
+	return zap.New(core, zap.ErrorOutput(zapcore.Lock(os.Stderr))), nil
+}
+
+// rotatingFile is a log file which is rotated when it grows beyond a maximum
+// size. The rotated files are named like the file with a suffix ".1", ".2",
+// etc. where ".1" is the most recent one. It is safe for concurrent use.
+type rotatingFile struct {
+	path       string
+	maxSize    int64 // zero disables rotation
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	r.file, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The file is missing if it could not be opened again after the last
+	// rotation, e.g. because the directory was not writable.
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file to ".1" after shifting the older backups
+// and opens a new file. The oldest backup is overwritten. The file at the
+// current path is opened again even if closing or rotating fails so logging
+// can continue.
+func (r *rotatingFile) rotate() error {
+	var err error
+	if closeErr := r.file.Close(); closeErr != nil {
+		err = fmt.Errorf("failed to close log file: %w", closeErr)
+	} else {
+		err = r.shiftBackups()
+	}
+
+	r.file = nil
+	if openErr := r.open(); openErr != nil {
+		return multierr.Append(err, openErr)
+	}
+
+	return err
+}
+
+func (r *rotatingFile) shiftBackups() error {
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove log file: %w", err)
+		}
+		return nil
+	}
+
+	for i := r.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	return nil
+}
+
+func (r *rotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	return r.file.Sync()
+}
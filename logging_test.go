@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFileReopensAfterFailedRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fritz-mon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "fritz-mon.log")
+	r, err := openRotatingFile(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Write([]byte("first line\n")); err != nil {
+		t.Fatalf("first write failed: %v", err)
+	}
+
+	// A non-empty directory in place of the backup lets the rename fail.
+	backup := path + ".1"
+	if err := os.MkdirAll(filepath.Join(backup, "blocked"), 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Write([]byte("second line\n")); err == nil {
+		t.Fatal("expected rotation to fail")
+	}
+	if err := r.Sync(); err != nil {
+		t.Fatalf("log file was not opened again after the failed rotation: %v", err)
+	}
+
+	if err := os.RemoveAll(backup); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("third line\n")); err != nil {
+		t.Fatalf("write after failed rotation failed: %v", err)
+	}
+
+	rotated, err := ioutil.ReadFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rotated) != "first line\n" {
+		t.Errorf("rotated file contains %q", rotated)
+	}
+
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != "third line\n" {
+		t.Errorf("log file contains %q", current)
+	}
+}
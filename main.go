@@ -2,24 +2,60 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
 func main() {
 	setup := flag.Bool("setup", false, "setup configuration file interactively")
-	verbose := flag.Bool("debug", false, "enable verbose log output")
+	verbose := flag.Bool("debug", false, "enable verbose log output (same as -log-level=debug)")
+	logFormat := flag.String("log-format", "", "log format, either console or json (overrides log.format)")
+	logLevel := flag.String("log-level", "", "log level, e.g. debug or warn (overrides log.level)")
 	config := flag.String("config", "fritz-mon.yml", "path to the configuration file")
 	flag.Parse()
 
+	// Commands only return their exit code so deferred calls run before main
+	// decides to exit.
+	switch flag.Arg(0) {
+	case "update":
+		os.Exit(runUpdate(flag.Args()[1:]))
+	case "stats":
+		os.Exit(runStats(flag.Args()[1:], *config))
+	case "switch":
+		os.Exit(runSwitch(flag.Args()[1:], *config))
+	case "template":
+		os.Exit(runTemplate(flag.Args()[1:], *config))
+	case "check":
+		os.Exit(runCheck(flag.Args()[1:], *config))
+	}
+
 	if *setup {
-		runSetup()
-		return
+		os.Exit(runSetup())
 	}
 
-	logger := newLogger(*verbose)
+	// The flags also apply while the configuration is loaded so errors in the
+	// configuration file are logged in the requested format.
+	logFlags := func(conf LogConfig) LogConfig {
+		if *logFormat != "" {
+			conf.Format = *logFormat
+		}
+		if *logLevel != "" {
+			conf.Level = *logLevel
+		}
+		if *verbose {
+			conf.Level = "debug"
+		}
+		return conf
+	}
+
+	logger, err := newLogger(logFlags(DefaultConfig().Log))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create logger: %v\n", err)
+		os.Exit(2)
+	}
 	defer func() { _ = logger.Sync() }()
 
 	conf, err := LoadConfiguration(*config, logger)
@@ -27,6 +63,20 @@ func main() {
 		logger.Fatal("Failed to load configuration", zap.Error(err))
 	}
 
+	conf.Log = logFlags(conf.Log)
+	err = conf.Log.Validate()
+	if err != nil {
+		logger.Fatal("Invalid log flags", zap.Error(err))
+	}
+
+	configured, err := newLogger(conf.Log)
+	if err != nil {
+		logger.Fatal("Failed to create logger", zap.Error(err))
+	}
+
+	_ = logger.Sync()
+	logger = configured
+
 	server, err := NewServer(conf, logger)
 	if err != nil {
 		logger.Fatal("Failed to create new server", zap.Error(err))
@@ -45,35 +95,16 @@ func main() {
 	logger.Info(`Shutdown complete. Have a nice day  \ʕ◔ϖ◔ʔ/`)
 }
 
-func newLogger(verbose bool) *zap.Logger {
-	level := zap.InfoLevel
-	if verbose {
-		level = zap.DebugLevel
-	}
-
-	cfg := zap.Config{
-		Level:       zap.NewAtomicLevelAt(level),
-		Development: false,
-		Encoding:    "console",
-		EncoderConfig: zapcore.EncoderConfig{
-			TimeKey:        "T",
-			LevelKey:       "L",
-			NameKey:        "N",
-			MessageKey:     "M",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.CapitalLevelEncoder,
-			EncodeTime:     zapcore.ISO8601TimeEncoder,
-			EncodeDuration: zapcore.StringDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		},
-		OutputPaths:      []string{"stderr"},
-		ErrorOutputPaths: []string{"stderr"},
+// parseFlags parses the flags of a command. If it returns false, the command
+// must return the exit code, e.g. 0 after -h printed the usage.
+func parseFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	err := flags.Parse(args)
+	switch {
+	case err == flag.ErrHelp:
+		return 0, false
+	case err != nil:
+		return 2, false
+	default:
+		return 0, true
 	}
-
-	logger, err := cfg.Build()
-	if err != nil {
-		panic(err)
-	}
-
-	return logger
 }
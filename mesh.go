@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+
+	"github.com/fgrosse/fritz-mon/tr064"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type MeshMetrics struct {
+	DataRate    *prometheus.GaugeVec // cur_data_rate_rx and cur_data_rate_tx of the uplink of each repeater
+	MaxDataRate *prometheus.GaugeVec // max_data_rate_rx and max_data_rate_tx of the uplink of each repeater
+	Connected   *prometheus.GaugeVec // state of the uplink of each repeater
+	Info        *prometheus.GaugeVec // medium of the uplink of each repeater
+
+	logger  *zap.Logger
+	mediums map[string]string // last medium by repeater MAC address
+}
+
+func NewMeshMetrics(logger *zap.Logger) *MeshMetrics {
+	namespace := "fritzbox"
+	subsystem := "mesh"
+	labels := []string{"repeater", "mac"}
+
+	return &MeshMetrics{
+		logger:  logger,
+		mediums: map[string]string{},
+		DataRate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "backhaul_data_rate_bps",
+				Help:      "Current data rate of the uplink of each mesh repeater in bit/s (by direction rx or tx as seen by the repeater).",
+			},
+			append(labels, "direction"),
+		),
+		MaxDataRate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "backhaul_max_data_rate_bps",
+				Help:      "Maximum data rate of the uplink of each mesh repeater in bit/s (by direction rx or tx as seen by the repeater).",
+			},
+			append(labels, "direction"),
+		),
+		Connected: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "backhaul_connected_bool",
+				Help:      "Either 0 or 1 to indicate if the uplink of the mesh repeater is connected.",
+			},
+			labels,
+		),
+		Info: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "backhaul_info",
+				Help:      "Always 1. The labels describe the medium (ethernet or the WLAN band) of the uplink of each mesh repeater and the node it is connected to.",
+			},
+			append(labels, "medium", "peer"),
+		),
+	}
+}
+
+func (m *MeshMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.DataRate,
+		m.MaxDataRate,
+		m.Connected,
+		m.Info,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FetchFrom reads the mesh list of the FRITZ!Box and exports the uplink of
+// each mesh repeater. A change of the medium is logged, e.g. when a repeater
+// which is connected via Ethernet falls back to a WLAN uplink.
+func (m *MeshMetrics) FetchFrom(ctx context.Context, client *tr064.Client) ([]tr064.Backhaul, error) {
+	backhauls, err := client.MeshBackhauls(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Repeaters may be removed and the medium and peer of the uplinks may
+	// change, so we only ever export the current state.
+	m.DataRate.Reset()
+	m.MaxDataRate.Reset()
+	m.Connected.Reset()
+	m.Info.Reset()
+
+	for _, b := range backhauls {
+		m.DataRate.WithLabelValues(b.Repeater, b.MACAddress, "rx").Set(float64(b.RateRx) * 1000)
+		m.DataRate.WithLabelValues(b.Repeater, b.MACAddress, "tx").Set(float64(b.RateTx) * 1000)
+		m.MaxDataRate.WithLabelValues(b.Repeater, b.MACAddress, "rx").Set(float64(b.MaxRateRx) * 1000)
+		m.MaxDataRate.WithLabelValues(b.Repeater, b.MACAddress, "tx").Set(float64(b.MaxRateTx) * 1000)
+		m.Connected.WithLabelValues(b.Repeater, b.MACAddress).Set(prometheusBool(b.Connected))
+		m.Info.WithLabelValues(b.Repeater, b.MACAddress, b.Medium, b.ConnectedNode).Set(1)
+
+		if last, ok := m.mediums[b.MACAddress]; ok && last != b.Medium {
+			m.logger.Warn("Uplink of mesh repeater changed",
+				zap.String("repeater", b.Repeater),
+				zap.String("old_medium", last),
+				zap.String("new_medium", b.Medium),
+			)
+		}
+		m.mediums[b.MACAddress] = b.Medium
+	}
+
+	m.logger.Debug("Collected mesh metrics", zap.Int("repeaters", len(backhauls)))
+	return backhauls, nil
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
+	"strconv"
+	"time"
 
 	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/fgrosse/fritz-mon/tr064"
 	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 )
@@ -13,17 +17,87 @@ import (
 type Metrics struct {
 	Devices *DeviceMetrics
 	Network *NetworkMetrics
+	System  *SystemMetrics
+	WAN     *WANMetrics
+	Hosts   *HostMetrics
+	Events  *EventLogMetrics
+	WLAN    *WLANMetrics
+	LAN     *LANMetrics
+	Mobile  *MobileMetrics
+	Fiber   *FiberMetrics
+	DOCSIS  *DOCSISMetrics
+	Mesh    *MeshMetrics
+
+	GuestWLANWatchdog *GuestWLANWatchdog
 }
 
 type DeviceMetrics struct {
 	IsConnected *prometheus.GaugeVec
 	IsPoweredOn *prometheus.GaugeVec
 	Temperature *prometheus.GaugeVec
+	Humidity    *prometheus.GaugeVec
+	Battery     *prometheus.GaugeVec
 	Power       *prometheus.GaugeVec
 	Voltage     *prometheus.GaugeVec
-	Energy      *prometheus.GaugeVec
+	Energy      *counterVec
 
-	logger *zap.Logger
+	BoostActive   *prometheus.GaugeVec
+	BoostEndTime  *prometheus.GaugeVec
+	HolidayActive *prometheus.GaugeVec
+	SummerActive  *prometheus.GaugeVec
+
+	Level           *prometheus.GaugeVec
+	ColorHue        *prometheus.GaugeVec
+	ColorSaturation *prometheus.GaugeVec
+	ColorTemp       *prometheus.GaugeVec
+
+	BlindPosition        *prometheus.GaugeVec
+	BlindEndPositionsSet *prometheus.GaugeVec
+
+	Alert             *prometheus.GaugeVec
+	LastAlert         *prometheus.GaugeVec
+	ButtonLastPressed *prometheus.GaugeVec
+
+	SwitchOnTransitions *prometheus.CounterVec
+	HeatingDegreeDays   *prometheus.CounterVec
+	LastUpdate          prometheus.Gauge
+	Partial             prometheus.Gauge
+
+	TargetTemperature    *prometheus.GaugeVec
+	TemperatureDeviation *prometheus.GaugeVec
+	OutsideTolerance     *prometheus.CounterVec
+	WindowOpen           *prometheus.CounterVec
+
+	EnergyUsage    *prometheus.GaugeVec // from the statistics of the FRITZ!Box, see StatsInterval
+	TemperatureMin *prometheus.GaugeVec
+	TemperatureMax *prometheus.GaugeVec
+
+	TemplateInfo    *prometheus.GaugeVec // smart home templates of the FRITZ!Box, see collectTemplates
+	TemplateDevices *prometheus.GaugeVec
+
+	Standby  *StandbyKiller  // switches off devices in standby according to the configured rules
+	Pipeline *devicePipeline // runs follow-up requests for individual devices after the device list was fetched
+
+	StateFile              string        // optional file to persist the latest readings across restarts
+	HeatingBaseTemperature float64       // base temperature in °C for the heating degree days
+	ThermostatTolerance    float64       // deviation from the target temperature in °C which is still on schedule
+	FullLogInterval        time.Duration // only debug log changed readings in between, zero logs all readings every time
+	StatsInterval          time.Duration // how long the statistics of each device are reused until they are fetched again, zero disables
+	ExpireAfter            int           // remove the series of devices missing from this many collections in a row, zero keeps them
+	DefaultName            string        // template of the name of devices which were not named in the FRITZ!Box UI, empty keeps the empty name
+
+	logger              *zap.Logger
+	labels              deviceLabels
+	pendingEnergy       map[string][2]float64 // energy readings and offsets from the state file by device name
+	switchState         map[string]bool       // last observed switch state by device name
+	lastDegreeDayUpdate map[string]time.Time  // time of the last temperature reading by device name
+
+	lastThermostatUpdate map[string]time.Time // time of the last thermostat reading by device name
+	lastWindowUpdate     map[string]time.Time // time of the last window state reading by device name
+
+	loggedReadings map[string]map[string]float64 // last readings which were logged by device name
+	lastFullLog    time.Time                     // time at which all readings were logged the last time
+	knownDevices   map[string]*knownDevice       // devices of the previous collections by label values
 }
 
 type NetworkMetrics struct {
@@ -36,26 +110,77 @@ type NetworkMetrics struct {
 	UpstreamLowPriority     prometheus.Gauge // us_background_bps_curr
 	UpstreamGuest           prometheus.Gauge // guest_us_bps
 
+	Bytes        *prometheus.CounterVec // sum of all buckets
+	MinBandwidth *prometheus.GaugeVec   // minimum of the buckets since the last collection
+	AvgBandwidth *prometheus.GaugeVec   // average of the buckets since the last collection
+	MaxBandwidth *prometheus.GaugeVec   // maximum of the buckets since the last collection
+
+	logger     *zap.Logger
+	lastBucket time.Time // end of the newest bucket which was already counted
+}
+
+type SystemMetrics struct {
+	Uptime    prometheus.Gauge // DeviceInfo NewUpTime
+	TimeDrift prometheus.Gauge // Time NewCurrentLocalTime compared to the local clock
+	Waiting   prometheus.Gauge // set while the FRITZ!Box appears to be rebooting
+
+	LoginBlocked      prometheus.Gauge // login_sid.lua BlockTime
+	UnexpectedLogouts *counterVec      // sessions which were invalidated before they timed out
+
 	logger *zap.Logger
 }
 
-func NewMetrics(logger *zap.Logger) *Metrics {
+type WANMetrics struct {
+	IsConnected      prometheus.Gauge       // WANIPConnection NewConnectionStatus
+	ConnectionUptime prometheus.Gauge       // WANIPConnection NewUptime
+	IPChanges        *prometheus.CounterVec // changes of NewExternalIPAddress and NewExternalIPv6Address
+	UplinkInfo       *prometheus.GaugeVec   // WANCommonInterfaceConfig NewWANAccessType
+	BytesSent        *counterVec            // WANCommonInterfaceConfig NewX_AVM_DE_TotalBytesSent64
+	BytesReceived    *counterVec            // WANCommonInterfaceConfig NewX_AVM_DE_TotalBytesReceived64
+	ExternalIP       *prometheus.GaugeVec   // whether the FRITZ!Box has an external address by protocol
+	DSLSyncRate      *prometheus.GaugeVec   // WANDSLInterfaceConfig NewUpstreamCurrRate and NewDownstreamCurrRate
+	DSLMaxRate       *prometheus.GaugeVec   // WANDSLInterfaceConfig NewUpstreamMaxRate and NewDownstreamMaxRate
+	DSLNoiseMargin   *prometheus.GaugeVec   // WANDSLInterfaceConfig NewUpstreamNoiseMargin and NewDownstreamNoiseMargin
+	DSLAttenuation   *prometheus.GaugeVec   // WANDSLInterfaceConfig NewUpstreamAttenuation and NewDownstreamAttenuation
+
+	LogIPChanges bool // log old and new addresses whenever an IP change is detected
+
+	logger      *zap.Logger
+	lastAddress map[string]string // last known external IP address by protocol
+}
+
+func NewMetrics(logger *zap.Logger, labels deviceLabels) *Metrics {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
 
+	// Each group of metrics gets its own child logger so every log line of a
+	// collector can be attributed to it.
 	return &Metrics{
-		Devices: NewDeviceMetrics(logger),
-		Network: NewNetworkMetrics(logger),
+		Devices: NewDeviceMetrics(logger.With(zap.String("collector", "devices")), labels),
+		Network: NewNetworkMetrics(logger.With(zap.String("collector", "network"))),
+		System:  NewSystemMetrics(logger.With(zap.String("collector", "system"))),
+		WAN:     NewWANMetrics(logger.With(zap.String("collector", "wan"))),
+		Hosts:   NewHostMetrics(logger.With(zap.String("collector", "hosts"))),
+		Events:  NewEventLogMetrics(logger.With(zap.String("collector", "event_log"))),
+		WLAN:    NewWLANMetrics(logger.With(zap.String("collector", "wlan"))),
+		LAN:     NewLANMetrics(logger.With(zap.String("collector", "lan"))),
+		Mobile:  NewMobileMetrics(logger.With(zap.String("collector", "mobile"))),
+		Fiber:   NewFiberMetrics(logger.With(zap.String("collector", "fiber"))),
+		DOCSIS:  NewDOCSISMetrics(logger.With(zap.String("collector", "docsis"))),
+		Mesh:    NewMeshMetrics(logger.With(zap.String("collector", "mesh"))),
+
+		GuestWLANWatchdog: NewGuestWLANWatchdog(logger.With(zap.String("collector", "guest_wlan_watchdog"))),
 	}
 }
 
-func NewDeviceMetrics(logger *zap.Logger) *DeviceMetrics {
+func NewDeviceMetrics(logger *zap.Logger, labels deviceLabels) *DeviceMetrics {
 	namespace := "fritzbox"
 	subsystem := "home_automation"
-	labelNames := []string{"device_name"}
+	labelNames := labels.names()
 	return &DeviceMetrics{
 		logger: logger,
+		labels: labels,
 		IsConnected: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -83,6 +208,24 @@ func NewDeviceMetrics(logger *zap.Logger) *DeviceMetrics {
 			},
 			labelNames,
 		),
+		Humidity: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "humidity_percent",
+				Help:      "Relative humidity measured at the device sensor in percent.",
+			},
+			labelNames,
+		),
+		Battery: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "battery_percent",
+				Help:      "Charge level of the battery of the device in percent.",
+			},
+			labelNames,
+		),
 		Power: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -101,8 +244,8 @@ func NewDeviceMetrics(logger *zap.Logger) *DeviceMetrics {
 			},
 			labelNames,
 		),
-		Energy: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
+		Energy: newCounterVec(
+			prometheus.CounterOpts{
 				Namespace: namespace,
 				Subsystem: subsystem,
 				Name:      "energy_watthours_total",
@@ -110,12 +253,257 @@ func NewDeviceMetrics(logger *zap.Logger) *DeviceMetrics {
 			},
 			labelNames,
 		),
+		BoostActive: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "thermostat_boost_active_bool",
+				Help:      "Either 0 or 1 to indicate if the boost mode of the thermostat is active.",
+			},
+			labelNames,
+		),
+		BoostEndTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "thermostat_boost_end_timestamp_seconds",
+				Help:      "Unix timestamp at which the boost mode of the thermostat ends or 0 if it is not active.",
+			},
+			labelNames,
+		),
+		HolidayActive: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "thermostat_holiday_active_bool",
+				Help:      "Either 0 or 1 to indicate if a holiday period of the thermostat is active.",
+			},
+			labelNames,
+		),
+		SummerActive: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "thermostat_summer_active_bool",
+				Help:      "Either 0 or 1 to indicate if the summer period (heating off) of the thermostat is active.",
+			},
+			labelNames,
+		),
+		Level: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "level_percent",
+				Help:      "Level of the device in percent, e.g. the brightness of a light bulb.",
+			},
+			labelNames,
+		),
+		ColorHue: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "color_hue_degrees",
+				Help:      "Hue of a light bulb in degrees if it is in the hue/saturation color mode.",
+			},
+			labelNames,
+		),
+		ColorSaturation: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "color_saturation_percent",
+				Help:      "Saturation of a light bulb in percent if it is in the hue/saturation color mode.",
+			},
+			labelNames,
+		),
+		ColorTemp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "color_temperature_kelvin",
+				Help:      "Color temperature of a light bulb in Kelvin if it is in the color temperature mode.",
+			},
+			labelNames,
+		),
+		BlindPosition: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "blind_position_percent",
+				Help:      "Position of a blind in percent from 0 (open) to 100 (closed).",
+			},
+			labelNames,
+		),
+		BlindEndPositionsSet: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "blind_end_positions_set_bool",
+				Help:      "Either 0 or 1 to indicate if the end positions of a blind are calibrated.",
+			},
+			labelNames,
+		),
+		Alert: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "alert_bool",
+				Help:      "Either 0 or 1 to indicate if an alert sensor (e.g. a door/window contact or a smoke detector) currently reports an alert.",
+			},
+			labelNames,
+		),
+		LastAlert: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "last_alert_timestamp_seconds",
+				Help:      "Unix timestamp of the last change of the alert state of an alert sensor.",
+			},
+			labelNames,
+		),
+		ButtonLastPressed: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "button_last_pressed_timestamp_seconds",
+				Help:      "Unix timestamp at which a button of the device was last pressed.",
+			},
+			append(labels.names(), "button"),
+		),
+		SwitchOnTransitions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "switch_on_transitions_total",
+				Help:      "Number of observed transitions of a switch from off to on.",
+			},
+			labelNames,
+		),
+		HeatingDegreeDays: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "heating_degree_days_total",
+				Help:      "Accumulated heating degree days computed from the temperature measured by the thermostat.",
+			},
+			labelNames,
+		),
+		TargetTemperature: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "thermostat_target_temperature_celsius",
+				Help:      "Current target temperature of the thermostat in degree Celsius.",
+			},
+			labelNames,
+		),
+		TemperatureDeviation: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "thermostat_temperature_deviation_celsius",
+				Help:      "Difference between the measured and the target temperature of the thermostat in degree Celsius.",
+			},
+			labelNames,
+		),
+		OutsideTolerance: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "thermostat_outside_tolerance_seconds_total",
+				Help:      "Time in seconds the measured temperature of the thermostat deviated from the target temperature by more than the tolerance.",
+			},
+			labelNames,
+		),
+		WindowOpen: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "thermostat_window_open_seconds_total",
+				Help:      "Time in seconds the thermostat reported an open window.",
+			},
+			labelNames,
+		),
+		EnergyUsage: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "energy_usage_watthours",
+				Help:      "Energy consumption in Watt hours per period (today, yesterday, this_month or last_month) according to the statistics of the FRITZ!Box.",
+			},
+			append(labelNames[:len(labelNames):len(labelNames)], "period"),
+		),
+		TemperatureMin: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "temperature_min_24h_celsius",
+				Help:      "Lowest temperature of the last 24 hours in degree Celsius according to the statistics of the FRITZ!Box.",
+			},
+			labelNames,
+		),
+		TemperatureMax: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "temperature_max_24h_celsius",
+				Help:      "Highest temperature of the last 24 hours in degree Celsius according to the statistics of the FRITZ!Box.",
+			},
+			labelNames,
+		),
+		TemplateInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "template_info",
+				Help:      "Always 1. The labels describe a smart home template of the FRITZ!Box.",
+			},
+			[]string{"template", "ain"},
+		),
+		TemplateDevices: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "template_devices",
+				Help:      "Number of devices and groups which are changed by a smart home template.",
+			},
+			[]string{"template", "ain"},
+		),
+		LastUpdate: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "last_update_timestamp_seconds",
+				Help:      "Unix timestamp of the last successful collection of device metrics. Readings restored from the state file keep their original timestamp.",
+			},
+		),
+		Partial: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "partial_collection_bool",
+				Help:      "Either 0 or 1 to indicate if the last device collection exceeded its deadline and only contains the devices received until then.",
+			},
+		),
+		Standby:                NewStandbyKiller(logger, labels),
+		Pipeline:               newDevicePipeline(devicePool{Workers: defaultDeviceWorkers, Timeout: defaultDeviceRequestTimeout}),
+		HeatingBaseTemperature: defaultHeatingBaseTemperature,
+		ThermostatTolerance:    defaultThermostatTolerance,
+		ExpireAfter:            defaultExpireDevicesAfter,
+		switchState:            map[string]bool{},
+		pendingEnergy:          map[string][2]float64{},
+		lastDegreeDayUpdate:    map[string]time.Time{},
+		lastThermostatUpdate:   map[string]time.Time{},
+		lastWindowUpdate:       map[string]time.Time{},
+		loggedReadings:         map[string]map[string]float64{},
+		knownDevices:           map[string]*knownDevice{},
 	}
 }
 
 func NewNetworkMetrics(logger *zap.Logger) *NetworkMetrics {
 	namespace := "fritzbox"
 	subsystem := "network"
+	trafficLabelNames := []string{"direction", "class"}
 
 	return &NetworkMetrics{
 		logger: logger,
@@ -183,29 +571,272 @@ func NewNetworkMetrics(logger *zap.Logger) *NetworkMetrics {
 				Help:      "Guest network upstream in bits per second.",
 			},
 		),
+		Bytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bytes_total",
+				Help:      "Number of bytes transferred by traffic class as derived from the traffic monitoring of the FRITZ!Box.",
+			},
+			trafficLabelNames,
+		),
+		MinBandwidth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "min_bps",
+				Help:      "Minimum bandwidth in bits per second within the last collection interval.",
+			},
+			trafficLabelNames,
+		),
+		AvgBandwidth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "avg_bps",
+				Help:      "Average bandwidth in bits per second within the last collection interval.",
+			},
+			trafficLabelNames,
+		),
+		MaxBandwidth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "max_bps",
+				Help:      "Maximum bandwidth in bits per second within the last collection interval.",
+			},
+			trafficLabelNames,
+		),
 	}
 }
 
-func (m *Metrics) Register(r prometheus.Registerer) error {
-	if err := m.Devices.Register(r); err != nil {
-		return err
+func NewSystemMetrics(logger *zap.Logger) *SystemMetrics {
+	namespace := "fritzbox"
+	subsystem := "system"
+
+	return &SystemMetrics{
+		logger: logger,
+		Uptime: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "uptime_seconds",
+				Help:      "Time in seconds since the last reboot of the FRITZ!Box.",
+			},
+		),
+		TimeDrift: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "time_drift_seconds",
+				Help:      "Difference between the system time of the FRITZ!Box and the local clock of fritz-mon in seconds. Positive values mean the FRITZ!Box is ahead.",
+			},
+		),
+		Waiting: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "waiting_for_box_bool",
+				Help:      "Either 0 or 1 to indicate if fritz-mon is waiting for the FRITZ!Box to come back, e.g. because it is rebooting.",
+			},
+		),
+		LoginBlocked: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "login_blocked_seconds",
+				Help:      "Time in seconds for which the FRITZ!Box still refuses to log in fritz-mon after failed logins or 0 if it is not blocked.",
+			},
+		),
+		UnexpectedLogouts: newCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "unexpected_logouts_total",
+				Help:      "Number of sessions of fritz-mon which the FRITZ!Box invalidated before they timed out, e.g. because another client logged in with the same account.",
+			},
+			nil,
+		),
+	}
+}
+
+func NewWANMetrics(logger *zap.Logger) *WANMetrics {
+	namespace := "fritzbox"
+	subsystem := "wan"
+
+	return &WANMetrics{
+		logger: logger,
+		IsConnected: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "connected_bool",
+				Help:      "Either 0 or 1 to indicate if the FRITZ!Box is connected to the internet.",
+			},
+		),
+		ConnectionUptime: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "connection_uptime_seconds",
+				Help:      "Time in seconds since the internet connection was (re-)established.",
+			},
+		),
+		IPChanges: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "ip_changes_total",
+				Help:      "Number of observed changes of the external IP address.",
+			},
+			[]string{"protocol"},
+		),
+		UplinkInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "uplink_info",
+				Help:      "Always 1. The labels describe how the FRITZ!Box is connected to the internet (dsl, cable, fiber, lte or ethernet).",
+			},
+			[]string{"type", "access_type"},
+		),
+		BytesSent: newCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bytes_sent_total",
+				Help:      "Number of bytes sent via the internet connection. The FRITZ!Box resets its counter when it reboots, which is carried over while fritz-mon runs.",
+			},
+			nil,
+		),
+		BytesReceived: newCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bytes_received_total",
+				Help:      "Number of bytes received via the internet connection. The FRITZ!Box resets its counter when it reboots, which is carried over while fritz-mon runs.",
+			},
+			nil,
+		),
+		ExternalIP: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "external_ip_present_bool",
+				Help:      "Either 0 or 1 to indicate if the FRITZ!Box has an external IP address.",
+			},
+			[]string{"protocol"},
+		),
+		DSLSyncRate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "dsl_sync_rate_bits_per_second",
+				Help:      "Current sync rate of the DSL line.",
+			},
+			[]string{"direction"},
+		),
+		DSLMaxRate: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "dsl_max_rate_bits_per_second",
+				Help:      "Maximum attainable rate of the DSL line.",
+			},
+			[]string{"direction"},
+		),
+		DSLNoiseMargin: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "dsl_snr_margin_db",
+				Help:      "Signal-to-noise ratio margin of the DSL line in dB.",
+			},
+			[]string{"direction"},
+		),
+		DSLAttenuation: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "dsl_attenuation_db",
+				Help:      "Attenuation of the DSL line in dB.",
+			},
+			[]string{"direction"},
+		),
+		lastAddress: map[string]string{},
 	}
+}
 
-	if err := m.Network.Register(r); err != nil {
-		return err
+func (m *Metrics) Register(r prometheus.Registerer) error {
+	groups := m.groups()
+	for _, name := range collectorNames {
+		if err := groups[name].Register(r); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+type metricsGroup interface {
+	Register(prometheus.Registerer) error
+}
+
+// groups returns all metric groups by the name of the collector which updates them.
+func (m *Metrics) groups() map[string]metricsGroup {
+	return map[string]metricsGroup{
+		"devices":   m.Devices,
+		"network":   m.Network,
+		"system":    m.System,
+		"wan":       m.WAN,
+		"event_log": m.Events,
+		"wlan":      m.WLAN,
+		"lan":       m.LAN,
+		"mobile":    m.Mobile,
+		"fiber":     m.Fiber,
+		"docsis":    m.DOCSIS,
+		"mesh":      m.Mesh,
+		"hosts":     m.Hosts,
+
+		"guest_wlan_watchdog": m.GuestWLANWatchdog,
+	}
+}
+
 func (m *DeviceMetrics) Register(r prometheus.Registerer) error {
 	metrics := []prometheus.Collector{
 		m.IsPoweredOn,
 		m.IsConnected,
 		m.Temperature,
+		m.Humidity,
+		m.Battery,
 		m.Power,
 		m.Voltage,
 		m.Energy,
+		m.BoostActive,
+		m.BoostEndTime,
+		m.HolidayActive,
+		m.SummerActive,
+		m.Level,
+		m.ColorHue,
+		m.ColorSaturation,
+		m.ColorTemp,
+		m.BlindPosition,
+		m.BlindEndPositionsSet,
+		m.Alert,
+		m.LastAlert,
+		m.ButtonLastPressed,
+		m.SwitchOnTransitions,
+		m.HeatingDegreeDays,
+		m.TargetTemperature,
+		m.TemperatureDeviation,
+		m.OutsideTolerance,
+		m.WindowOpen,
+		m.EnergyUsage,
+		m.TemperatureMin,
+		m.TemperatureMax,
+		m.TemplateInfo,
+		m.TemplateDevices,
+		m.Standby.SwitchOffs,
+		m.LastUpdate,
+		m.Partial,
 	}
 
 	for _, metric := range metrics {
@@ -227,6 +858,28 @@ func (m *NetworkMetrics) Register(r prometheus.Registerer) error {
 		m.UpstreamDefaultPriority,
 		m.UpstreamLowPriority,
 		m.UpstreamGuest,
+		m.Bytes,
+		m.MinBandwidth,
+		m.AvgBandwidth,
+		m.MaxBandwidth,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *SystemMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.Uptime,
+		m.TimeDrift,
+		m.Waiting,
+		m.LoginBlocked,
+		m.UnexpectedLogouts,
 	}
 
 	for _, metric := range metrics {
@@ -238,59 +891,280 @@ func (m *NetworkMetrics) Register(r prometheus.Registerer) error {
 	return nil
 }
 
-func (m *DeviceMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client) error {
+func (m *WANMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.IsConnected,
+		m.ConnectionUptime,
+		m.IPChanges,
+		m.UplinkInfo,
+		m.BytesSent,
+		m.BytesReceived,
+		m.ExternalIP,
+		m.DSLSyncRate,
+		m.DSLMaxRate,
+		m.DSLNoiseMargin,
+		m.DSLAttenuation,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *DeviceMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client) ([]fritzbox.Device, error) {
 	devices, err := client.Devices(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to fetch devices from the FRITZ!Box API: %w", err)
+	partial := errors.Is(err, fritzbox.ErrPartial)
+	if err != nil && !partial {
+		return nil, fmt.Errorf("failed to fetch devices from the FRITZ!Box API: %w", err)
+	}
+
+	if m.DefaultName != "" {
+		for i := range devices {
+			if devices[i].Name == "" {
+				devices[i].Name = defaultDeviceName(m.DefaultName, devices[i])
+			}
+		}
 	}
 
+	readings := make(map[string]map[string]float64, len(devices))
+	var labels map[string]map[string]string // only needed for the state file
+	if m.StateFile != "" {
+		labels = make(map[string]map[string]string, len(devices))
+	}
 	for _, device := range devices {
-		m.collectDeviceMetrics(device)
+		readings[device.Name] = m.collectDeviceMetrics(device)
+		if labels != nil {
+			labels[device.Name] = deviceLabelValues(device)
+		}
 	}
 
-	return nil
+	m.logReadings(readings, time.Now())
+
+	m.LastUpdate.SetToCurrentTime()
+	m.Partial.Set(prometheusBool(partial))
+
+	// The remaining steps would need the FRITZ!Box again or overwrite the
+	// state of the missing devices, so a partial collection ends here.
+	if partial {
+		m.logger.Warn("Device collection exceeded its deadline, exporting partial results", zap.Error(err))
+		return devices, nil
+	}
+
+	m.expireDevices(devices)
+
+	// Follow-up requests for individual devices run after all readings were
+	// exported so slow devices do not delay the metrics of the others.
+	deviceErrs := m.Standby.Apply(ctx, client, devices, m.Pipeline.Pool)
+	deviceErrs = deviceErrs.merge(m.Pipeline.run(ctx, devices, m.deviceTasks(client)))
+	m.collectTemplates(ctx, client)
+
+	if m.StateFile != "" {
+		err := m.saveState(m.StateFile, readings, labels)
+		if err != nil {
+			m.logger.Error("Failed to write state file", zap.String("path", m.StateFile), zap.Error(err))
+		}
+	}
+
+	if len(deviceErrs) > 0 {
+		return devices, deviceErrs
+	}
+
+	return devices, nil
 }
 
-func (m *DeviceMetrics) collectDeviceMetrics(device fritzbox.Device) {
+func (m *DeviceMetrics) collectDeviceMetrics(device fritzbox.Device) map[string]float64 {
 	collectedMetrics := map[string]float64{}
-	m.IsConnected.WithLabelValues(device.Name).Set(float64(device.Present))
+	labels := m.labels.values(device)
+	m.IsConnected.WithLabelValues(labels...).Set(float64(device.Present))
 	collectedMetrics["is_connected"] = float64(device.Present)
 
+	var temp float64
 	if device.CanMeasureTemperature() {
-		temp := device.Temperature.GetCelsius()
-		m.Temperature.WithLabelValues(device.Name).Set(temp)
+		temp = device.Temperature.GetCelsius()
+		m.Temperature.WithLabelValues(labels...).Set(temp)
 		collectedMetrics["temperature_celsius"] = temp
 	}
 
+	if device.CanMeasureHumidity() {
+		if humidity, ok := device.Humidity.GetRelativeHumidity(); ok {
+			m.Humidity.WithLabelValues(labels...).Set(humidity)
+			collectedMetrics["humidity_percent"] = humidity
+		}
+	}
+
+	if battery, ok := device.GetBatteryPercent(); ok {
+		m.Battery.WithLabelValues(labels...).Set(battery)
+		collectedMetrics["battery_percent"] = battery
+	}
+
 	if device.CanMeasurePower() {
 		volt := device.Power.GetVoltage()
 		power := device.Power.GetPower()
-		energy := device.Power.GetEnergy()
 
-		m.Voltage.WithLabelValues(device.Name).Set(volt)
+		m.Voltage.WithLabelValues(labels...).Set(volt)
 		collectedMetrics["voltage_volt"] = volt
 
-		m.Power.WithLabelValues(device.Name).Set(power)
+		m.Power.WithLabelValues(labels...).Set(power)
 		collectedMetrics["power_watts"] = power
 
-		m.Energy.WithLabelValues(device.Name).Set(energy)
-		collectedMetrics["energy_watt_hours_total"] = energy
+		// The state file keeps the reading of the FRITZ!Box together with
+		// the offset of previous counter resets so both can be restored.
+		if pending, ok := m.pendingEnergy[device.Name]; ok {
+			m.Energy.Restore(pending[0], pending[1], labels...)
+			delete(m.pendingEnergy, device.Name)
+		}
+
+		// A device which is not connected reports no energy at all. This
+		// must not be observed as a counter reset since the counter would
+		// otherwise carry over its last reading once the device is back.
+		if energy, ok := device.Power.GetEnergy(); ok && device.Present == 1 {
+			m.Energy.Observe(energy, labels...)
+			collectedMetrics["energy_watt_hours_total"] = energy
+			if offset := m.Energy.Offset(labels...); offset > 0 {
+				collectedMetrics["energy_watt_hours_offset"] = offset
+			}
+		}
 	}
 
 	if device.IsSwitch() {
 		isPowered := prometheusBool(device.Switch.IsPoweredOn())
-		m.IsPoweredOn.WithLabelValues(device.Name).Set(isPowered)
+		m.IsPoweredOn.WithLabelValues(labels...).Set(isPowered)
+		collectedMetrics["is_powered"] = isPowered
+
+		// Transitions can only be detected between two collections so
+		// switching a device on and off again in between is not counted.
+		transitions := m.SwitchOnTransitions.WithLabelValues(labels...)
+		wasPowered, known := m.switchState[device.Name]
+		if known && !wasPowered && device.Switch.IsPoweredOn() {
+			transitions.Inc()
+		}
+		m.switchState[device.Name] = device.Switch.IsPoweredOn()
+	}
+
+	if device.IsThermostat() {
+		boost := prometheusBool(device.Thermostat.IsBoostActive())
+		m.BoostActive.WithLabelValues(labels...).Set(boost)
+		collectedMetrics["boost_active"] = boost
+
+		var boostEnd float64
+		if t := device.Thermostat.GetBoostEndTime(); !t.IsZero() {
+			boostEnd = float64(t.Unix())
+		}
+		m.BoostEndTime.WithLabelValues(labels...).Set(boostEnd)
+		collectedMetrics["boost_end_timestamp_seconds"] = boostEnd
+
+		holiday := prometheusBool(device.Thermostat.IsHolidayActive())
+		m.HolidayActive.WithLabelValues(labels...).Set(holiday)
+		collectedMetrics["holiday_active"] = holiday
+
+		summer := prometheusBool(device.Thermostat.IsSummerActive())
+		m.SummerActive.WithLabelValues(labels...).Set(summer)
+		collectedMetrics["summer_active"] = summer
+
+		if device.Present == 1 {
+			now := time.Now()
+			if device.CanMeasureTemperature() {
+				m.collectDegreeDays(device, temp, now)
+			}
+			m.collectThermostatSchedule(device, now)
+			m.collectWindowOpen(device, now)
+		}
+	}
+
+	// Switches report their state via the switch element which is handled
+	// above, all other devices that can be switched on and off (e.g. light
+	// bulbs) via the HAN-FUN on/off unit.
+	if device.CanSwitchOnOff() && !device.IsSwitch() {
+		isPowered := prometheusBool(device.OnOffState.IsOn())
+		m.IsPoweredOn.WithLabelValues(labels...).Set(isPowered)
 		collectedMetrics["is_powered"] = isPowered
 	}
 
-	logFields := metricsToLogFields(device.Name, collectedMetrics)
-	m.logger.Debug("Collected device metrics", logFields...)
+	// The level of a blind is its position so it gets its own metric.
+	if device.IsBlind() {
+		if position, ok := device.Level.GetLevelPercentage(); ok {
+			m.BlindPosition.WithLabelValues(labels...).Set(position)
+			collectedMetrics["blind_position_percent"] = position
+		}
+
+		endPositionsSet := prometheusBool(device.BlindState.AreEndPositionsSet())
+		m.BlindEndPositionsSet.WithLabelValues(labels...).Set(endPositionsSet)
+		collectedMetrics["blind_end_positions_set"] = endPositionsSet
+	} else if device.HasLevel() {
+		if level, ok := device.Level.GetLevelPercentage(); ok {
+			m.Level.WithLabelValues(labels...).Set(level)
+			collectedMetrics["level_percent"] = level
+		}
+	}
+
+	if device.HasColor() {
+		m.collectColor(device, labels, collectedMetrics)
+	}
+
+	if device.IsAlertSensor() {
+		if alert, known := device.AlertSensor.IsAlerting(); known {
+			v := prometheusBool(alert)
+			m.Alert.WithLabelValues(labels...).Set(v)
+			collectedMetrics["alert"] = v
+		}
+
+		if t := device.AlertSensor.GetLastChange(); !t.IsZero() {
+			m.LastAlert.WithLabelValues(labels...).Set(float64(t.Unix()))
+			collectedMetrics["last_alert_timestamp_seconds"] = float64(t.Unix())
+		}
+	}
+
+	for i, button := range device.Buttons {
+		if t := button.GetLastPressed(); !t.IsZero() {
+			m.ButtonLastPressed.WithLabelValues(append(labels, buttonName(button, i))...).Set(float64(t.Unix()))
+		}
+	}
+
+	return collectedMetrics
+}
+
+// collectColor exports the color of a light bulb. Only the values of the
+// current color mode are exported since the others are not in effect.
+func (m *DeviceMetrics) collectColor(device fritzbox.Device, labels []string, collectedMetrics map[string]float64) {
+	if hue, saturation, ok := device.Color.GetHueSaturation(); ok {
+		m.ColorHue.WithLabelValues(labels...).Set(hue)
+		m.ColorSaturation.WithLabelValues(labels...).Set(saturation)
+		collectedMetrics["color_hue_degrees"] = hue
+		collectedMetrics["color_saturation_percent"] = saturation
+	} else {
+		m.ColorHue.DeleteLabelValues(labels...)
+		m.ColorSaturation.DeleteLabelValues(labels...)
+	}
+
+	if kelvin, ok := device.Color.GetTemperature(); ok {
+		m.ColorTemp.WithLabelValues(labels...).Set(kelvin)
+		collectedMetrics["color_temperature_kelvin"] = kelvin
+	} else {
+		m.ColorTemp.DeleteLabelValues(labels...)
+	}
 }
 
-func (m *NetworkMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client) error {
+// buttonName returns the label of the i-th button of a device. Buttons which
+// are not named in the FRITZ!Box are labeled by their identifier or index.
+func buttonName(button fritzbox.ButtonInfo, i int) string {
+	switch {
+	case button.Name != "":
+		return button.Name
+	case button.Identifier != "":
+		return button.Identifier
+	default:
+		return strconv.Itoa(i + 1)
+	}
+}
+
+func (m *NetworkMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client) (*fritzbox.TrafficMonitoringData, error) {
 	stats, err := client.NetworkStats(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	m.DownstreamInternet.Set(stats.DownstreamInternet[0] * 8)
@@ -302,8 +1176,153 @@ func (m *NetworkMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client)
 	m.UpstreamLowPriority.Set(stats.UpstreamLowPriority[0] * 8)
 	m.UpstreamGuest.Set(stats.UpstreamGuest[0] * 8)
 
+	m.collectTraffic(stats, time.Now())
+
 	m.logger.Debug("Collected network metrics")
-	return nil
+	return stats, nil
+}
+
+// SystemReadings contains the raw data collected by SystemMetrics.FetchFrom.
+type SystemReadings struct {
+	Info      *tr064.DeviceInfo
+	Time      time.Time     // system time of the FRITZ!Box
+	TimeDrift time.Duration // difference between Time and the local clock
+}
+
+func (m *SystemMetrics) FetchFrom(ctx context.Context, client *tr064.Client) (*SystemReadings, error) {
+	info, err := client.DeviceInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Uptime.Set(float64(info.UpTime))
+
+	// The request itself takes some time so we compare the time of the
+	// FRITZ!Box with the middle of the request/response round trip.
+	start := time.Now()
+	boxTime, err := client.CurrentTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	localTime := start.Add(time.Since(start) / 2)
+	drift := boxTime.Sub(localTime)
+	m.TimeDrift.Set(drift.Seconds())
+
+	m.logger.Debug("Collected system metrics",
+		zap.Int64("uptime_seconds", info.UpTime),
+		zap.Duration("time_drift", drift),
+	)
+	return &SystemReadings{Info: info, Time: boxTime, TimeDrift: drift}, nil
+}
+
+// WANReadings contains the raw data collected by WANMetrics.FetchFrom.
+type WANReadings struct {
+	Status       *tr064.ConnectionStatus
+	Link         *tr064.LinkProperties
+	ExternalIPv4 string
+	ExternalIPv6 string
+	Traffic      *tr064.WANTraffic
+	DSL          *tr064.DSLInfo `json:",omitempty"` // only set if the FRITZ!Box is connected via DSL
+}
+
+func (m *WANMetrics) FetchFrom(ctx context.Context, client *tr064.Client) (*WANReadings, error) {
+	status, err := client.ConnectionStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.IsConnected.Set(prometheusBool(status.IsConnected()))
+	m.ConnectionUptime.Set(float64(status.Uptime))
+
+	link, err := client.LinkProperties(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.UplinkInfo.Reset() // the uplink may change e.g. on LTE fallback
+	m.UplinkInfo.WithLabelValues(link.UplinkType(), link.AccessType).Set(1)
+
+	ipv4, ipv6, err := client.ExternalIPAddresses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.observeAddress("ipv4", ipv4)
+	m.observeAddress("ipv6", ipv6)
+	m.ExternalIP.WithLabelValues("ipv4").Set(prometheusBool(ipv4 != ""))
+	m.ExternalIP.WithLabelValues("ipv6").Set(prometheusBool(ipv6 != ""))
+
+	traffic, err := client.WANTraffic(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.BytesSent.Observe(float64(traffic.BytesSent))
+	m.BytesReceived.Observe(float64(traffic.BytesReceived))
+
+	readings := &WANReadings{
+		Status:       status,
+		Link:         link,
+		ExternalIPv4: ipv4,
+		ExternalIPv6: ipv6,
+		Traffic:      traffic,
+	}
+
+	if link.UplinkType() == "dsl" {
+		readings.DSL, err = client.DSLInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		m.collectDSLMetrics(readings.DSL)
+	}
+
+	m.logger.Debug("Collected WAN metrics",
+		zap.String("connection_status", status.Status),
+		zap.Int64("connection_uptime_seconds", status.Uptime),
+		zap.String("uplink_type", link.UplinkType()),
+		zap.Uint64("bytes_sent", traffic.BytesSent),
+		zap.Uint64("bytes_received", traffic.BytesReceived),
+	)
+	return readings, nil
+}
+
+func (m *WANMetrics) collectDSLMetrics(dsl *tr064.DSLInfo) {
+	// The FRITZ!Box reports rates in kbit/s and levels in 0.1 dB.
+	m.DSLSyncRate.WithLabelValues("upstream").Set(float64(dsl.UpstreamCurrRate) * 1000)
+	m.DSLSyncRate.WithLabelValues("downstream").Set(float64(dsl.DownstreamCurrRate) * 1000)
+	m.DSLMaxRate.WithLabelValues("upstream").Set(float64(dsl.UpstreamMaxRate) * 1000)
+	m.DSLMaxRate.WithLabelValues("downstream").Set(float64(dsl.DownstreamMaxRate) * 1000)
+	m.DSLNoiseMargin.WithLabelValues("upstream").Set(float64(dsl.UpstreamNoiseMargin) / 10)
+	m.DSLNoiseMargin.WithLabelValues("downstream").Set(float64(dsl.DownstreamNoiseMargin) / 10)
+	m.DSLAttenuation.WithLabelValues("upstream").Set(float64(dsl.UpstreamAttenuation) / 10)
+	m.DSLAttenuation.WithLabelValues("downstream").Set(float64(dsl.DownstreamAttenuation) / 10)
+}
+
+// observeAddress counts a change of the external IP address. An empty address
+// (e.g. while the connection is down) is not considered a change so that a
+// reconnect with the same address is not counted either.
+func (m *WANMetrics) observeAddress(protocol, addr string) {
+	m.IPChanges.WithLabelValues(protocol) // make sure the series exists before the first change
+	if addr == "" {
+		return
+	}
+
+	last := m.lastAddress[protocol]
+	m.lastAddress[protocol] = addr
+	if last == "" || last == addr {
+		return
+	}
+
+	m.IPChanges.WithLabelValues(protocol).Inc()
+	if m.LogIPChanges {
+		m.logger.Info("External IP address changed",
+			zap.String("protocol", protocol),
+			zap.String("old", last),
+			zap.String("new", addr),
+		)
+	}
 }
 
 func prometheusBool(value bool) float64 {
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.uber.org/zap"
+)
+
+func TestCollectDeviceMetricsEnergyOfAbsentDevice(t *testing.T) {
+	m := NewDeviceMetrics(zap.NewNop(), defaultDeviceLabels)
+
+	device := fritzbox.Device{
+		Identifier:         "08761 0000434",
+		CapabilitiesBitmap: "35712",
+		ProductName:        "FRITZ!DECT 200",
+		Name:               "Washing machine",
+	}
+
+	steps := []struct {
+		present int
+		energy  string
+		want    float64
+	}{
+		{present: 1, energy: "707", want: 707},
+		{present: 0, energy: "", want: 707}, // absent devices have an empty <energy>
+		{present: 1, energy: "710", want: 710},
+		{present: 1, energy: "5", want: 715}, // the device was re-paired
+	}
+
+	for i, step := range steps {
+		device.Present = step.present
+		device.Power.Energy = step.energy
+		m.collectDeviceMetrics(device)
+
+		if got := testutil.ToFloat64(m.Energy); got != step.want {
+			t.Errorf("step %d: energy = %v, want %v", i, got, step.want)
+		}
+	}
+}
+
+func TestWANBytesAreCounters(t *testing.T) {
+	m := NewWANMetrics(zap.NewNop())
+
+	m.BytesSent.Observe(4000)
+	m.BytesSent.Observe(100) // the FRITZ!Box rebooted
+
+	want := `
+# HELP fritzbox_wan_bytes_sent_total Number of bytes sent via the internet connection. The FRITZ!Box resets its counter when it reboots, which is carried over while fritz-mon runs.
+# TYPE fritzbox_wan_bytes_sent_total counter
+fritzbox_wan_bytes_sent_total 4100
+`
+	if err := testutil.CollectAndCompare(m.BytesSent, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestCollectDeviceMetricsFixtures checks the metrics of the device list
+// fixtures of the fritzbox package.
+func TestCollectDeviceMetricsFixtures(t *testing.T) {
+	cases := map[string]map[string]float64{
+		"devicelist_dect200.xml": {
+			"is_connected":            1,
+			"is_powered":              1,
+			"power_watts":             1.24,
+			"voltage_volt":            229.891,
+			"energy_watt_hours_total": 75382,
+			"temperature_celsius":     21.5,
+		},
+		"devicelist_dect200_absent.xml": {
+			"is_connected":        0,
+			"is_powered":          0,
+			"power_watts":         0,
+			"voltage_volt":        0,
+			"temperature_celsius": 0,
+		},
+		"devicelist_dect301.xml": {
+			"is_connected":                1,
+			"battery_percent":             80,
+			"temperature_celsius":         20.5,
+			"boost_active":                0,
+			"boost_end_timestamp_seconds": 0,
+			"holiday_active":              0,
+			"summer_active":               0,
+		},
+		"devicelist_dect440.xml": {
+			"is_connected":        1,
+			"battery_percent":     100,
+			"temperature_celsius": 19.5,
+			"humidity_percent":    48,
+		},
+		"devicelist_dect500.xml": {
+			"is_connected":             1,
+			"is_powered":               1,
+			"level_percent":            58,
+			"color_hue_degrees":        358,
+			"color_saturation_percent": 180.0 / 255 * 100,
+		},
+		"devicelist_hanfun_contact.xml": {
+			"is_connected":                 1,
+			"alert":                        1,
+			"last_alert_timestamp_seconds": 1612880211,
+		},
+		"devicelist_blind.xml": {
+			"is_connected":            1,
+			"alert":                   0,
+			"blind_position_percent":  25,
+			"blind_end_positions_set": 1,
+		},
+	}
+
+	for file, want := range cases {
+		devices := loadDeviceList(t, file)
+		if len(devices) != 1 {
+			t.Fatalf("%s: got %d devices, want 1", file, len(devices))
+		}
+
+		m := NewDeviceMetrics(zap.NewNop(), defaultDeviceLabels)
+		got := m.collectDeviceMetrics(devices[0])
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\ngot  %v\nwant %v", file, got, want)
+		}
+	}
+}
+
+// loadDeviceList reads a device list fixture of the fritzbox package.
+func loadDeviceList(tb testing.TB, file string) []fritzbox.Device {
+	tb.Helper()
+
+	b, err := ioutil.ReadFile(filepath.Join("fritzbox", "testdata", file))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	var list fritzbox.DeviceList
+	if err := xml.Unmarshal(b, &list); err != nil {
+		tb.Fatalf("%s: %v", file, err)
+	}
+
+	return list.Devices
+}
+
+func BenchmarkCollectDeviceMetrics(b *testing.B) {
+	devices := loadDeviceList(b, "devicelist_50.xml")
+	m := NewDeviceMetrics(zap.NewNop(), defaultDeviceLabels)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, d := range devices {
+			m.collectDeviceMetrics(d)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type MobileMetrics struct {
+	RSRP     prometheus.Gauge
+	RSRQ     prometheus.Gauge
+	SINR     prometheus.Gauge
+	CellInfo *prometheus.GaugeVec
+
+	logger *zap.Logger
+}
+
+func NewMobileMetrics(logger *zap.Logger) *MobileMetrics {
+	namespace := "fritzbox"
+	subsystem := "mobile"
+
+	return &MobileMetrics{
+		logger: logger,
+		RSRP: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "rsrp_dbm",
+				Help:      "Reference Signal Received Power of the serving cell in dBm.",
+			},
+		),
+		RSRQ: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "rsrq_db",
+				Help:      "Reference Signal Received Quality of the serving cell in dB.",
+			},
+		),
+		SINR: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "sinr_db",
+				Help:      "Signal to Interference plus Noise Ratio of the serving cell in dB.",
+			},
+		),
+		CellInfo: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "cell_info",
+				Help:      "Always 1. The labels describe the serving cell of the mobile connection.",
+			},
+			[]string{"technology", "band", "cell_id"},
+		),
+	}
+}
+
+func (m *MobileMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.RSRP,
+		m.RSRQ,
+		m.SINR,
+		m.CellInfo,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MobileMetrics) FetchFrom(ctx context.Context, client *fritzbox.Client) (*fritzbox.MobileStatus, error) {
+	status, err := client.MobileStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.RSRP.Set(status.RSRP)
+	m.RSRQ.Set(status.RSRQ)
+	m.SINR.Set(status.SINR)
+
+	m.CellInfo.Reset() // only ever export the current cell
+	m.CellInfo.WithLabelValues(status.Technology, status.Band, status.CellID).Set(1)
+
+	m.logger.Debug("Collected mobile metrics",
+		zap.String("technology", status.Technology),
+		zap.String("band", status.Band),
+		zap.String("cell_id", status.CellID),
+		zap.Float64("rsrp_dbm", status.RSRP),
+		zap.Float64("rsrq_db", status.RSRQ),
+		zap.Float64("sinr_db", status.SINR),
+	)
+	return status, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// wanCollectors contains the names of all collectors which require the
+// FRITZ!Box to have its own internet connection.
+var wanCollectors = []string{"network", "wan", "mobile", "fiber", "docsis"}
+
+func isWANCollector(name string) bool {
+	for _, n := range wanCollectors {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// modeDetectionTimeout limits how long we wait for the FRITZ!Box when
+// detecting its operation mode at startup.
+const modeDetectionTimeout = 30 * time.Second
+
+// isIPClient returns true if the FRITZ!Box is configured or detected to run as
+// IP client or mesh repeater. Such a box does not serve any WAN or DSL
+// information so the corresponding collectors would fail on every run.
+func (b *Box) isIPClient(ctx context.Context) bool {
+	switch b.Config.Mode {
+	case ModeRouter:
+		return false
+	case ModeIPClient:
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, modeDetectionTimeout)
+	defer cancel()
+
+	ipClient, err := b.TR064.IsIPClient(ctx)
+	if err != nil {
+		b.Logger.Warn("Failed to detect operation mode of FRITZ!Box, assuming it is a router", zap.Error(err))
+		return false
+	}
+
+	if ipClient {
+		b.Logger.Info("FRITZ!Box runs as IP client, WAN metrics will not be collected")
+	}
+
+	return ipClient
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+)
+
+// errNoTimeLeft is returned for a request which was not started because the
+// collection deadline would not leave enough time for it.
+var errNoTimeLeft = errors.New("not enough time left until the collection deadline")
+
+// deviceTask is a request for individual smart home devices which runs after
+// the device list was fetched, e.g. fetching the statistics of each device.
+type deviceTask struct {
+	name   string                                                                 // e.g. "device statistics", used in errors and as part of the cache key
+	ttl    time.Duration                                                          // how long a result is reused, zero fetches on every collection
+	filter func(device fritzbox.Device) bool                                      // devices for which the task runs
+	fetch  func(ctx context.Context, device fritzbox.Device) (interface{}, error) // requests the data of a single device
+	apply  func(device fritzbox.Device, data interface{})                         // updates the metrics, must be safe for concurrent use
+}
+
+// devicePipeline runs the tasks for individual devices through the shared
+// worker pool. Results are cached per device for the TTL of the task, so
+// devices which were added recently are fetched at the next collection while
+// the others are not fetched again until their results expire. Each result is
+// applied as soon as it arrives, so the metrics of fast devices are updated
+// even if the collection deadline cuts off slow ones. Requests which would not
+// finish before the deadline are not started at all and are retried at the
+// next collection.
+type devicePipeline struct {
+	Pool devicePool
+
+	mu    sync.Mutex
+	cache map[cacheKey]cachedResult
+}
+
+type cacheKey struct {
+	task string
+	ain  string
+}
+
+type cachedResult struct {
+	data    interface{}
+	fetched time.Time
+}
+
+func newDevicePipeline(pool devicePool) *devicePipeline {
+	return &devicePipeline{Pool: pool, cache: map[cacheKey]cachedResult{}}
+}
+
+// run runs all tasks for the given devices and returns the failed requests by
+// device name. Cached results are applied again without a request.
+func (p *devicePipeline) run(ctx context.Context, devices []fritzbox.Device, tasks []deviceTask) DeviceErrors {
+	p.prune(devices)
+
+	var failed DeviceErrors
+	for _, task := range tasks {
+		var due []fritzbox.Device
+		for _, device := range devices {
+			if !task.filter(device) {
+				continue
+			}
+
+			if cached, ok := p.cached(task, device); ok {
+				task.apply(device, cached)
+				continue
+			}
+
+			due = append(due, device)
+		}
+
+		errs := p.Pool.run(ctx, due, func(ctx context.Context, device fritzbox.Device) error {
+			if !p.timeLeft(ctx) {
+				return errNoTimeLeft
+			}
+
+			data, err := task.fetch(ctx, device)
+			if err != nil {
+				return err
+			}
+
+			p.store(task, device, data)
+			task.apply(device, data)
+			return nil
+		})
+
+		for i, err := range errs {
+			if err == nil || errors.Is(err, errNoTimeLeft) {
+				continue
+			}
+			if failed == nil {
+				failed = DeviceErrors{}
+			}
+			failed[due[i].Name] = fmt.Errorf("failed to fetch %s: %w", task.name, err)
+		}
+	}
+
+	return failed
+}
+
+// timeLeft returns false if the deadline of ctx does not leave enough time for
+// another request.
+func (p *devicePipeline) timeLeft(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok || p.Pool.Timeout <= 0 {
+		return ctx.Err() == nil
+	}
+
+	return time.Until(deadline) >= p.Pool.Timeout/2
+}
+
+func (p *devicePipeline) cached(task deviceTask, device fritzbox.Device) (interface{}, bool) {
+	if task.ttl <= 0 {
+		return nil, false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	result, ok := p.cache[cacheKey{task.name, device.Identifier}]
+	if !ok || time.Since(result.fetched) >= task.ttl {
+		return nil, false
+	}
+
+	return result.data, true
+}
+
+func (p *devicePipeline) store(task deviceTask, device fritzbox.Device, data interface{}) {
+	if task.ttl <= 0 {
+		return
+	}
+
+	p.mu.Lock()
+	p.cache[cacheKey{task.name, device.Identifier}] = cachedResult{data: data, fetched: time.Now()}
+	p.mu.Unlock()
+}
+
+// prune removes the cached results of devices which are no longer part of the
+// device list.
+func (p *devicePipeline) prune(devices []fritzbox.Device) {
+	ains := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		ains[d.Identifier] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key := range p.cache {
+		if !ains[key.ain] {
+			delete(p.cache, key)
+		}
+	}
+}
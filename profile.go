@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Values of metrics.profile.
+const (
+	profileFull    metricsProfile = "full"
+	profileMinimal metricsProfile = "minimal"
+)
+
+// minimalMetrics contains the names of all metrics which are exported with the
+// minimal profile. It is a small set of series which covers whether the
+// FRITZ!Box and its devices are up, the power and temperature readings of the
+// smart home devices and the traffic totals.
+var minimalMetrics = map[string]bool{
+	"fritzbox_system_waiting_for_box_bool":           true,
+	"fritzbox_wan_connected_bool":                    true,
+	"fritzbox_home_automation_device_connected_bool": true,
+	"fritzbox_home_automation_power_watts":           true,
+	"fritzbox_home_automation_temperature_celsius":   true,
+	"fritzbox_network_bytes_total":                   true,
+	"fritzbox_wan_bytes_sent_total":                  true,
+	"fritzbox_wan_bytes_received_total":              true,
+	"fritzmon_healthy":                               true,
+}
+
+// metricsProfile selects which metrics are exported at /metrics and pushed to
+// the Pushgateway or via remote write. The minimal profile is meant for hosted
+// backends which limit the number of series.
+type metricsProfile string
+
+func (p metricsProfile) Validate() error {
+	switch p {
+	case profileFull, profileMinimal:
+		return nil
+	default:
+		return fmt.Errorf("metrics.profile: unknown profile %q (must be full or minimal)", p)
+	}
+}
+
+// gatherer returns g with all metrics removed which are not part of the
+// profile. The collectors still update all metrics, so e.g. the logs and the
+// JSON API are not affected by the profile.
+func (p metricsProfile) gatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	if p != profileMinimal {
+		return g
+	}
+
+	return filteredGatherer{Gatherer: g, names: minimalMetrics}
+}
+
+// filteredGatherer is a prometheus.Gatherer which only returns the metric
+// families with the given names.
+type filteredGatherer struct {
+	prometheus.Gatherer
+	names map[string]bool
+}
+
+func (g filteredGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+
+	filtered := families[:0]
+	for _, mf := range families {
+		if g.names[mf.GetName()] {
+			filtered = append(filtered, mf)
+		}
+	}
+
+	return filtered, err
+}
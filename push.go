@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"go.uber.org/zap"
+)
+
+type PushgatewayConfig struct {
+	URL      string            `yaml:"url"`      // e.g. http://pushgateway:9091, push is disabled if empty
+	Job      string            `yaml:"job"`      // job label of the pushed metrics
+	Grouping map[string]string `yaml:"grouping"` // additional grouping labels such as "instance"
+}
+
+func (c PushgatewayConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// pusher pushes all metrics to a Prometheus Pushgateway after each completed
+// collection. It is safe for concurrent use by multiple collectors.
+type pusher struct {
+	mu     sync.Mutex
+	pusher *push.Pusher
+	logger *zap.Logger
+}
+
+func newPusher(conf PushgatewayConfig, g prometheus.Gatherer, logger *zap.Logger) *pusher {
+	p := push.New(conf.URL, conf.Job).
+		Gatherer(g).
+		Client(&http.Client{Timeout: 10 * time.Second})
+
+	for name, value := range conf.Grouping {
+		p = p.Grouping(name, value)
+	}
+
+	return &pusher{pusher: p, logger: logger}
+}
+
+// Push replaces all metrics of the configured group at the Pushgateway.
+func (p *pusher) Push(collector string) {
+	p.mu.Lock()
+	err := p.pusher.Push()
+	p.mu.Unlock()
+
+	if err != nil {
+		p.logger.Error("Failed to push metrics to Pushgateway", zap.String("collector", collector), zap.Error(err))
+		return
+	}
+
+	p.logger.Debug("Pushed metrics to Pushgateway", zap.String("collector", collector))
+}
+
+func (c PushgatewayConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if c.Job == "" {
+		return fmt.Errorf("pushgateway.job cannot be empty")
+	}
+
+	return nil
+}
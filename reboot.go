@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+// availability tracks whether a FRITZ!Box is currently unavailable. While the
+// FRITZ!Box reboots, all collectors fail with the same errors, so instead of
+// logging each of them the box is put into a quiet "waiting for box" state
+// until any collection succeeds again.
+type availability struct {
+	mu           sync.Mutex
+	waiting      bool
+	waitingSince time.Time
+}
+
+// isRebootSymptom returns true if the error is characteristic for a FRITZ!Box
+// which is rebooting: the connection is refused while the services are not
+// yet up, or the FRITZ!Box no longer knows our session.
+func isRebootSymptom(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, fritzbox.ErrSessionInvalid)
+}
+
+// waitingForBox records a failed collection. It returns true if the error
+// indicates that the FRITZ!Box is rebooting and should not be logged.
+func (b *Box) waitingForBox(err error) bool {
+	if !isRebootSymptom(err) {
+		return false
+	}
+
+	a := &b.availability
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.waiting {
+		b.Logger.Warn("FRITZ!Box is not available, waiting for it to come back", zap.Error(err))
+		b.Metrics.System.Waiting.Set(1)
+		b.FritzBox.SetWaiting(true)
+		a.waiting = true
+		a.waitingSince = time.Now()
+	}
+
+	return true
+}
+
+// available must be called after each successful collection.
+func (b *Box) available() {
+	a := &b.availability
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.waiting {
+		return
+	}
+
+	b.Logger.Info("FRITZ!Box is available again",
+		zap.Duration("after", time.Since(a.waitingSince).Round(time.Second)),
+	)
+	b.Metrics.System.Waiting.Set(0)
+	b.FritzBox.SetWaiting(false)
+	a.waiting = false
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+)
+
+type RemoteWriteConfig struct {
+	URL      string        `yaml:"url"`      // e.g. https://prometheus.example.com/api/v1/write, remote write is disabled if empty
+	Username string        `yaml:"username"` // optional basic auth credentials
+	Password string        `yaml:"password"`
+	Timeout  time.Duration `yaml:"timeout"` // timeout of each HTTP request
+}
+
+func (c RemoteWriteConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+func (c RemoteWriteConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if u, err := url.Parse(c.URL); err != nil || u.Host == "" {
+		return fmt.Errorf("remote_write.url must be an absolute URL")
+	}
+	if c.Timeout <= 0 {
+		return fmt.Errorf("remote_write.timeout must be positive")
+	}
+
+	return nil
+}
+
+// remoteWriter sends all metrics via the Prometheus remote write protocol
+// after each completed collection. It is safe for concurrent use by multiple
+// collectors.
+type remoteWriter struct {
+	mu       sync.Mutex
+	conf     RemoteWriteConfig
+	gatherer prometheus.Gatherer
+	http     *http.Client
+	logger   *zap.Logger
+}
+
+func newRemoteWriter(conf RemoteWriteConfig, g prometheus.Gatherer, logger *zap.Logger) *remoteWriter {
+	return &remoteWriter{
+		conf:     conf,
+		gatherer: g,
+		http:     &http.Client{Timeout: conf.Timeout},
+		logger:   logger,
+	}
+}
+
+// Write sends the current value of all metrics to the remote write endpoint.
+func (w *remoteWriter) Write(ctx context.Context, collector string) {
+	// Remote write expects the samples of a series in order, so concurrent
+	// collections must not overtake each other.
+	w.mu.Lock()
+	err := w.write(ctx, time.Now())
+	w.mu.Unlock()
+
+	if err != nil {
+		w.logger.Error("Failed to send metrics via remote write", zap.String("collector", collector), zap.Error(err))
+		return
+	}
+
+	w.logger.Debug("Sent metrics via remote write", zap.String("collector", collector))
+}
+
+func (w *remoteWriter) write(ctx context.Context, now time.Time) error {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	series := timeSeriesOf(families, now)
+	body := snappyEncode(encodeWriteRequest(series))
+
+	req, err := http.NewRequest("POST", w.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if w.conf.Username != "" {
+		req.SetBasicAuth(w.conf.Username, w.conf.Password)
+	}
+
+	resp, err := w.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bad HTTP status code: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// timeSeries is a single sample of a series of the remote write protocol.
+type timeSeries struct {
+	labels []labelPair // sorted by name, including __name__
+	value  float64
+	time   int64 // milliseconds since the epoch
+}
+
+type labelPair struct {
+	name, value string
+}
+
+// timeSeriesOf flattens the gathered metric families into samples the same
+// way Prometheus does when it scrapes them, i.e. summaries and histograms are
+// split into their _sum, _count and quantile or bucket series.
+func timeSeriesOf(families []*dto.MetricFamily, now time.Time) []timeSeries {
+	var result []timeSeries
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			ts := now.UnixNano() / int64(time.Millisecond)
+			if m.GetTimestampMs() != 0 {
+				ts = m.GetTimestampMs()
+			}
+
+			add := func(name string, value float64, extra ...labelPair) {
+				labels := []labelPair{{"__name__", name}}
+				for _, l := range m.GetLabel() {
+					labels = append(labels, labelPair{l.GetName(), l.GetValue()})
+				}
+				labels = append(labels, extra...)
+				sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+				result = append(result, timeSeries{labels: labels, value: value, time: ts})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(name, q.GetValue(), labelPair{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add(name+"_sum", s.GetSampleSum())
+				add(name+"_count", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				for _, b := range h.GetBucket() {
+					add(name+"_bucket", float64(b.GetCumulativeCount()), labelPair{"le", formatFloat(b.GetUpperBound())})
+				}
+				add(name+"_bucket", float64(h.GetSampleCount()), labelPair{"le", "+Inf"})
+				add(name+"_sum", h.GetSampleSum())
+				add(name+"_count", float64(h.GetSampleCount()))
+			}
+		}
+	}
+
+	return result
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes the series as protobuf WriteRequest message of
+// the remote write protocol. The message is simple enough to encode it by
+// hand instead of depending on the generated code of the Prometheus server:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries   { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label        { string name = 1; string value = 2; }
+//	message Sample       { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var label []byte
+			label = appendBytesField(label, 1, []byte(l.name))
+			label = appendBytesField(label, 2, []byte(l.value))
+			ts = appendBytesField(ts, 1, label)
+		}
+
+		var sample []byte
+		sample = appendVarint(sample, 1<<3|1) // field 1, 64-bit
+		sample = appendFixed64(sample, math.Float64bits(s.value))
+		sample = appendVarint(sample, 2<<3|0) // field 2, varint
+		sample = appendVarint(sample, uint64(s.time))
+		ts = appendBytesField(ts, 2, sample)
+
+		req = appendBytesField(req, 1, ts)
+	}
+
+	return req
+}
+
+// appendBytesField appends a length-delimited protobuf field.
+func appendBytesField(b []byte, field int, value []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|2)
+	b = appendVarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// snappyEncode encodes src in the snappy block format which is required by
+// the remote write protocol. It only emits literals, i.e. the data is not
+// actually compressed, which is still a valid snappy block that every
+// decoder accepts. The payload of a single FRITZ!Box is small enough that
+// compression does not matter.
+func snappyEncode(src []byte) []byte {
+	dst := appendVarint(nil, uint64(len(src)))
+
+	// A literal tag can address chunks of up to 2^32 bytes but we keep them
+	// at 64 KiB just like the reference implementation.
+	const maxLiteral = 1 << 16
+	for len(src) > 0 {
+		chunk := src
+		if len(chunk) > maxLiteral {
+			chunk = chunk[:maxLiteral]
+		}
+		src = src[len(chunk):]
+
+		n := len(chunk) - 1
+		switch {
+		case n < 60:
+			dst = append(dst, byte(n)<<2)
+		case n < 1<<8:
+			dst = append(dst, 60<<2, byte(n))
+		default:
+			dst = append(dst, 61<<2, byte(n), byte(n>>8))
+		}
+		dst = append(dst, chunk...)
+	}
+
+	return dst
+}
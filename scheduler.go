@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// schedule determines when a collector fetches its metrics. By default the
+// collections happen every interval after the first one, which is due after
+// the start offset of the collector. Aligned schedules collect at multiples of
+// the interval instead (e.g. at :00, :05, :10 for 5m) and cron schedules at
+// the times which match the cron expression. The jitter randomly delays each
+// collection so multiple instances do not hit the FRITZ!Box at the same time.
+type schedule struct {
+	interval time.Duration
+	align    bool           // collect at multiples of the interval
+	phase    time.Duration  // shifts aligned collections, e.g. by the start offset
+	jitter   time.Duration  // maximum random delay of each collection
+	cron     *cronSchedule  // optional, takes precedence over the interval
+	loc      *time.Location // time zone of cron expressions and aligned collections, nil uses the system time zone
+}
+
+// tick is sent by schedule.ticks for each due collection.
+type tick struct {
+	Time time.Time // when the collection was due
+	Next time.Time // when the following collection is due, not including the jitter
+}
+
+// newTicker returns a channel which receives a tick every interval. The first
+// tick is sent after the given offset which may be zero to trigger the first
+// collection immediately.
+func newTicker(ctx context.Context, interval, offset time.Duration) <-chan tick {
+	return schedule{interval: interval}.ticks(ctx, offset)
+}
+
+// ticks returns a channel which receives a tick whenever a collection is due.
+// The first tick is sent after the given offset. If the receiver is still busy
+// with the previous collection when the next one is due, the next tick is sent
+// as soon as the receiver is ready again and the collections which were missed
+// in the meantime are dropped.
+func (s schedule) ticks(ctx context.Context, offset time.Duration) <-chan tick {
+	ch := make(chan tick, 1)
+
+	go func() {
+		due := time.Now().Add(offset)
+		timer := time.NewTimer(offset)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				return
+			}
+
+			now := time.Now()
+			next := s.next(due, now)
+			select {
+			case ch <- tick{Time: now, Next: next}:
+			case <-ctx.Done():
+				return
+			}
+
+			// The collection may have taken a while so we have to check again
+			// which collection is due next.
+			due = s.next(due, time.Now())
+			timer.Reset(time.Until(due) + s.randomJitter())
+		}
+	}()
+
+	return ch
+}
+
+// next returns the time of the first collection after now. The previous
+// collection was due at prev.
+func (s schedule) next(prev, now time.Time) time.Time {
+	if s.loc != nil {
+		now = now.In(s.loc)
+	}
+
+	if s.cron != nil {
+		if next := s.cron.next(now); !next.IsZero() {
+			return next
+		}
+		// The expression never matches again, so we keep using the interval.
+	}
+
+	next := prev.Add(s.interval)
+	if s.align {
+		// Truncate works on the absolute time, so we shift by the offset of
+		// the time zone to align e.g. daily collections to local midnight.
+		_, offset := now.Zone()
+		zone := time.Duration(offset) * time.Second
+		next = now.Add(zone).Truncate(s.interval).Add(-zone).Add(s.phase % s.interval)
+	}
+
+	for !next.After(now) {
+		next = next.Add(s.interval)
+	}
+
+	return next
+}
+
+func (s schedule) randomJitter() time.Duration {
+	if s.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(s.jitter)))
+}
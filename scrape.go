@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// scrapeCollectorNames contains the names of all collectors which can fetch
+// their metrics when Prometheus scrapes fritz-mon instead of periodically.
+var scrapeCollectorNames = []string{"devices", "network"}
+
+// scrapeFetchTimeout limits how long a scrape waits for the FRITZ!Box.
+const scrapeFetchTimeout = 10 * time.Second
+
+// scrapeCollector is a prometheus.Collector which fetches the metrics of a
+// collector from the FRITZ!Box whenever it is scraped. Data which is not older
+// than the configured TTL is reused so concurrent or frequent scrapes do not
+// put additional load on the FRITZ!Box.
+type scrapeCollector struct {
+	server     *Server
+	collector  collector
+	ttl        time.Duration
+	collectors collectorList // the metrics which are updated by the collector
+
+	mu        sync.Mutex
+	lastFetch time.Time
+}
+
+func (c *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors {
+		m.Describe(ch)
+	}
+}
+
+func (c *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.fetch()
+	for _, m := range c.collectors {
+		m.Collect(ch)
+	}
+}
+
+func (c *scrapeCollector) fetch() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.lastFetch.IsZero() && time.Since(c.lastFetch) < c.ttl {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), scrapeFetchTimeout)
+	defer cancel()
+
+	result := c.server.runCollection(ctx, c.collector)
+	if err := result.Err; err != nil {
+		if !errors.Is(err, context.Canceled) && !c.collector.box.waitingForBox(err) {
+			c.collector.errors.Error("Failed to fetch metrics", err)
+		}
+		return // serve the last known values
+	}
+
+	c.lastFetch = time.Now()
+	c.collector.box.available()
+	c.collector.errors.Resolve()
+
+	// The Pushgateway is skipped here since pushing would gather all metrics
+	// again while we are still in the middle of a scrape.
+	if c.server.webhook != nil {
+		c.server.webhook.Post(ctx, result)
+	}
+}
+
+// collectorList is a prometheus.Registerer which only remembers all registered
+// collectors. It is used to wrap the metrics of a collector into a
+// scrapeCollector.
+type collectorList []prometheus.Collector
+
+func (l *collectorList) Register(c prometheus.Collector) error {
+	*l = append(*l, c)
+	return nil
+}
+
+func (l *collectorList) MustRegister(cs ...prometheus.Collector) {
+	*l = append(*l, cs...)
+}
+
+func (l *collectorList) Unregister(prometheus.Collector) bool {
+	return false
+}
+
+// collectsOnScrape returns true if the collector with the given name fetches
+// its metrics when Prometheus scrapes fritz-mon.
+func (s *Server) collectsOnScrape(name string) bool {
+	if !s.Config.CollectOnScrape {
+		return false
+	}
+
+	for _, n := range scrapeCollectorNames {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
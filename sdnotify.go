@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sdNotify sends a state (e.g. "READY=1") to systemd if fritz-mon runs as a
+// service of Type=notify. It returns false if systemd did not ask for
+// notifications (i.e. NOTIFY_SOCKET is not set).
+func sdNotify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+
+	addr := &net.UnixAddr{Name: socket, Net: "unixgram"}
+	if strings.HasPrefix(socket, "@") {
+		addr.Name = "\x00" + socket[1:] // abstract socket
+	}
+
+	conn, err := net.DialUnix(addr.Net, nil, addr)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to systemd notification socket: %w", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	if err != nil {
+		return false, fmt.Errorf("failed to notify systemd: %w", err)
+	}
+
+	return true, nil
+}
+
+// sdWatchdogInterval returns the watchdog timeout which was configured via
+// WatchdogSec in the systemd unit or zero if the watchdog is disabled.
+func sdWatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0 // the watchdog is meant for another process
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+// sdStatusInterval is how often the STATUS shown by systemctl is updated if the
+// systemd watchdog is disabled.
+const sdStatusInterval = 30 * time.Second
+
+// notifySystemd tells systemd that fritz-mon is ready once the HTTP server is
+// listening and all collectors ran their first collection, regardless of
+// whether it succeeded. Otherwise a collector which cannot succeed (e.g.
+// because the account lacks a permission) or a FRITZ!Box which is still
+// booting would make systemd abort the start.
+//
+// If the systemd watchdog is enabled, it sends heartbeats as long as the
+// collector loops make progress (see hungCollectors). Whether the FRITZ!Box is
+// reachable does not matter for the watchdog since restarting fritz-mon does
+// not help with that. Stale collectors are reported via /healthz and the
+// STATUS shown by systemctl instead.
+func (s *Server) notifySystemd(ctx context.Context) {
+	if os.Getenv("NOTIFY_SOCKET") == "" {
+		return
+	}
+
+	interval := sdWatchdogInterval()
+	watchdog := interval > 0
+	if watchdog {
+		s.Logger.Debug("Sending heartbeats to the systemd watchdog", zap.Duration("watchdog_timeout", interval))
+		interval /= 2
+	} else {
+		interval = sdStatusInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	var status string // last STATUS sent to systemd
+	started := s.started.Ready()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-started:
+			started = nil // notify only once
+			s.Logger.Debug("Notifying systemd that fritz-mon is ready")
+			if _, err := sdNotify("READY=1"); err != nil {
+				s.Logger.Error("Failed to notify systemd", zap.Error(err))
+			}
+
+		case now := <-t.C:
+			var state []string
+			if newStatus := s.systemdStatus(now); newStatus != status {
+				status = newStatus
+				state = append(state, "STATUS="+status)
+			}
+
+			if hung := s.status.hungCollectors(now); watchdog && len(hung) > 0 {
+				s.Logger.Warn("Skipping systemd watchdog heartbeat since collections do not return", zap.Strings("collectors", hung))
+			} else if watchdog {
+				state = append(state, "WATCHDOG=1")
+			}
+
+			if len(state) == 0 {
+				continue
+			}
+
+			if _, err := sdNotify(strings.Join(state, "\n")); err != nil {
+				s.Logger.Error("Failed to notify systemd", zap.Error(err))
+			}
+		}
+	}
+}
+
+// systemdStatus returns a short description of the health of fritz-mon which
+// is shown by systemctl status.
+func (s *Server) systemdStatus(now time.Time) string {
+	if stale := s.status.staleCollectors(now); len(stale) > 0 {
+		return fmt.Sprintf("No successful collection for %d intervals: %s", staleIntervals, strings.Join(stale, ", "))
+	}
+
+	if pending := s.ready.pendingCollectors(); len(pending) > 0 {
+		return "Waiting for first successful collection of: " + strings.Join(pending, ", ")
+	}
+
+	return "Collecting metrics"
+}
+
+// notifyStopping tells systemd that fritz-mon is shutting down.
+func (s *Server) notifyStopping() {
+	if _, err := sdNotify("STOPPING=1"); err != nil {
+		s.Logger.Error("Failed to notify systemd", zap.Error(err))
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// notifications receives the states which are sent via sdNotify.
+type notifications struct {
+	t    *testing.T
+	conn *net.UnixConn
+}
+
+func listenNotifications(t *testing.T) *notifications {
+	t.Helper()
+
+	socket := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+	t.Cleanup(func() { conn.Close() })
+	return &notifications{t: t, conn: conn}
+}
+
+// next returns the next state which contains the given assignment or fails
+// the test if none is sent within the timeout.
+func (n *notifications) next(assignment string, timeout time.Duration) string {
+	n.t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	buf := make([]byte, 4096)
+	for {
+		_ = n.conn.SetReadDeadline(deadline)
+		size, err := n.conn.Read(buf)
+		if err != nil {
+			n.t.Fatalf("No %q sent to systemd: %v", assignment, err)
+		}
+
+		state := string(buf[:size])
+		for _, line := range strings.Split(state, "\n") {
+			if strings.HasPrefix(line, assignment) {
+				return state
+			}
+		}
+	}
+}
+
+// drain discards all states which were sent so far.
+func (n *notifications) drain() {
+	buf := make([]byte, 4096)
+	for {
+		_ = n.conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+		if _, err := n.conn.Read(buf); err != nil {
+			return
+		}
+	}
+}
+
+// none fails the test if a state which contains the given assignment is
+// sent within the duration.
+func (n *notifications) none(assignment string, d time.Duration) {
+	n.t.Helper()
+
+	deadline := time.Now().Add(d)
+	buf := make([]byte, 4096)
+	for {
+		_ = n.conn.SetReadDeadline(deadline)
+		size, err := n.conn.Read(buf)
+		if err != nil {
+			return
+		}
+
+		if state := string(buf[:size]); strings.Contains(state, assignment) {
+			n.t.Fatalf("Unexpected state sent to systemd: %q", state)
+		}
+	}
+}
+
+func TestNotifySystemd(t *testing.T) {
+	notified := listenNotifications(t)
+	t.Setenv("WATCHDOG_USEC", "20000") // heartbeats every 10ms
+
+	s := newTestServer(DefaultConfig())
+	c := collector{id: "fritz.box/devices", interval: time.Hour, timeout: 50 * time.Millisecond}
+	s.status.add(c)
+	s.ready.wait(c.id)
+	s.started.wait(c.id)
+	s.ready.start()
+	s.started.start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.notifySystemd(ctx)
+
+	state := notified.next("STATUS=", time.Second)
+	if want := "STATUS=Waiting for first successful collection of: fritz.box/devices"; !strings.Contains(state, want) {
+		t.Errorf("State = %q, want %q", state, want)
+	}
+	notified.next("WATCHDOG=1", time.Second)
+
+	// A failed first collection (e.g. while the FRITZ!Box reboots) makes
+	// fritz-mon ready for systemd but not for /readyz and does not stop the
+	// heartbeats.
+	s.status.begin(c.id, time.Now())
+	s.status.record(c.id, CollectionResult{Start: time.Now(), Err: errors.New("FRITZ!Box is not reachable")})
+	s.started.done(c.id)
+
+	notified.next("READY=1", time.Second)
+	if pending := s.ready.pendingCollectors(); len(pending) != 1 {
+		t.Errorf("Pending collectors = %v, want the failed collector", pending)
+	}
+	notified.next("WATCHDOG=1", time.Second)
+
+	// A collection which runs far beyond its timeout means fritz-mon is
+	// wedged, so systemd should restart it.
+	s.status.begin(c.id, time.Now().Add(-time.Second))
+	time.Sleep(20 * time.Millisecond) // a heartbeat may already be on its way
+	notified.drain()
+	notified.none("WATCHDOG=1", 100*time.Millisecond)
+}
+
+func TestHungCollectors(t *testing.T) {
+	now := time.Now()
+	r := newStatusRegistry()
+	r.add(collector{id: "idle", interval: time.Minute})
+	r.add(collector{id: "running", interval: time.Minute})
+	r.add(collector{id: "hung", interval: time.Minute, timeout: 10 * time.Second})
+
+	r.begin("running", now.Add(-90*time.Second)) // beyond the timeout but not yet hung
+	r.begin("hung", now.Add(-21*time.Second))
+
+	hung := r.hungCollectors(now)
+	if len(hung) != 1 || hung[0] != "hung" {
+		t.Errorf("hungCollectors() = %v, want [hung]", hung)
+	}
+
+	r.record("hung", CollectionResult{Start: now})
+	if hung := r.hungCollectors(now); len(hung) != 0 {
+		t.Errorf("hungCollectors() = %v after the collection returned, want none", hung)
+	}
+}
+
+func TestSystemdStatusReportsStaleCollectors(t *testing.T) {
+	now := time.Now()
+	s := newTestServer(DefaultConfig())
+	s.status.add(collector{id: "fritz.box/devices", interval: time.Minute})
+	s.status.add(collector{id: "fritz.box/mesh", interval: time.Minute})
+	s.status.record("fritz.box/mesh", CollectionResult{Start: now.Add(4 * time.Minute)})
+
+	got := s.systemdStatus(now.Add(5 * time.Minute))
+	if want := "No successful collection for 3 intervals: fritz.box/devices"; got != want {
+		t.Errorf("systemdStatus() = %q, want %q", got, want)
+	}
+
+	if got := s.systemdStatus(now); got != "Collecting metrics" {
+		t.Errorf("systemdStatus() = %q, want %q", got, "Collecting metrics")
+	}
+}
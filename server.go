@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,62 +20,175 @@ import (
 )
 
 type Server struct {
-	Logger    *zap.Logger
-	Metrics   *Metrics
-	Config    Config
-	FritzBox  *fritzbox.Client
-	interrupt chan os.Signal
+	Logger     *zap.Logger
+	Config     Config
+	Boxes      []*Box
+	Gatherer   prometheus.Gatherer // used to push metrics if a Pushgateway or remote write is configured
+	interrupt  chan os.Signal
+	hangup     chan os.Signal                  // reloads the password files
+	registry   map[string]*prometheus.Registry // separate registries by collector name (see Config.Endpoints)
+	ready      *readiness                      // collectors which did not yet succeed
+	started    *readiness                      // collectors which did not yet run their first collection
+	status     *statusRegistry
+	pusher     *pusher
+	remote     *remoteWriter
+	webhook    *webhook
+	homekit    *homeKitBridge
+	pseudonyms *pseudonymizer // replaces household details in the data which leaves the process, nil if disabled
+	limiter    *actionLimiter // rate limits of the control API
+	confirm    *confirmations // pending confirmations of the control API
 }
 
 var ErrServerClosed = fmt.Errorf("server closed")
 
 func NewServer(conf Config, logger *zap.Logger) (*Server, error) {
-	interrupt := make(chan os.Signal)
+	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	var boxes []*Box
+	for _, boxConf := range conf.Boxes() {
+		box, err := NewBox(conf, boxConf, logger)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", boxConf.DisplayName(), err)
+		}
+		boxes = append(boxes, box)
+	}
 
-	client, err := fritzbox.New(conf.FritzBox.BaseURL, conf.FritzBox.Username, conf.FritzBox.Password, logger)
-	if err != nil {
-		return nil, fmt.Errorf("bad FRITZ!Box configuration")
+	// Metrics of collectors with their own endpoint are kept in separate
+	// registries but are still pushed together with all other metrics.
+	registry := map[string]*prometheus.Registry{}
+	gatherers := prometheus.Gatherers{prometheus.DefaultGatherer}
+	for _, name := range conf.Endpoints {
+		if registry[name] == nil {
+			registry[name] = prometheus.NewRegistry()
+			gatherers = append(gatherers, registry[name])
+		}
 	}
 
 	return &Server{
-		Logger:    logger,
-		Metrics:   NewMetrics(logger),
-		Config:    conf,
-		FritzBox:  client,
-		interrupt: interrupt,
+		Logger:     logger,
+		Config:     conf,
+		Boxes:      boxes,
+		Gatherer:   conf.Metrics.gatherer(gatherers),
+		interrupt:  interrupt,
+		hangup:     hangup,
+		registry:   registry,
+		ready:      newReadiness(),
+		started:    newReadiness(),
+		status:     newStatusRegistry(),
+		limiter:    newActionLimiter(conf.API.RateLimits),
+		confirm:    newConfirmations(),
+		pseudonyms: conf.Metrics.pseudonymizer(),
 	}, nil
 }
 
+// RegisterMetrics registers the metrics of all boxes. Each metric gets a "box"
+// label so the metrics of multiple FRITZ!Boxes can be told apart. Metrics of
+// collectors with their own endpoint are registered at their own registry
+// instead of r.
 func (s *Server) RegisterMetrics(r prometheus.Registerer) error {
-	return s.Metrics.Register(r)
+	for _, box := range s.Boxes {
+		err := s.registerBoxMetrics(box, r)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.Register(s.healthyMetric())
+}
+
+func (s *Server) registerBoxMetrics(box *Box, r prometheus.Registerer) error {
+	groups := box.Metrics.groups()
+	for _, c := range s.boxCollectors(box) {
+		var cr prometheus.Registerer = r
+		if registry, ok := s.registry[c.name]; ok {
+			cr = registry
+		}
+		cr = prometheus.WrapRegistererWith(prometheus.Labels{"box": box.Name}, cr)
+
+		if !s.collectsOnScrape(c.name) {
+			if err := groups[c.name].Register(cr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		sc := &scrapeCollector{server: s, collector: c, ttl: s.Config.ScrapeCacheTTL}
+		if err := groups[c.name].Register(&sc.collectors); err != nil {
+			return err
+		}
+
+		s.status.add(c)
+		if err := cr.Register(sc); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *Server) Run() error {
 	s.Logger.Info("Starting FRITZ!Box monitoring server",
 		zap.String("listen_addr", s.Config.ListenAddr),
-		zap.String("fritzbox", s.Config.FritzBox.BaseURL),
 	)
 
+	s.logStartupSummary()
+	for _, box := range s.Boxes {
+		box.discoverTR064Port()
+	}
+
 	if s.Logger.Check(zap.DebugLevel, "") == nil {
 		s.Logger.Info("If you want to see more verbose log run with -debug")
 	} else {
 		s.Logger.Debug("Debug logging is enabled")
 	}
 
-	mux := http.NewServeMux()
-	mux.Handle("/metrics", promhttp.Handler())
+	s.publishExpvars()
 
 	httpServer := &http.Server{
 		Addr:    s.Config.ListenAddr,
-		Handler: mux,
+		Handler: s.handler(),
+	}
+
+	// Listen before anything else is started so systemd is only notified
+	// once the HTTP server actually accepts connections.
+	listener, err := net.Listen("tcp", s.Config.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("HTTP server failed: %w", err)
+	}
+
+	if s.Config.Pushgateway.Enabled() {
+		s.Logger.Info("Pushing metrics to Pushgateway", zap.String("url", s.Config.Pushgateway.URL))
+		s.pusher = newPusher(s.Config.Pushgateway, s.Gatherer, s.Logger)
+	}
+
+	if s.Config.RemoteWrite.Enabled() {
+		s.Logger.Info("Sending metrics via remote write", zap.String("url", s.Config.RemoteWrite.URL))
+		s.remote = newRemoteWriter(s.Config.RemoteWrite, s.Gatherer, s.Logger)
+	}
+
+	if s.Config.Webhook.Enabled() {
+		s.Logger.Info("Posting collections to webhook", zap.String("url", s.Config.Webhook.URL))
+		s.webhook = newWebhook(s.Config.Webhook, s.pseudonyms, s.Logger)
+	}
+
+	if s.Config.HomeKit.Enabled() {
+		s.homekit = newHomeKitBridge(s.Config.HomeKit, s.Boxes, s.pseudonyms, s.Logger)
 	}
 
 	ctx, shutdown := context.WithCancel(context.Background())
 
+	if s.homekit != nil {
+		go s.homekit.Run(ctx)
+	}
+
+	go s.notifySystemd(ctx)
+
 	var serverErr error
 	go func() {
-		err := httpServer.ListenAndServe()
+		err := httpServer.Serve(listener)
 		if err != http.ErrServerClosed {
 			serverErr = fmt.Errorf("HTTP server failed: %w", err)
 		}
@@ -81,22 +196,30 @@ func (s *Server) Run() error {
 	}()
 
 	go func() {
-		select {
-		case sig := <-s.interrupt:
-			s.Logger.Info("Shutting down server due to system interrupt",
-				zap.Stringer("signal", sig),
-			)
-			shutdown()
-		case <-ctx.Done():
-			return
+		for {
+			select {
+			case <-s.hangup:
+				s.reloadPasswords()
+			case sig := <-s.interrupt:
+				s.Logger.Info("Shutting down server due to system interrupt",
+					zap.Stringer("signal", sig),
+				)
+				shutdown()
+				return
+			case <-ctx.Done():
+				return
+			}
 		}
 	}()
 
 	s.CollectMetrics(ctx)
+	s.notifyStopping()
 
-	err := s.FritzBox.Close()
-	if err != nil {
-		s.Logger.Error("Failed to close FRITZ!Box client", zap.Error(err))
+	for _, box := range s.Boxes {
+		err := box.FritzBox.Close()
+		if err != nil {
+			box.Logger.Error("Failed to close FRITZ!Box client", zap.Error(err))
+		}
 	}
 
 	s.Logger.Info("HTTP Server is shutting down")
@@ -110,80 +233,340 @@ func (s *Server) Run() error {
 	return serverErr
 }
 
+// handler returns the HTTP handler of all endpoints. The endpoints which
+// expose the status of the collectors require a read token just like the JSON
+// API since they contain the raw data of the last collections.
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	metrics := s.Config.Metrics
+	if metrics.Profile == profileFull && !metrics.Anonymize {
+		mux.Handle("/metrics", promhttp.Handler())
+	} else {
+		mux.Handle("/metrics", promhttp.HandlerFor(metrics.gatherer(prometheus.DefaultGatherer), promhttp.HandlerOpts{}))
+	}
+	for name, registry := range s.registry {
+		mux.Handle("/metrics/"+name, promhttp.HandlerFor(metrics.gatherer(registry), promhttp.HandlerOpts{}))
+	}
+	mux.Handle("/readyz", s.ready)
+	mux.Handle("/ready", s.ready)
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/api/v1/status", s.requireScope(scopeRead, s.serveStatus))
+	mux.HandleFunc("/api/v1/devices", s.requireScope(scopeRead, s.serveDevices))
+	mux.HandleFunc("/api/v1/devices/", s.requireScope(scopeControl, s.serveDeviceAction))
+	mux.HandleFunc("/api/v1/groups/", s.requireScope(scopeControl, s.serveGroupAction))
+	mux.HandleFunc("/api/v1/guest_wlan", s.requireScope(scopeControl, s.serveGuestWLAN))
+	mux.HandleFunc("/", s.requireScope(scopeRead, s.serveIndex))
+	mux.HandleFunc("/debug/vars", s.requireScope(scopeRead, expvar.Handler().ServeHTTP))
+
+	return mux
+}
+
+// collector periodically fetches a group of metrics from the FRITZ!Box.
+type collector struct {
+	name     string        // used as key in the configuration and in log messages
+	id       string        // unique name across all boxes (e.g. for the readiness check)
+	box      *Box          // the FRITZ!Box from which metrics are collected
+	interval time.Duration // zero disables the collector
+	timeout  time.Duration // deadline of each collection, zero uses the interval
+	offset   time.Duration // delay of the first collection after startup
+	schedule schedule      // when to collect after the first collection
+	logger   *zap.Logger   // child logger with the collector name attached
+	errors   *errorLog     // deduplicates repeated errors of this collector
+
+	// fetch updates the metrics and returns the raw data fetched from the FRITZ!Box.
+	fetch func(ctx context.Context) (interface{}, error)
+}
+
+// collectionTimeout returns the deadline of each collection.
+func (c collector) collectionTimeout() time.Duration {
+	if c.timeout > 0 {
+		return c.timeout
+	}
+	return c.interval
+}
+
+// collectors returns the collectors of all boxes.
+func (s *Server) collectors() []collector {
+	var cs []collector
+	for _, box := range s.Boxes {
+		cs = append(cs, s.boxCollectors(box)...)
+	}
+	return cs
+}
+
+func (s *Server) boxCollectors(b *Box) []collector {
+	cs := []collector{
+		{name: "devices", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.Devices.FetchFrom(ctx, b.FritzBox)
+		}},
+		{name: "network", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.Network.FetchFrom(ctx, b.FritzBox)
+		}},
+		{name: "system", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.System.FetchFrom(ctx, b.TR064)
+		}},
+		{name: "wan", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.WAN.FetchFrom(ctx, b.TR064)
+		}},
+		{name: "event_log", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.Events.FetchFrom(ctx, b.FritzBox)
+		}},
+		{name: "wlan", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.WLAN.FetchFrom(ctx, b.TR064)
+		}},
+		{name: "lan", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.LAN.FetchFrom(ctx, b.TR064)
+		}},
+		{name: "mobile", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.Mobile.FetchFrom(ctx, b.FritzBox)
+		}},
+		{name: "fiber", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.Fiber.FetchFrom(ctx, b.FritzBox)
+		}},
+		{name: "docsis", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.DOCSIS.FetchFrom(ctx, b.FritzBox)
+		}},
+		{name: "mesh", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.Mesh.FetchFrom(ctx, b.TR064)
+		}},
+		{name: "hosts", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.Hosts.FetchFrom(ctx, b.TR064)
+		}},
+		{name: "guest_wlan_watchdog", fetch: func(ctx context.Context) (interface{}, error) {
+			return b.Metrics.GuestWLANWatchdog.Check(ctx, b.TR064)
+		}},
+	}
+
+	for i := range cs {
+		cs[i].box = b
+		cs[i].id = s.collectorID(b, cs[i].name)
+		cs[i].interval = s.Config.boxInterval(b.Config, cs[i].name)
+		cs[i].timeout = s.Config.Collectors[cs[i].name].Timeout
+		cs[i].offset = s.Config.StartupDelay + s.Config.StartOffsets[cs[i].name]
+		if s.Config.DeferFirst[cs[i].name] {
+			cs[i].offset += cs[i].interval
+		}
+		cs[i].schedule = s.collectorSchedule(cs[i].name, cs[i].interval)
+		cs[i].logger = b.Logger.With(zap.String("collector", cs[i].name))
+		cs[i].errors = newErrorLog(cs[i].logger, s.Config.LogDedupWindow)
+	}
+
+	return cs
+}
+
+// collectorSchedule returns the schedule of the collector with the given name.
+func (s *Server) collectorSchedule(name string, interval time.Duration) schedule {
+	sched := schedule{
+		interval: interval,
+		align:    s.Config.AlignCollections,
+		phase:    s.Config.StartOffsets[name],
+		jitter:   s.Config.CollectionJitter,
+		loc:      s.Config.Location(),
+	}
+
+	if expr, ok := s.Config.Schedules[name]; ok {
+		// The expression was already validated with the configuration.
+		sched.cron, _ = parseCron(expr)
+	}
+
+	return sched
+}
+
+// collectorID returns the name of a collector which is unique across all
+// boxes.
+func (s *Server) collectorID(b *Box, name string) string {
+	if len(s.Boxes) > 1 {
+		return b.Name + "/" + name
+	}
+	return name
+}
+
+// collectorNames contains the names of all collectors. It is used to validate
+// the configuration.
+var collectorNames = []string{
+	"devices",
+	"network",
+	"system",
+	"wan",
+	"event_log",
+	"wlan",
+	"lan",
+	"mobile",
+	"fiber",
+	"docsis",
+	"mesh",
+	"hosts",
+	"guest_wlan_watchdog",
+}
+
+// reloadPasswords reads the password_file of all FRITZ!Boxes again. A box
+// whose file cannot be read keeps its previous password.
+func (s *Server) reloadPasswords() {
+	for _, box := range s.Boxes {
+		if err := box.reloadPassword(); err != nil {
+			box.Logger.Error("Failed to reload FRITZ!Box password", zap.Error(err))
+		}
+	}
+}
+
 func (s *Server) CollectMetrics(ctx context.Context) {
+	ipClients := map[*Box]bool{}
+	for _, box := range s.Boxes {
+		ipClients[box] = box.isIPClient(ctx)
+	}
+
 	wg := new(sync.WaitGroup)
-	wg.Add(2)
-	go s.deviceMetricsLoop(ctx, wg, s.Config.DeviceMonitoringInterval)
-	go s.networkMetricsLoop(ctx, wg, s.Config.NetworkMonitoringInterval)
-	wg.Wait()
-}
+	for _, c := range s.collectors() {
+		if c.interval <= 0 {
+			c.logger.Debug("Collector is disabled")
+			continue
+		}
 
-func newTicker(ctx context.Context, interval time.Duration) <-chan time.Time {
-	ch := make(chan time.Time, 1)
-	ch <- time.Now() // trigger first metrics collection immediately
+		if ipClients[c.box] && isWANCollector(c.name) {
+			c.logger.Info("Collector is disabled since the FRITZ!Box runs as IP client")
+			continue
+		}
 
-	go func() {
-		ti := time.NewTicker(interval)
-		defer ti.Stop()
+		if s.collectsOnScrape(c.name) {
+			c.logger.Debug("Collecting metrics on scrape", zap.Duration("cache_ttl", s.Config.ScrapeCacheTTL))
+			continue
+		}
 
-		for {
-			var next time.Time
-			select {
-			case next = <-ti.C:
-				// we got an update
-			case <-ctx.Done():
-				return
-			}
+		s.ready.wait(c.id)
+		if !s.Config.DeferFirst[c.name] {
+			// Deferred collectors first run an interval after startup
+			// which is much too late for systemd to wait for.
+			s.started.wait(c.id)
+		}
+		s.status.add(c)
+		wg.Add(1)
+		go s.metricsLoop(ctx, wg, c)
+	}
 
-			select {
-			case ch <- next:
-				// cool, keep on going
-			case <-ctx.Done():
-				return
-			}
+	s.ready.start()
+	s.started.start()
+
+	if s.Config.SessionKeepAlive > 0 {
+		for _, box := range s.Boxes {
+			wg.Add(1)
+			go s.keepSessionAlive(ctx, wg, box)
 		}
-	}()
+	}
 
-	return ch
+	wg.Wait()
 }
 
-func (s *Server) deviceMetricsLoop(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
-	s.Logger.Info("Monitoring device metrics", zap.Duration("interval", interval))
+func (s *Server) metricsLoop(ctx context.Context, wg *sync.WaitGroup, c collector) {
+	defer wg.Done()
+
+	fields := []zap.Field{
+		zap.Duration("interval", c.interval),
+		zap.Duration("offset", c.offset),
+	}
+	if expr, ok := s.Config.Schedules[c.name]; ok {
+		fields = append(fields, zap.String("schedule", expr))
+	}
+	c.logger.Info("Monitoring metrics", fields...)
 
-	ticker := newTicker(ctx, interval)
+	ticker := c.schedule.ticks(ctx, c.offset)
+	s.status.schedule(c.id, time.Now().Add(c.offset))
 	for {
 		select {
-		case <-ticker:
-			err := s.Metrics.Devices.FetchFrom(ctx, s.FritzBox)
-			if err != nil && !errors.Is(err, context.Canceled) {
-				s.Logger.Error("Failed to fetch device metrics", zap.Error(err))
-			}
-
 		case <-ctx.Done():
-			s.Logger.Info("Device monitoring stopped")
-			wg.Done()
+			c.logger.Info("Monitoring stopped")
 			return
+
+		case tick := <-ticker:
+			s.status.schedule(c.id, tick.Next)
+			if !s.ready.isDone(c.id) {
+				s.initialCollection(ctx, c)
+				continue
+			}
+
+			result := s.runCollection(ctx, c)
+			if err := result.Err; err != nil {
+				if !errors.Is(err, context.Canceled) && !c.box.waitingForBox(err) {
+					c.errors.Error("Failed to fetch metrics", err)
+				}
+				continue
+			}
+
+			c.box.available()
+			c.errors.Resolve()
+			s.publish(ctx, c, result)
 		}
 	}
 }
 
-func (s *Server) networkMetricsLoop(ctx context.Context, wg *sync.WaitGroup, interval time.Duration) {
-	s.Logger.Info("Monitoring network metrics", zap.Duration("interval", interval))
+// publish sends the results of a completed collection to all configured
+// sinks (i.e. the Pushgateway, remote write, the webhook and HomeKit).
+func (s *Server) publish(ctx context.Context, c collector, result CollectionResult) {
+	if s.pusher != nil {
+		s.pusher.Push(c.id)
+	}
 
-	ticker := newTicker(ctx, interval)
+	if s.remote != nil {
+		s.remote.Write(ctx, c.id)
+	}
 
+	if s.webhook != nil {
+		s.webhook.Post(ctx, result)
+	}
+
+	if devices, ok := result.Data.([]fritzbox.Device); ok && s.homekit != nil {
+		s.homekit.Update(c.box, devices)
+	}
+}
+
+// initialCollection runs the first collection of a collector. Other than
+// regular collections, a failed initial collection is retried with an
+// exponential backoff instead of waiting a full interval. This way, metrics
+// become available quickly if the FRITZ!Box is still booting when fritz-mon
+// starts (e.g. after a power failure).
+func (s *Server) initialCollection(ctx context.Context, c collector) {
+	backoff := initialBackoff
 	for {
-		select {
-		case <-ctx.Done():
-			s.Logger.Info("Network monitoring stopped")
-			wg.Done()
+		result := s.runCollection(ctx, c)
+		s.started.done(c.id)
+		err := result.Err
+		if err == nil {
+			c.box.available()
+			s.ready.done(c.id)
+			c.errors.Resolve()
+			c.logger.Debug("Initial collection succeeded")
+			s.publish(ctx, c, result)
 			return
+		}
 
-		case <-ticker:
-			err := s.Metrics.Network.FetchFrom(ctx, s.FritzBox)
-			if err != nil && !errors.Is(err, context.Canceled) {
-				s.Logger.Error("Failed to fetch network metrics", zap.Error(err))
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+
+		waiting := c.box.waitingForBox(err)
+		if backoff >= c.interval {
+			// Retrying would not be any faster than waiting for the next tick.
+			if !waiting {
+				c.errors.Error("Failed to fetch metrics", err)
 			}
+			return
+		}
+
+		if !waiting {
+			c.logger.Warn("Initial collection failed, retrying",
+				zap.Duration("backoff", backoff),
+				zap.Error(err),
+			)
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return
 		}
 	}
 }
+
+// initialBackoff is the delay before the first retry of a failed initial
+// collection. The delay doubles with each attempt.
+const initialBackoff = time.Second
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func newTestServer(conf Config) *Server {
+	return &Server{
+		Logger:  zap.NewNop(),
+		Config:  conf,
+		ready:   newReadiness(),
+		started: newReadiness(),
+		status:  newStatusRegistry(),
+
+		pseudonyms: conf.Metrics.pseudonymizer(),
+	}
+}
+
+func TestHandlerRequiresReadTokenForStatusPages(t *testing.T) {
+	conf := DefaultConfig()
+	conf.API.ReadTokens = []string{"read-token"}
+	handler := newTestServer(conf).handler()
+
+	for _, path := range []string{"/debug/vars", "/"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("GET %s without token: got status %d, want %d", path, rec.Code, http.StatusUnauthorized)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer read-token")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s with read token: got status %d, want %d", path, rec.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestHandlerAllowsStatusPagesWithoutTokens(t *testing.T) {
+	handler := newTestServer(DefaultConfig()).handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET /debug/vars without configured tokens: got status %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestReloadPasswords(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	if err := ioutil.WriteFile(file, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	boxConf := FritzBoxConfig{BaseURL: "http://fritz.box", Username: "fritz-mon", PasswordFile: file}
+	if err := boxConf.readPasswordFile("fritzbox"); err != nil {
+		t.Fatal(err)
+	}
+	box, err := NewBox(DefaultConfig(), boxConf, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(DefaultConfig())
+	s.Boxes = []*Box{box}
+
+	if err := ioutil.WriteFile(file, []byte("new\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.reloadPasswords()
+	if box.FritzBox.Password != "new" || box.TR064.Password != "new" {
+		t.Errorf("passwords after reload are %q and %q, want %q", box.FritzBox.Password, box.TR064.Password, "new")
+	}
+
+	// A file which cannot be read keeps the previous password.
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	s.reloadPasswords()
+	if box.FritzBox.Password != "new" || box.TR064.Password != "new" {
+		t.Errorf("passwords after failed reload are %q and %q, want %q", box.FritzBox.Password, box.TR064.Password, "new")
+	}
+}
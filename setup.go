@@ -17,9 +17,9 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-func runSetup() {
+func runSetup() int {
 	input := bufio.NewReader(os.Stdin)
-	ask := func(question, defaultVal string) string {
+	ask := func(question, defaultVal string) (string, error) {
 		text := "> " + question
 		if defaultVal != "" {
 			text += " [" + defaultVal + "]"
@@ -28,16 +28,20 @@ func runSetup() {
 		fmt.Print(text + " : ")
 		line, err := input.ReadString('\n')
 		if err != nil {
-			fmt.Printf("ERROR: Failed to read user input: %v\n", err)
-			os.Exit(1)
+			return "", err
 		}
 
 		line = strings.TrimSpace(line)
 		if line == "" {
-			return defaultVal
+			return defaultVal, nil
 		}
 
-		return line
+		return line, nil
+	}
+
+	inputFailed := func(err error) int {
+		fmt.Printf("ERROR: Failed to read user input: %v\n", err)
+		return 1
 	}
 
 	fmt.Println("~~ FRITZ!Box Monitor Setup ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~")
@@ -46,7 +50,10 @@ func runSetup() {
 	fmt.Println("You can abort the setup at any point via ctrl+c without any side effects.")
 	fmt.Println()
 
-	configPath := ask("Where do you want to store your configuration file?", "fritz-mon.yml")
+	configPath, err := ask("Where do you want to store your configuration file?", "fritz-mon.yml")
+	if err != nil {
+		return inputFailed(err)
+	}
 	fmt.Println("  Checking if a configuration file already exists at this location... ")
 	if strings.HasPrefix(configPath, "~/") {
 		configPath = filepath.Join(os.Getenv("HOME"), configPath[2:])
@@ -62,7 +69,7 @@ func runSetup() {
 		if err != nil {
 			fmt.Println("  ✘ Seems like we cannot write to that location")
 			fmt.Println("    " + err.Error())
-			os.Exit(1)
+			return 1
 		}
 
 	default:
@@ -74,15 +81,21 @@ func runSetup() {
 			fmt.Println("  ✔ The existing config file is valid")
 		}
 
-		answer := ask("Do you want to overwrite this file?", "no")
+		answer, err := ask("Do you want to overwrite this file?", "no")
+		if err != nil {
+			return inputFailed(err)
+		}
 		if strings.ToLower(answer) != "yes" && strings.ToLower(answer) != "y" {
 			fmt.Println("  Aborting setup. Have a nice day!")
-			os.Exit(1)
+			return 1
 		}
 	}
 
 listenAddrStep:
-	listenAddr := ask("At which address should fritz-mon open its HTTP server?", conf.ListenAddr)
+	listenAddr, err := ask("At which address should fritz-mon open its HTTP server?", conf.ListenAddr)
+	if err != nil {
+		return inputFailed(err)
+	}
 	_, err = url.Parse("http://" + listenAddr)
 	if err != nil {
 		fmt.Println("  ✘ This is not a valid address. Please use the HOST:PORT notation (e.g. localhost:1234)")
@@ -126,7 +139,10 @@ listenAddrStep:
 	conf.ListenAddr = listenAddr
 
 intervalStep:
-	answer := ask("At which interval should fritz-mon request metrics from the FRITZ!Box API?", conf.DeviceMonitoringInterval.String())
+	answer, err := ask("At which interval should fritz-mon request metrics from the FRITZ!Box API?", conf.DeviceMonitoringInterval.String())
+	if err != nil {
+		return inputFailed(err)
+	}
 	fmt.Println("  Checking provided interval value... ")
 	interval, err := time.ParseDuration(answer)
 	if err != nil {
@@ -145,7 +161,10 @@ intervalStep:
 	conf.DeviceMonitoringInterval = interval
 
 baseURLStep:
-	baseURL := ask("What is the URL of your FRITZ!Box", conf.FritzBox.BaseURL)
+	baseURL, err := ask("What is the URL of your FRITZ!Box", conf.FritzBox.BaseURL)
+	if err != nil {
+		return inputFailed(err)
+	}
 	u, err := url.Parse(baseURL)
 	if err != nil {
 		fmt.Println("  ✘ This is not a valid URL:")
@@ -153,31 +172,69 @@ baseURLStep:
 		goto baseURLStep
 	}
 
-	if u.Scheme == "https" {
-		fmt.Println("  ✘ Connecting via HTTPS to your FRITZ!Box is not yet supported")
-		fmt.Println("    Please try again with http instead")
+	if u.Scheme != "http" && u.Scheme != "https" {
+		fmt.Println("  ✘ The URL must start with http:// or https://")
 		goto baseURLStep
 	}
 
 	conf.FritzBox.BaseURL = baseURL
 
+	if u.Scheme == "https" {
+	caFileStep:
+		conf.FritzBox.CAFile, err = ask("Path to a CA certificate which signed the certificate of your FRITZ!Box (leave empty to use the system CAs)", conf.FritzBox.CAFile)
+		if err != nil {
+			return inputFailed(err)
+		}
+		if conf.FritzBox.CAFile != "" {
+			if _, err := os.Stat(conf.FritzBox.CAFile); err != nil {
+				fmt.Println("  ✘ Cannot read CA certificate:")
+				fmt.Println("    " + err.Error())
+				goto caFileStep
+			}
+		}
+
+		if conf.FritzBox.CAFile == "" && conf.FritzBox.TLSFingerprint == "" {
+			fmt.Println("  The FRITZ!Box uses a self-signed certificate by default which cannot be verified.")
+			answer, err := ask("Do you want to skip the verification of the TLS certificate?", "no")
+			if err != nil {
+				return inputFailed(err)
+			}
+			conf.FritzBox.InsecureSkipVerify = strings.ToLower(answer) == "yes" || strings.ToLower(answer) == "y"
+		}
+	}
+
 usernameStep:
-	conf.FritzBox.Username = ask("What is the name of the FRITZ!Box that fritz-mon should use", conf.FritzBox.Username)
+	conf.FritzBox.Username, err = ask("What is the name of the FRITZ!Box that fritz-mon should use", conf.FritzBox.Username)
+	if err != nil {
+		return inputFailed(err)
+	}
 	if conf.FritzBox.Username == "" {
 		fmt.Println("  ✘ The username cannot be empty and there is no sensible default")
 		goto usernameStep
 	}
 
-	conf.FritzBox.Password = ask("What is the password for this user? Please remember that passwords are stored in plaintext and will be shown here when you are typing", "")
+	conf.FritzBox.Password, err = ask("What is the password for this user? Please remember that passwords are stored in plaintext and will be shown here when you are typing", "")
+	if err != nil {
+		return inputFailed(err)
+	}
 
 	fmt.Println("  Checking connection to FRITZ!Box by listing connected SmartHome devices... ")
 	client, err := fritzbox.New(conf.FritzBox.BaseURL, conf.FritzBox.Username, conf.FritzBox.Password, zap.NewNop())
 	if err != nil {
 		fmt.Println("  ✘ Failed to create FRITZ!Box client")
 		fmt.Println("    " + err.Error())
-		os.Exit(1)
+		return 1
+	}
+
+	httpClient, err := newHTTPClient(conf.FritzBox)
+	if err != nil {
+		fmt.Println("  ✘ Failed to create FRITZ!Box client")
+		fmt.Println("    " + err.Error())
+		return 1
 	}
 
+	client.SetHTTPClient(httpClient)
+
 	ctx := context.Background()
 	devices, err := client.Devices(ctx)
 	if err != nil {
@@ -199,7 +256,7 @@ usernameStep:
 		fmt.Println("Writing configuration file")
 		fmt.Println("  ✘ Failed to open file for writing")
 		fmt.Println("    " + err.Error())
-		os.Exit(1)
+		return 1
 	}
 
 	err = yaml.NewEncoder(f).Encode(conf)
@@ -207,7 +264,7 @@ usernameStep:
 		fmt.Println("Writing configuration file")
 		fmt.Println("  ✘ Failed to write config file")
 		fmt.Println("    " + err.Error())
-		os.Exit(1)
+		return 1
 	}
 
 	fmt.Println("")
@@ -222,4 +279,6 @@ usernameStep:
 	fmt.Printf("  fritz-mon -config=%s\n", configPath)
 	fmt.Println("")
 	fmt.Println("Please also review permissions to the config file if you are on a multi-user system!")
+
+	return 0
 }
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+)
+
+// StandbyRule switches off a smart plug once the power it measures stays below
+// a threshold for a while, i.e. when the connected device is only in standby.
+type StandbyRule struct {
+	Device    string        `yaml:"device"`            // name of the smart plug as shown in the FRITZ!Box
+	Threshold float64       `yaml:"threshold_watts"`   // power below which the device is considered to be in standby
+	Duration  time.Duration `yaml:"duration"`          // how long the power must stay below the threshold
+	Exclude   *TimeWindow   `yaml:"exclude,omitempty"` // optional daily time window in which the plug is never switched off
+}
+
+// TimeWindow is a daily time window like 18:00 to 23:30. A window which ends
+// before it starts spans midnight.
+type TimeWindow struct {
+	From string `yaml:"from"` // e.g. "18:00"
+	To   string `yaml:"to"`   // e.g. "06:00"
+}
+
+func (r StandbyRule) Validate(prefix string) error {
+	var err error
+	if r.Device == "" {
+		err = multierr.Append(err, fmt.Errorf("%s.device cannot be empty", prefix))
+	}
+	if r.Threshold <= 0 {
+		err = multierr.Append(err, fmt.Errorf("%s.threshold_watts must be positive", prefix))
+	}
+	if r.Duration <= 0 {
+		err = multierr.Append(err, fmt.Errorf("%s.duration must be positive", prefix))
+	}
+	if r.Exclude != nil {
+		if _, parseErr := parseTimeOfDay(r.Exclude.From); parseErr != nil {
+			err = multierr.Append(err, fmt.Errorf("%s.exclude.from: %w", prefix, parseErr))
+		}
+		if _, parseErr := parseTimeOfDay(r.Exclude.To); parseErr != nil {
+			err = multierr.Append(err, fmt.Errorf("%s.exclude.to: %w", prefix, parseErr))
+		}
+	}
+
+	return err
+}
+
+// Contains returns true if the time of day of t lies within the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	from, _ := parseTimeOfDay(w.From)
+	to, _ := parseTimeOfDay(w.To)
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	if from <= to {
+		return now >= from && now < to
+	}
+
+	return now >= from || now < to
+}
+
+// parseTimeOfDay parses a time like "18:30" into the duration since midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (expected HH:MM)", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// StandbyKiller applies the configured standby rules to the devices after
+// each device collection.
+type StandbyKiller struct {
+	SwitchOffs *prometheus.CounterVec
+
+	Rules    []StandbyRule
+	Location *time.Location // time zone of the exclude windows, nil uses the system time zone
+
+	logger       *zap.Logger
+	labels       deviceLabels
+	standbySince map[string]time.Time // when the power dropped below the threshold by device name
+}
+
+func NewStandbyKiller(logger *zap.Logger, labels deviceLabels) *StandbyKiller {
+	namespace := "fritzbox"
+	subsystem := "home_automation"
+
+	return &StandbyKiller{
+		logger:       logger,
+		labels:       labels,
+		standbySince: map[string]time.Time{},
+		SwitchOffs: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "standby_switch_offs_total",
+				Help:      "Number of times a device was switched off because it was in standby.",
+			},
+			labels.names(),
+		),
+	}
+}
+
+// localTime returns t in the time zone of the exclude windows.
+func (k *StandbyKiller) localTime(t time.Time) time.Time {
+	if k.Location == nil {
+		return t
+	}
+	return t.In(k.Location)
+}
+
+// Apply switches off all devices which have been in standby for longer than
+// allowed by their rule. Since the power is only known at each collection,
+// the standby duration is measured between collections. The devices are
+// switched off concurrently via the pool.
+func (k *StandbyKiller) Apply(ctx context.Context, client *fritzbox.Client, devices []fritzbox.Device, pool devicePool) DeviceErrors {
+	if len(k.Rules) == 0 {
+		return nil
+	}
+
+	rules := map[string]StandbyRule{}
+	for _, rule := range k.Rules {
+		rules[rule.Device] = rule
+	}
+
+	var switchOff []fritzbox.Device
+	now := time.Now()
+	for _, device := range devices {
+		rule, ok := rules[device.Name]
+		if !ok || !device.IsSwitch() || !device.CanMeasurePower() {
+			continue
+		}
+
+		if device.Present != 1 || !device.Switch.IsPoweredOn() || device.Power.GetPower() >= rule.Threshold {
+			delete(k.standbySince, device.Name)
+			continue
+		}
+
+		since, ok := k.standbySince[device.Name]
+		if !ok {
+			k.standbySince[device.Name] = now
+			continue
+		}
+
+		if now.Sub(since) < rule.Duration {
+			continue
+		}
+
+		if rule.Exclude != nil && rule.Exclude.Contains(k.localTime(now)) {
+			continue
+		}
+
+		k.logger.Info("Switching off device in standby",
+			zap.String("device", device.Name),
+			zap.Float64("power_watts", device.Power.GetPower()),
+			zap.Duration("standby", now.Sub(since)),
+		)
+		switchOff = append(switchOff, device)
+	}
+
+	if len(switchOff) == 0 {
+		return nil
+	}
+
+	errs := pool.run(ctx, switchOff, func(ctx context.Context, device fritzbox.Device) error {
+		return client.SwitchOff(ctx, device.Identifier)
+	})
+
+	var failed DeviceErrors
+	for i, device := range switchOff {
+		if errs[i] != nil {
+			if failed == nil {
+				failed = DeviceErrors{}
+			}
+			failed[device.Name] = fmt.Errorf("failed to switch off device in standby: %w", errs[i])
+			continue
+		}
+
+		delete(k.standbySince, device.Name)
+		k.SwitchOffs.WithLabelValues(k.labels.values(device)...).Inc()
+	}
+
+	return failed
+}
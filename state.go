@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// deviceState contains the latest device readings. It is written to the
+// configured state file after each device collection so the gauges can be
+// restored when fritz-mon restarts.
+type deviceState struct {
+	Timestamp time.Time                     `json:"timestamp"`
+	Devices   map[string]map[string]float64 `json:"devices"`          // readings by device name and metric key
+	Labels    map[string]map[string]string  `json:"labels,omitempty"` // label values by device name and label key (see deviceLabelValues)
+}
+
+// gauges maps the keys of the collected device readings to the corresponding
+// gauges. Counters are not included since they cannot be restored, except for
+// the energy counter which mirrors the readings of the FRITZ!Box.
+func (m *DeviceMetrics) gauges() map[string]*prometheus.GaugeVec {
+	return map[string]*prometheus.GaugeVec{
+		"is_connected":                 m.IsConnected,
+		"temperature_celsius":          m.Temperature,
+		"humidity_percent":             m.Humidity,
+		"voltage_volt":                 m.Voltage,
+		"power_watts":                  m.Power,
+		"is_powered":                   m.IsPoweredOn,
+		"boost_active":                 m.BoostActive,
+		"boost_end_timestamp_seconds":  m.BoostEndTime,
+		"holiday_active":               m.HolidayActive,
+		"summer_active":                m.SummerActive,
+		"level_percent":                m.Level,
+		"color_hue_degrees":            m.ColorHue,
+		"color_saturation_percent":     m.ColorSaturation,
+		"color_temperature_kelvin":     m.ColorTemp,
+		"blind_position_percent":       m.BlindPosition,
+		"blind_end_positions_set":      m.BlindEndPositionsSet,
+		"alert":                        m.Alert,
+		"last_alert_timestamp_seconds": m.LastAlert,
+	}
+}
+
+func (m *DeviceMetrics) saveState(path string, readings map[string]map[string]float64, labels map[string]map[string]string) error {
+	data, err := json.Marshal(deviceState{
+		Timestamp: time.Now(),
+		Devices:   readings,
+		Labels:    labels,
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// writeFileAtomic writes data to a temporary file first and then renames it
+// to path so a crash never leaves a corrupt file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// RestoreState sets the device gauges to the readings stored in the given
+// state file. A missing state file is not an error.
+func (m *DeviceMetrics) RestoreState(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	var state deviceState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	gauges := m.gauges()
+	for deviceName, readings := range state.Devices {
+		// State files of older versions only contain the device names.
+		stored, ok := state.Labels[deviceName]
+		if !ok {
+			stored = map[string]string{"name": deviceName}
+		}
+
+		// If the state file lacks any of the configured labels, the gauges
+		// are set with the next collection. The energy counter is restored
+		// then as well since it must not start from zero again.
+		labels, ok := m.labels.fromMap(stored)
+		if !ok {
+			if energy, ok := readings["energy_watt_hours_total"]; ok {
+				m.pendingEnergy[deviceName] = [2]float64{energy, readings["energy_watt_hours_offset"]}
+			}
+			continue
+		}
+
+		for key, value := range readings {
+			if gauge, ok := gauges[key]; ok {
+				gauge.WithLabelValues(labels...).Set(value)
+			}
+		}
+
+		if energy, ok := readings["energy_watt_hours_total"]; ok {
+			m.Energy.Restore(energy, readings["energy_watt_hours_offset"], labels...)
+		}
+	}
+
+	m.LastUpdate.Set(float64(state.Timestamp.Unix()))
+	m.logger.Info("Restored device readings from state file",
+		zap.String("path", path),
+		zap.Int("devices", len(state.Devices)),
+		zap.Time("timestamp", state.Timestamp),
+	)
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+// runStats implements the "stats" command which prints the measurement history
+// of a smart home device as it is stored on the FRITZ!Box.
+func runStats(args []string, configPath string) int {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	metric := flags.String("metric", "power", "the metric to print ("+strings.Join(statsMetricNames(), ", ")+")")
+	duration := flags.Duration("duration", 24*time.Hour, "how far to go back in time")
+	format := flags.String("format", "csv", "output format (csv or json)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: fritz-mon stats <ain> [flags]")
+		flags.PrintDefaults()
+	}
+
+	// Allow flags before and after the AIN.
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	ain := flags.Arg(0)
+	if ain == "" {
+		flags.Usage()
+		return 2
+	}
+	if code, ok := parseFlags(flags, flags.Args()[1:]); !ok {
+		return code
+	}
+
+	if _, ok := fritzbox.StatsMetrics[*metric]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown metric %q\n", *metric)
+		return 2
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown format %q\n", *format)
+		return 2
+	}
+
+	conf, err := LoadConfiguration(configPath, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	client, err := newStatsClient(conf.Boxes()[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create FRITZ!Box client: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	stats, err := client.DeviceStats(ctx, ain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch device statistics: %v\n", err)
+		return 1
+	}
+
+	samples, err := selectSamples(stats, *metric, *duration, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse device statistics: %v\n", err)
+		return 1
+	}
+
+	switch *format {
+	case "json":
+		err = json.NewEncoder(os.Stdout).Encode(struct {
+			AIN     string            `json:"ain"`
+			Metric  string            `json:"metric"`
+			Unit    string            `json:"unit"`
+			Samples []fritzbox.Sample `json:"samples"`
+		}{ain, *metric, fritzbox.StatsMetrics[*metric], samples})
+	default:
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"timestamp", *metric + "_" + fritzbox.StatsMetrics[*metric]})
+		for _, s := range samples {
+			_ = w.Write([]string{s.Time.Format(time.RFC3339), strconv.FormatFloat(s.Value, 'f', -1, 64)})
+		}
+		w.Flush()
+		err = w.Error()
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write output: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func newStatsClient(conf FritzBoxConfig) (*fritzbox.Client, error) {
+	client, err := fritzbox.New(conf.BaseURL, conf.Username, conf.Password, zap.NewNop())
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := newHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SetHTTPClient(httpClient)
+	return client, nil
+}
+
+// selectSamples returns the samples of the series with the highest resolution
+// which covers the whole duration. If no series is long enough, the longest
+// one is used.
+func selectSamples(stats *fritzbox.DeviceStats, metric string, duration time.Duration, now time.Time) ([]fritzbox.Sample, error) {
+	series, scale, err := stats.Series(metric)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(series) == 0 {
+		return nil, fmt.Errorf("the device does not report any %s statistics", metric)
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Grid < series[j].Grid
+	})
+
+	selected := series[len(series)-1]
+	for _, s := range series {
+		if s.Duration() >= duration {
+			selected = s
+			break
+		}
+	}
+
+	samples, err := selected.Samples(scale, now)
+	if err != nil {
+		return nil, err
+	}
+
+	start := now.Add(-duration)
+	for len(samples) > 0 && samples[0].Time.Before(start) {
+		samples = samples[1:]
+	}
+
+	return samples, nil
+}
+
+func statsMetricNames() []string {
+	var names []string
+	for name := range fritzbox.StatsMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// collectorStatus contains the health and the latest results of a collector.
+type collectorStatus struct {
+	Interval     time.Duration `json:"interval"`
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastSuccess  time.Time     `json:"last_success"`
+	NextRun      time.Time     `json:"next_run"`
+	LastError    string        `json:"last_error,omitempty"`
+	Data         interface{}   `json:"data,omitempty"` // raw data of the last successful collection, only set by snapshot
+
+	DeviceErrors map[string]string `json:"device_errors,omitempty"` // failed requests for individual devices of the last successful collection, only set by snapshot
+
+	added     time.Time     // when the collector was started, used instead of LastSuccess before the first success
+	scheduled bool          // whether the collector runs on a cron schedule instead of every interval
+	failures  int           // number of consecutive failed collections
+	timeout   time.Duration // deadline of each collection
+	running   time.Time     // start of the current collection, zero while the collector waits for the next one
+}
+
+// statusRegistry keeps track of the status of all collectors. It also keeps
+// the result of the latest successful collection of each collector in memory
+// so the JSON API and the landing page serve the same data which is exported
+// at /metrics without sending any requests to the FRITZ!Box. It is safe for
+// concurrent use.
+type statusRegistry struct {
+	mu         sync.RWMutex
+	collectors map[string]*collectorStatus
+	results    map[string]CollectionResult // latest successful result by collector
+}
+
+func newStatusRegistry() *statusRegistry {
+	return &statusRegistry{
+		collectors: map[string]*collectorStatus{},
+		results:    map[string]CollectionResult{},
+	}
+}
+
+func (r *statusRegistry) add(c collector) {
+	r.mu.Lock()
+	r.collectors[c.id] = &collectorStatus{
+		Interval:  c.interval,
+		added:     time.Now(),
+		scheduled: c.schedule.cron != nil,
+		timeout:   c.collectionTimeout(),
+	}
+	r.mu.Unlock()
+}
+
+// begin records that a collection of the given collector started.
+func (r *statusRegistry) begin(name string, start time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if status, ok := r.collectors[name]; ok {
+		status.running = start
+	}
+}
+
+func (r *statusRegistry) record(name string, result CollectionResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, ok := r.collectors[name]
+	if !ok {
+		status = new(collectorStatus)
+		r.collectors[name] = status
+	}
+
+	status.LastRun = result.Start
+	status.LastDuration = result.Duration
+	status.running = time.Time{}
+	if result.Err != nil {
+		status.LastError = result.Err.Error()
+		status.failures++
+		return
+	}
+
+	status.failures = 0
+	status.LastSuccess = result.Start
+	status.LastError = ""
+	r.results[name] = result
+}
+
+// latest returns the result of the latest successful collection of the given
+// collector. Other than snapshot it does not copy the status of all collectors
+// so it is cheap enough to be called on every API request.
+func (r *statusRegistry) latest(name string) (CollectionResult, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result, ok := r.results[name]
+	return result, ok
+}
+
+// schedule records when the next collection of the given collector is due.
+func (r *statusRegistry) schedule(name string, next time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if status, ok := r.collectors[name]; ok {
+		status.NextRun = next
+	}
+}
+
+// snapshot returns a copy of the status of all collectors including the data
+// of their latest successful collection.
+func (r *statusRegistry) snapshot() map[string]collectorStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(map[string]collectorStatus, len(r.collectors))
+	for name, status := range r.collectors {
+		s := *status
+		if last, ok := r.results[name]; ok {
+			s.Data = last.Data
+			s.DeviceErrors = last.DeviceErrors
+		}
+		result[name] = s
+	}
+
+	return result
+}
+
+// publishExpvars exposes the status of all collectors at /debug/vars.
+func (s *Server) publishExpvars() {
+	expvar.Publish("collectors", expvar.Func(s.expvars))
+}
+
+// expvars returns the status of all collectors with household details in
+// their data replaced by pseudonyms if anonymization is enabled.
+func (s *Server) expvars() interface{} {
+	snapshot := s.status.snapshot()
+	if s.pseudonyms == nil {
+		return snapshot
+	}
+
+	result := make(map[string]collectorStatus, len(snapshot))
+	for id, status := range snapshot {
+		status.Data = s.pseudonyms.data(status.Data)
+		status.DeviceErrors = s.pseudonyms.deviceErrors(status.DeviceErrors)
+		if i := strings.Index(id, "/"); i >= 0 { // see collectorID
+			id = s.pseudonyms.label("box", id[:i]) + id[i:]
+		}
+		result[id] = status
+	}
+
+	return result
+}
+
+// runCollection runs a single collection and records its result. A collection
+// must complete before the next one is due. Failed requests for individual
+// devices do not fail the collection but are part of the result.
+func (s *Server) runCollection(ctx context.Context, c collector) CollectionResult {
+	if timeout := c.collectionTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	s.status.begin(c.id, start)
+	data, err := c.fetch(ctx)
+	result := CollectionResult{
+		Box:       c.box.Name,
+		Collector: c.name,
+		Start:     start,
+		Duration:  time.Since(start),
+		Data:      data,
+		Err:       err,
+	}
+
+	var deviceErrs DeviceErrors
+	if errors.As(err, &deviceErrs) {
+		c.logger.Error("Failed to complete requests for individual devices", zap.Error(err))
+		result.DeviceErrors = deviceErrs.strings()
+		result.Err = nil
+	}
+
+	s.status.record(c.id, result)
+	c.box.Metrics.System.LoginBlocked.Set(c.box.FritzBox.LoginBlockedFor().Seconds())
+	c.box.checkAccountConflicts()
+	return result
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+// runSwitch implements the "switch" command which switches a smart plug on or
+// off using the FRITZ!Box credentials of the configuration file. With -group,
+// all smart plugs of a group are switched at once.
+func runSwitch(args []string, configPath string) int {
+	flags := flag.NewFlagSet("switch", flag.ContinueOnError)
+	group := flags.String("group", "", "switch all smart plugs of this group")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: fritz-mon switch <device> on|off|toggle")
+		fmt.Fprintln(os.Stderr, "       fritz-mon switch -group <group> on|off|toggle")
+		fmt.Fprintln(os.Stderr, "The device is either its name or its AIN. The group is either a group of the")
+		fmt.Fprintln(os.Stderr, "configuration file or a device group of the FRITZ!Box.")
+	}
+
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if (*group == "" && flags.NArg() != 2) || (*group != "" && flags.NArg() != 1) {
+		flags.Usage()
+		return 2
+	}
+
+	action := flags.Arg(flags.NArg() - 1)
+	if action != "on" && action != "off" && action != "toggle" {
+		fmt.Fprintf(os.Stderr, "Unknown action %q\n", action)
+		return 2
+	}
+
+	conf, err := LoadConfiguration(configPath, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	client, err := newStatsClient(conf.Boxes()[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create FRITZ!Box client: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	devices, err := client.Devices(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch devices: %v\n", err)
+		return 1
+	}
+
+	if *group != "" {
+		return switchGroup(ctx, client, devices, conf.Groups, *group, action)
+	}
+
+	device, err := findSwitch(devices, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	on := action == "on"
+	switch action {
+	case "on":
+		err = client.SwitchOn(ctx, device.Identifier)
+	case "off":
+		err = client.SwitchOff(ctx, device.Identifier)
+	case "toggle":
+		on, err = client.SwitchToggle(ctx, device.Identifier)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to switch %q: %v\n", device.Name, err)
+		return 1
+	}
+
+	fmt.Printf("%s is switched %s\n", device.Name, onOff(on))
+
+	return 0
+}
+
+// switchGroup switches all smart plugs of a group, prints a summary and
+// returns the exit code of the command.
+func switchGroup(ctx context.Context, client *fritzbox.Client, devices []fritzbox.Device, groups map[string][]string, group, action string) int {
+	switches, err := groupSwitches(ctx, client, devices, groups, group)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(switches) == 0 {
+		fmt.Fprintf(os.Stderr, "Unknown group %q or group without smart plugs\n", group)
+		return 1
+	}
+
+	var failed int
+	for _, result := range switchAll(ctx, client, switches, action) {
+		if result.Error != "" {
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed to switch %q: %s\n", result.Device, result.Error)
+			continue
+		}
+		fmt.Printf("%s is switched %s\n", result.Device, onOff(result.On))
+	}
+
+	fmt.Printf("Switched %d of %d devices of %q\n", len(switches)-failed, len(switches), group)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+// findSwitch returns the switchable device with the given name or AIN. Spaces
+// in the AIN are optional.
+func findSwitch(devices []fritzbox.Device, nameOrAIN string) (*fritzbox.Device, error) {
+	ain := strings.ReplaceAll(nameOrAIN, " ", "")
+	for i, d := range devices {
+		if d.Name != nameOrAIN && strings.ReplaceAll(d.Identifier, " ", "") != ain {
+			continue
+		}
+
+		if !d.IsSwitch() {
+			return nil, fmt.Errorf("device %q is not a switch", d.Name)
+		}
+
+		return &devices[i], nil
+	}
+
+	return nil, fmt.Errorf("unknown device %q", nameOrAIN)
+}
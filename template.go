@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+	"go.uber.org/zap"
+)
+
+// collectTemplates exports the smart home templates of the FRITZ!Box. They
+// are only informational, so a failure does not fail the device collection.
+func (m *DeviceMetrics) collectTemplates(ctx context.Context, client *fritzbox.Client) {
+	templates, err := client.Templates(ctx)
+	if err != nil {
+		m.logger.Warn("Failed to fetch templates", zap.Error(err))
+		return
+	}
+
+	m.TemplateInfo.Reset() // templates may be renamed or removed
+	m.TemplateDevices.Reset()
+	for _, t := range templates {
+		ain := strings.ReplaceAll(t.Identifier, " ", "")
+		m.TemplateInfo.WithLabelValues(t.Name, ain).Set(1)
+		m.TemplateDevices.WithLabelValues(t.Name, ain).Set(float64(len(t.Devices)))
+	}
+}
+
+// runTemplate implements the "template" command which lists the smart home
+// templates of the FRITZ!Box or applies one of them using the FRITZ!Box
+// credentials of the configuration file.
+func runTemplate(args []string, configPath string) int {
+	flags := flag.NewFlagSet("template", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: fritz-mon template [<template>]")
+		fmt.Fprintln(os.Stderr, "Without arguments all templates are listed. Otherwise the template with the")
+		fmt.Fprintln(os.Stderr, "given name or AIN is applied.")
+	}
+
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	conf, err := LoadConfiguration(configPath, zap.NewNop())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	client, err := newStatsClient(conf.Boxes()[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create FRITZ!Box client: %v\n", err)
+		return 1
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	templates, err := client.Templates(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch templates: %v\n", err)
+		return 1
+	}
+
+	if flags.NArg() == 0 {
+		for _, t := range templates {
+			fmt.Printf("%s\t%s\t%d devices\n", t.Identifier, t.Name, len(t.Devices))
+		}
+		return 0
+	}
+
+	template, err := findTemplate(templates, flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = client.ApplyTemplate(ctx, template.Identifier)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to apply template %q: %v\n", template.Name, err)
+		return 1
+	}
+
+	fmt.Printf("Applied template %s\n", template.Name)
+	return 0
+}
+
+// findTemplate returns the template with the given name or AIN. Spaces in the
+// AIN are optional.
+func findTemplate(templates []fritzbox.Template, nameOrAIN string) (*fritzbox.Template, error) {
+	ain := strings.ReplaceAll(nameOrAIN, " ", "")
+	for i, t := range templates {
+		if t.Name == nameOrAIN || strings.ReplaceAll(t.Identifier, " ", "") == ain {
+			return &templates[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown template %q", nameOrAIN)
+}
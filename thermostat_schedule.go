@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+)
+
+// defaultThermostatTolerance is the deviation from the target temperature in
+// °C which is still considered to be on schedule.
+const defaultThermostatTolerance = 1.0
+
+// collectThermostatSchedule exports how well the measured temperature of a
+// thermostat follows its target temperature. The time spent outside of the
+// tolerance band is accumulated between two readings just like the heating
+// degree days.
+func (m *DeviceMetrics) collectThermostatSchedule(device fritzbox.Device, now time.Time) {
+	last, ok := m.lastThermostatUpdate[device.Name]
+	m.lastThermostatUpdate[device.Name] = now
+
+	measured, hasMeasured := device.Thermostat.GetMeasured()
+	goal, hasGoal := device.Thermostat.GetGoal()
+	if !hasMeasured || !hasGoal {
+		// The thermostat is switched off or permanently on so there is no
+		// schedule to follow.
+		m.TargetTemperature.DeleteLabelValues(m.labels.values(device)...)
+		m.TemperatureDeviation.DeleteLabelValues(m.labels.values(device)...)
+		return
+	}
+
+	labels := m.labels.values(device)
+	deviation := measured - goal
+	m.TargetTemperature.WithLabelValues(labels...).Set(goal)
+	m.TemperatureDeviation.WithLabelValues(labels...).Set(deviation)
+
+	outside := m.OutsideTolerance.WithLabelValues(labels...)
+	if !ok || math.Abs(deviation) <= m.ThermostatTolerance {
+		return
+	}
+
+	if elapsed := now.Sub(last); elapsed > 0 && elapsed <= maxDegreeDayGap {
+		outside.Add(elapsed.Seconds())
+	}
+}
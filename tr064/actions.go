@@ -0,0 +1,79 @@
+package tr064
+
+// Action identifies an action of a TR-064 service.
+type Action struct {
+	Service Service
+	Name    string // e.g. "GetInfo"
+}
+
+// Action returns the action with the given name of the service.
+func (s Service) Action(name string) Action {
+	return Action{Service: s, Name: name}
+}
+
+// The actions of the TR-064 services which are used by this package. See the
+// service descriptions (SCPD) which are linked from /tr64desc.xml for their
+// arguments.
+var (
+	deviceInfoGetInfo         = DeviceInfoService.Action("GetInfo")
+	deviceInfoGetSecurityPort = DeviceInfoService.Action("GetSecurityPort")
+	deviceInfoGetDeviceLog    = DeviceInfoService.Action("GetDeviceLog")
+
+	timeGetInfo = TimeService.Action("GetInfo")
+
+	wanPPPGetStatusInfo         = WANPPPConnectionService.Action("GetStatusInfo")
+	wanPPPGetExternalIPAddress  = WANPPPConnectionService.Action("GetExternalIPAddress")
+	wanIPGetStatusInfo          = WANIPConnectionService.Action("GetStatusInfo")
+	wanIPGetExternalIPAddress   = WANIPConnectionService.Action("GetExternalIPAddress")
+	wanIPGetExternalIPv6Address = WANIPConnectionService.Action("X_AVM_DE_GetExternalIPv6Address")
+
+	wanCommonGetAddonInfos           = WANCommonInterfaceConfig.Action("GetAddonInfos")
+	wanCommonGetCommonLinkProperties = WANCommonInterfaceConfig.Action("GetCommonLinkProperties")
+
+	wanDSLGetInfo = WANDSLInterfaceConfig.Action("GetInfo")
+
+	lanGetStatistics = LANEthernetInterface.Action("GetStatistics")
+
+	hostsGetHostListPath        = HostsService.Action("X_AVM-DE_GetHostListPath")
+	hostsGetHostNumberOfEntries = HostsService.Action("GetHostNumberOfEntries")
+	hostsGetGenericHostEntry    = HostsService.Action("GetGenericHostEntry")
+	hostsGetMeshListPath        = HostsService.Action("X_AVM-DE_GetMeshListPath")
+)
+
+// Names of the actions of the WLANConfiguration services. The service is
+// different for each WLAN (see WLANConfigurationService).
+const (
+	wlanGetInfo                        = "GetInfo"
+	wlanGetStatistics                  = "GetStatistics"
+	wlanGetByteStatistics              = "GetByteStatistics"
+	wlanGetTotalAssociations           = "GetTotalAssociations"
+	wlanGetGenericAssociatedDeviceInfo = "GetGenericAssociatedDeviceInfo"
+	wlanSetEnable                      = "SetEnable"
+)
+
+// hostEntryArgs are the arguments of Hosts#GetGenericHostEntry.
+type hostEntryArgs struct {
+	Index int `xml:"NewIndex"`
+}
+
+// associatedDeviceArgs are the arguments of
+// WLANConfiguration#GetGenericAssociatedDeviceInfo.
+type associatedDeviceArgs struct {
+	Index int `xml:"NewAssociatedDeviceIndex"`
+}
+
+// enableArgs are the arguments of WLANConfiguration#SetEnable.
+type enableArgs struct {
+	Enable boolArg `xml:"NewEnable"`
+}
+
+// boolArg is a boolean action argument. TR-064 expects booleans as "1" or "0"
+// instead of "true" or "false".
+type boolArg bool
+
+func (b boolArg) MarshalText() ([]byte, error) {
+	if b {
+		return []byte("1"), nil
+	}
+	return []byte("0"), nil
+}
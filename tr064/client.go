@@ -0,0 +1,267 @@
+// Package tr064 implements a minimal client for the TR-064 SOAP interface of
+// the FRITZ!Box. In contrast to the AHA HTTP interface in package fritzbox,
+// TR-064 exposes line-level information about the internet connection and the
+// router itself.
+//
+// See https://avm.de/service/schnittstellen/.
+package tr064
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultPort is the port at which the FRITZ!Box serves TR-064 via plain HTTP.
+const DefaultPort = "49000"
+
+// DefaultTLSPort is the port at which the FRITZ!Box serves TR-064 via HTTPS.
+const DefaultTLSPort = "49443"
+
+type Client struct {
+	Username string
+	Password string
+	BaseURL  url.URL // must not be a pointer to avoid modifying this URL during our requests
+
+	// Location is the time zone of the FRITZ!Box which is used for the times
+	// in its event log. If nil, the system time zone is used.
+	Location *time.Location
+
+	http   *http.Client
+	logger *zap.Logger
+
+	digestMu sync.Mutex
+	digest   *digestChallenge // last challenge of the FRITZ!Box which is reused for all requests
+}
+
+// New creates a new TR-064 client. The baseURL is the same URL that is used to
+// access the FRITZ!Box web interface (e.g. http://fritz.box). If it does not
+// contain an explicit port, the TR-064 DefaultPort or DefaultTLSPort is used.
+func New(baseURL, username, password string, logger *zap.Logger) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	if u.Port() == "" {
+		port := DefaultPort
+		if u.Scheme == "https" {
+			port = DefaultTLSPort
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	return &Client{
+		Username: username,
+		Password: password,
+		BaseURL:  *u,
+
+		http:   http.DefaultClient,
+		logger: logger,
+	}, nil
+}
+
+// DiscoverTLSPort asks the FRITZ!Box at which port it serves TR-064 via HTTPS
+// and uses this port for all following requests. The port can be changed in
+// the FRITZ!Box so DefaultTLSPort is only a guess. The port is not part of the
+// device description (tr64desc.xml) itself, but the DeviceInfo service which
+// is described there reports it via plain HTTP without authentication.
+//
+// DiscoverTLSPort must be called before the client is used concurrently. It
+// does nothing if the client does not use HTTPS.
+func (c *Client) DiscoverTLSPort(ctx context.Context) error {
+	if c.BaseURL.Scheme != "https" {
+		return nil
+	}
+
+	plain := &Client{BaseURL: c.BaseURL, http: c.http, logger: c.logger}
+	plain.BaseURL.Scheme = "http"
+	plain.BaseURL.Host = net.JoinHostPort(c.BaseURL.Hostname(), DefaultPort)
+
+	var resp struct {
+		Port int `xml:"NewSecurityPort"`
+	}
+	err := plain.call(ctx, deviceInfoGetSecurityPort, nil, &resp)
+	if err != nil {
+		return err
+	}
+
+	if resp.Port <= 0 || resp.Port > 65535 {
+		return fmt.Errorf("FRITZ!Box reported invalid TR-064 security port %d", resp.Port)
+	}
+
+	c.BaseURL.Host = net.JoinHostPort(c.BaseURL.Hostname(), strconv.Itoa(resp.Port))
+	return nil
+}
+
+// SetHTTPClient replaces the http.DefaultClient which is used by default.
+func (c *Client) SetHTTPClient(client *http.Client) {
+	c.http = client
+}
+
+// SetPassword replaces the password which is used to authenticate the
+// following requests.
+func (c *Client) SetPassword(password string) {
+	c.digestMu.Lock()
+	c.Password = password
+	c.digestMu.Unlock()
+}
+
+// DeviceInfo contains general information about the FRITZ!Box itself.
+type DeviceInfo struct {
+	ManufacturerName string `xml:"NewManufacturerName"`
+	ModelName        string `xml:"NewModelName"`
+	SerialNumber     string `xml:"NewSerialNumber"`
+	SoftwareVersion  string `xml:"NewSoftwareVersion"`
+	HardwareVersion  string `xml:"NewHardwareVersion"`
+	UpTime           int64  `xml:"NewUpTime"` // Seconds since the last reboot of the FRITZ!Box.
+}
+
+func (c *Client) DeviceInfo(ctx context.Context) (*DeviceInfo, error) {
+	c.logger.Debug("Requesting device info")
+
+	var info DeviceInfo
+	err := c.call(ctx, deviceInfoGetInfo, nil, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// CurrentTime returns the local system time of the FRITZ!Box. Note that the
+// FRITZ!Box reports its time only with a resolution of one second.
+func (c *Client) CurrentTime(ctx context.Context) (time.Time, error) {
+	c.logger.Debug("Requesting current time")
+
+	var resp struct {
+		LocalTime string `xml:"NewCurrentLocalTime"` // e.g. "2020-01-04T18:00:25+01:00"
+	}
+
+	err := c.call(ctx, timeGetInfo, nil, &resp)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t, err := time.Parse(time.RFC3339, resp.LocalTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse FRITZ!Box time: %w", err)
+	}
+
+	return t, nil
+}
+
+// ConnectionStatus contains the state of the WAN connection of the FRITZ!Box.
+type ConnectionStatus struct {
+	Status    string `xml:"NewConnectionStatus"`    // e.g. "Connected", "Connecting" or "Disconnected".
+	LastError string `xml:"NewLastConnectionError"` // e.g. "ERROR_NONE".
+	Uptime    int64  `xml:"NewUptime"`              // Seconds since the connection was (re-)established.
+}
+
+func (s ConnectionStatus) IsConnected() bool {
+	return s.Status == "Connected"
+}
+
+// ConnectionStatus returns the status of the WAN connection. DSL boxes usually
+// establish the internet connection via PPP, so the PPP service is queried
+// first. If this connection is not in use, the IP connection is used instead.
+func (c *Client) ConnectionStatus(ctx context.Context) (*ConnectionStatus, error) {
+	c.logger.Debug("Requesting WAN connection status")
+
+	var status ConnectionStatus
+	err := c.call(ctx, wanPPPGetStatusInfo, nil, &status)
+	if err == nil && status.IsConnected() {
+		return &status, nil
+	}
+
+	status = ConnectionStatus{}
+	err = c.call(ctx, wanIPGetStatusInfo, nil, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// ExternalIPAddresses returns the current external IPv4 and IPv6 address of
+// the FRITZ!Box. An address is empty if the FRITZ!Box currently has no such
+// address. Boxes without IPv6 support return an empty IPv6 address.
+func (c *Client) ExternalIPAddresses(ctx context.Context) (ipv4, ipv6 string, err error) {
+	c.logger.Debug("Requesting external IP addresses")
+
+	var v4 struct {
+		Address string `xml:"NewExternalIPAddress"`
+	}
+
+	err = c.call(ctx, wanPPPGetExternalIPAddress, nil, &v4)
+	if err != nil || v4.Address == "" || v4.Address == "0.0.0.0" {
+		err = c.call(ctx, wanIPGetExternalIPAddress, nil, &v4)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	if v4.Address == "0.0.0.0" {
+		v4.Address = ""
+	}
+
+	var v6 struct {
+		Address string `xml:"NewExternalIPv6Address"`
+	}
+
+	err = c.call(ctx, wanIPGetExternalIPv6Address, nil, &v6)
+	if err != nil {
+		c.logger.Debug("Failed to request external IPv6 address", zap.Error(err))
+		v6.Address = ""
+	}
+
+	return v4.Address, v6.Address, nil
+}
+
+// LinkProperties describes the physical link of the FRITZ!Box to the internet.
+type LinkProperties struct {
+	AccessType         string `xml:"NewWANAccessType"` // e.g. "DSL", "Ethernet", "X_AVM-DE_Fiber", "X_AVM-DE_Cable" or "X_AVM-DE_UMTS".
+	UpstreamMaxRate    uint64 `xml:"NewLayer1UpstreamMaxBitRate"`
+	DownstreamMaxRate  uint64 `xml:"NewLayer1DownstreamMaxBitRate"`
+	PhysicalLinkStatus string `xml:"NewPhysicalLinkStatus"` // e.g. "Up" or "Down".
+}
+
+// UplinkType returns a normalized name for the way the FRITZ!Box is connected
+// to the internet: "dsl", "cable", "fiber", "lte" or "ethernet". The latter is
+// used if the FRITZ!Box is connected to an external modem or router.
+func (p LinkProperties) UplinkType() string {
+	switch strings.ToLower(strings.TrimPrefix(p.AccessType, "X_AVM-DE_")) {
+	case "dsl":
+		return "dsl"
+	case "cable", "docsis":
+		return "cable"
+	case "fiber", "gpon", "aon":
+		return "fiber"
+	case "umts", "lte", "mobile":
+		return "lte"
+	case "ethernet":
+		return "ethernet"
+	default:
+		return "unknown"
+	}
+}
+
+func (c *Client) LinkProperties(ctx context.Context) (*LinkProperties, error) {
+	c.logger.Debug("Requesting WAN link properties")
+
+	var props LinkProperties
+	err := c.call(ctx, wanCommonGetCommonLinkProperties, nil, &props)
+	if err != nil {
+		return nil, err
+	}
+
+	return &props, nil
+}
@@ -0,0 +1,86 @@
+package tr064
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// descriptionPath is the path of the device description which lists all
+// TR-064 services that are offered by the FRITZ!Box.
+const descriptionPath = "/tr64desc.xml"
+
+// Services returns the types of all TR-064 services which are offered by the
+// FRITZ!Box (e.g. "urn:dslforum-org:service:DeviceInfo:1").
+func (c *Client) Services(ctx context.Context) ([]string, error) {
+	reqURL := c.BaseURL
+	reqURL.Path = descriptionPath
+
+	req, err := http.NewRequest("GET", reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	defer discardBody(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad HTTP status code: %s", resp.Status)
+	}
+
+	// Services are nested in embedded devices (e.g. WANDevice >
+	// WANConnectionDevice) so we simply collect all service types regardless
+	// of where they appear in the document.
+	var services []string
+	dec := xml.NewDecoder(io.LimitReader(resp.Body, maxResponseSize))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode device description: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "serviceType" {
+			continue
+		}
+
+		var serviceType string
+		if err := dec.DecodeElement(&serviceType, &start); err != nil {
+			return nil, fmt.Errorf("failed to decode device description: %w", err)
+		}
+		services = append(services, strings.TrimSpace(serviceType))
+	}
+
+	return services, nil
+}
+
+// IsIPClient returns true if the FRITZ!Box does not offer any WAN connection.
+// This is the case if it runs as IP client or mesh repeater behind another
+// router.
+func (c *Client) IsIPClient(ctx context.Context) (bool, error) {
+	services, err := c.Services(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if len(services) == 0 {
+		return false, fmt.Errorf("device description does not contain any services")
+	}
+
+	for _, s := range services {
+		if s == WANIPConnectionService.Type || s == WANPPPConnectionService.Type {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
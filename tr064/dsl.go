@@ -0,0 +1,64 @@
+package tr064
+
+import "context"
+
+// DSLInfo contains the line-level information of the DSL connection. Rates
+// are given in kbit/s. Noise margins and attenuations are given in 0.1 dB.
+type DSLInfo struct {
+	Status                string `xml:"NewStatus"` // e.g. "Up" or "Initializing"
+	UpstreamCurrRate      uint64 `xml:"NewUpstreamCurrRate"`
+	DownstreamCurrRate    uint64 `xml:"NewDownstreamCurrRate"`
+	UpstreamMaxRate       uint64 `xml:"NewUpstreamMaxRate"`
+	DownstreamMaxRate     uint64 `xml:"NewDownstreamMaxRate"`
+	UpstreamNoiseMargin   int64  `xml:"NewUpstreamNoiseMargin"`
+	DownstreamNoiseMargin int64  `xml:"NewDownstreamNoiseMargin"`
+	UpstreamAttenuation   int64  `xml:"NewUpstreamAttenuation"`
+	DownstreamAttenuation int64  `xml:"NewDownstreamAttenuation"`
+}
+
+// DSLInfo returns the line-level information of the DSL connection. It fails
+// if the FRITZ!Box is not connected via DSL.
+func (c *Client) DSLInfo(ctx context.Context) (*DSLInfo, error) {
+	c.logger.Debug("Requesting DSL information")
+
+	var info DSLInfo
+	err := c.call(ctx, wanDSLGetInfo, nil, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// WANTraffic contains the total number of bytes which were transferred via the
+// internet connection since the last reboot of the FRITZ!Box.
+type WANTraffic struct {
+	BytesSent     uint64
+	BytesReceived uint64
+}
+
+// WANTraffic returns the traffic counters of the internet connection. Newer
+// FRITZ!OS versions report 64 bit counters, older versions only 32 bit
+// counters which wrap around after 4 GiB.
+func (c *Client) WANTraffic(ctx context.Context) (*WANTraffic, error) {
+	c.logger.Debug("Requesting WAN traffic")
+
+	var info struct {
+		BytesSent       uint64 `xml:"NewTotalBytesSent"`
+		BytesReceived   uint64 `xml:"NewTotalBytesReceived"`
+		BytesSent64     uint64 `xml:"NewX_AVM_DE_TotalBytesSent64"`
+		BytesReceived64 uint64 `xml:"NewX_AVM_DE_TotalBytesReceived64"`
+	}
+	err := c.call(ctx, wanCommonGetAddonInfos, nil, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	traffic := &WANTraffic{BytesSent: info.BytesSent, BytesReceived: info.BytesReceived}
+	if info.BytesSent64 > 0 || info.BytesReceived64 > 0 {
+		traffic.BytesSent = info.BytesSent64
+		traffic.BytesReceived = info.BytesReceived64
+	}
+
+	return traffic, nil
+}
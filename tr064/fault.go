@@ -0,0 +1,88 @@
+package tr064
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors which can be matched against the error of a TR-064 action via
+// errors.Is.
+var (
+	// ErrUnauthorized is returned if the FRITZ!Box rejects the credentials,
+	// even after responding to its digest authentication challenge.
+	ErrUnauthorized = errors.New("FRITZ!Box rejected username or password")
+
+	// ErrInvalidAction is returned if the FRITZ!Box does not know the action,
+	// e.g. because the model or FRITZ!OS version does not support it.
+	ErrInvalidAction = errors.New("action is not supported by the FRITZ!Box")
+
+	// ErrNotAuthorized is returned if the user is not allowed to execute the
+	// action, e.g. because it lacks the "FRITZ!Box settings" permission.
+	ErrNotAuthorized = errors.New("user is not authorized to execute the action")
+
+	// ErrNoSuchEntry is returned if an action was called with an index which
+	// does not exist (e.g. when iterating over the hosts of the FRITZ!Box).
+	ErrNoSuchEntry = errors.New("no such entry")
+)
+
+// UPnP error codes which are reported in SOAP faults by the FRITZ!Box.
+const (
+	faultInvalidAction       = 401
+	faultInvalidArgs         = 402
+	faultActionFailed        = 501
+	faultArgumentInvalid     = 600
+	faultActionNotAuthorized = 606
+	faultArrayIndexInvalid   = 713
+	faultNoSuchEntry         = 714
+	faultInternalError       = 820
+)
+
+// Fault is returned if the FRITZ!Box responds to an action with a SOAP fault.
+type Fault struct {
+	Service     string // control URL of the service, e.g. "/upnp/control/deviceinfo"
+	Action      string // e.g. "GetInfo"
+	Code        int    // UPnP error code, e.g. 606
+	Description string // UPnP error description, e.g. "Action Not Authorized"
+}
+
+func (f *Fault) Error() string {
+	msg := fmt.Sprintf("%s#%s: SOAP fault %d: %s", f.Service, f.Action, f.Code, f.Description)
+	if hint := f.hint(); hint != "" {
+		msg += " (" + hint + ")"
+	}
+	return msg
+}
+
+// hint explains the fault to the user since the descriptions reported by the
+// FRITZ!Box are often not very helpful.
+func (f *Fault) hint() string {
+	switch f.Code {
+	case faultInvalidAction:
+		return "the FRITZ!Box model or FRITZ!OS version does not support this action"
+	case faultInvalidArgs, faultArgumentInvalid:
+		return "the FRITZ!Box rejected the arguments of the action"
+	case faultActionNotAuthorized:
+		return `the user needs the "FRITZ!Box settings" permission and TR-064 access must be allowed under Home Network > Network > Network Settings`
+	case faultArrayIndexInvalid, faultNoSuchEntry:
+		return "the requested entry does not exist"
+	case faultActionFailed, faultInternalError:
+		return "the FRITZ!Box failed to execute the action, this is usually temporary"
+	default:
+		return ""
+	}
+}
+
+// Is makes it possible to match a Fault against the errors of this package
+// via errors.Is.
+func (f *Fault) Is(target error) bool {
+	switch target {
+	case ErrInvalidAction:
+		return f.Code == faultInvalidAction
+	case ErrNotAuthorized:
+		return f.Code == faultActionNotAuthorized
+	case ErrNoSuchEntry:
+		return f.Code == faultArrayIndexInvalid || f.Code == faultNoSuchEntry
+	default:
+		return false
+	}
+}
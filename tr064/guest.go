@@ -0,0 +1,44 @@
+package tr064
+
+import (
+	"context"
+	"fmt"
+)
+
+// GuestWLAN is the guest network of the FRITZ!Box.
+type GuestWLAN struct {
+	Index   int // the n in WLANConfiguration:n
+	SSID    string
+	Enabled bool
+}
+
+// GuestWLAN returns the state of the guest network.
+func (c *Client) GuestWLAN(ctx context.Context) (*GuestWLAN, error) {
+	c.logger.Debug("Requesting guest WLAN")
+
+	var guest *GuestWLAN
+	for n := 1; n <= maxWLANs; n++ {
+		info, err := c.wlanInfo(ctx, WLANConfigurationService(n))
+		if err != nil && n == 1 {
+			return nil, err
+		}
+		if err != nil {
+			break // there are no more WLANs
+		}
+
+		guest = &GuestWLAN{Index: n, SSID: info.SSID, Enabled: info.Enabled}
+	}
+
+	if guest == nil || !isGuestWLAN(guest.Index, guest.Index) {
+		return nil, fmt.Errorf("FRITZ!Box does not offer a guest WLAN")
+	}
+
+	return guest, nil
+}
+
+// SetWLANEnabled enables or disables the n-th WLAN of the FRITZ!Box. This
+// requires a user with the permission to change the FRITZ!Box settings.
+func (c *Client) SetWLANEnabled(ctx context.Context, n int, enabled bool) error {
+	action := WLANConfigurationService(n).Action(wlanSetEnable)
+	return c.call(ctx, action, enableArgs{Enable: boolArg(enabled)}, nil)
+}
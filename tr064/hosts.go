@@ -0,0 +1,117 @@
+package tr064
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// HostsService provides information about all hosts known to the FRITZ!Box.
+var HostsService = Service{"urn:dslforum-org:service:Hosts:1", "/upnp/control/hosts"}
+
+// Host is a network device which is or was connected to the FRITZ!Box.
+type Host struct {
+	MACAddress    string `xml:"NewMACAddress"`
+	IPAddress     string `xml:"NewIPAddress"`
+	HostName      string `xml:"NewHostName"`      // the name which is shown in the FRITZ!Box network overview
+	InterfaceType string `xml:"NewInterfaceType"` // e.g. "Ethernet" or "802.11"
+	Active        bool   `xml:"NewActive"`
+	Speed         int    `xml:"-"` // link speed in Mbit/s, only reported via the host list
+	Guest         bool   `xml:"-"` // connected to the guest network, only reported via the host list
+}
+
+// IsWLAN returns true if the host is connected via WLAN.
+func (h Host) IsWLAN() bool {
+	return h.InterfaceType == "802.11"
+}
+
+// Hosts returns all hosts which are known to the FRITZ!Box. Newer FRITZ!OS
+// versions offer the whole list as a single document which is much faster
+// than requesting each host individually.
+func (c *Client) Hosts(ctx context.Context) ([]Host, error) {
+	c.logger.Debug("Requesting hosts")
+
+	hosts, err := c.hostList(ctx)
+	if err == nil {
+		return hosts, nil
+	}
+
+	c.logger.Debug("Failed to download host list, requesting hosts individually", zap.Error(err))
+	return c.genericHostEntries(ctx)
+}
+
+func (c *Client) hostList(ctx context.Context) ([]Host, error) {
+	var resp struct {
+		Path string `xml:"NewX_AVM-DE_HostListPath"`
+	}
+	err := c.call(ctx, hostsGetHostListPath, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.download(ctx, resp.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download host list: %w", err)
+	}
+
+	defer discardBody(body)
+
+	var list struct {
+		Items []struct {
+			MACAddress    string `xml:"MACAddress"`
+			IPAddress     string `xml:"IPAddress"`
+			HostName      string `xml:"HostName"`
+			InterfaceType string `xml:"InterfaceType"`
+			Active        string `xml:"Active"`
+			Speed         string `xml:"X_AVM-DE_Speed"`
+			Guest         string `xml:"X_AVM-DE_Guest"`
+		} `xml:"Item"`
+	}
+	err = xml.NewDecoder(io.LimitReader(body, maxResponseSize)).Decode(&list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode host list: %w", err)
+	}
+
+	hosts := make([]Host, 0, len(list.Items))
+	for _, item := range list.Items {
+		speed, _ := strconv.Atoi(item.Speed)
+		hosts = append(hosts, Host{
+			MACAddress:    item.MACAddress,
+			IPAddress:     item.IPAddress,
+			HostName:      item.HostName,
+			InterfaceType: item.InterfaceType,
+			Active:        item.Active == "1",
+			Speed:         speed,
+			Guest:         item.Guest == "1",
+		})
+	}
+
+	return hosts, nil
+}
+
+func (c *Client) genericHostEntries(ctx context.Context) ([]Host, error) {
+	var count struct {
+		Entries int `xml:"NewHostNumberOfEntries"`
+	}
+	err := c.call(ctx, hostsGetHostNumberOfEntries, nil, &count)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := make([]Host, 0, count.Entries)
+	for i := 0; i < count.Entries; i++ {
+		var host Host
+		err := c.call(ctx, hostsGetGenericHostEntry, hostEntryArgs{Index: i}, &host)
+		if err != nil {
+			return nil, err
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
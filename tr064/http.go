@@ -0,0 +1,235 @@
+package tr064
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxResponseSize limits how much of a response we read into memory. Host
+// lists of large networks are only a fraction of this size, so any bigger
+// response is most likely broken and must not bloat the memory of fritz-mon.
+const maxResponseSize = 4 << 20
+
+// post sends the given body to the FRITZ!Box. Once the FRITZ!Box required
+// authentication, all following requests are authenticated right away by
+// reusing its last digest challenge. If the FRITZ!Box rejects the request
+// because it requires authentication or the nonce expired, the request is
+// repeated once with a response to the new challenge.
+func (c *Client) post(ctx context.Context, reqURL url.URL, header http.Header, body []byte) ([]byte, error) {
+	if auth, ok := c.reuseDigest(reqURL); ok {
+		header.Set("Authorization", auth)
+	}
+
+	resp, err := c.do(ctx, reqURL, header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		discardBody(resp.Body)
+
+		auth, err := c.digestAuthorization(challenge, reqURL)
+		if err != nil {
+			return nil, err
+		}
+
+		header.Set("Authorization", auth)
+		resp, err = c.do(ctx, reqURL, header, body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	discardBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP response body: %w", err)
+	}
+
+	if len(respBody) > maxResponseSize {
+		return nil, fmt.Errorf("HTTP response body exceeds %d bytes", maxResponseSize)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		c.forgetDigest()
+		return nil, ErrUnauthorized
+	}
+
+	// SOAP faults are transmitted with status 500 so we let the caller decode them.
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusInternalServerError {
+		return nil, fmt.Errorf("bad HTTP status code: %s", resp.Status)
+	}
+
+	return respBody, nil
+}
+
+func (c *Client) do(ctx context.Context, reqURL url.URL, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("POST", reqURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+
+	req.Header = header
+	req = req.WithContext(ctx)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	return resp, nil
+}
+
+// digestChallenge is a WWW-Authenticate challenge of the FRITZ!Box. The same
+// nonce can be used for multiple requests as long as the nonce count is
+// incremented with each request (see RFC 2617).
+type digestChallenge struct {
+	realm  string
+	nonce  string
+	opaque string
+	qop    bool   // true if the FRITZ!Box supports qop=auth
+	nc     uint32 // number of requests which used this nonce
+}
+
+// digestAuthorization remembers the given WWW-Authenticate challenge and
+// computes the Authorization header of a request in response to it.
+func (c *Client) digestAuthorization(challenge string, reqURL url.URL) (string, error) {
+	if !strings.HasPrefix(challenge, "Digest ") {
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	params := parseDigestChallenge(strings.TrimPrefix(challenge, "Digest "))
+	if params["nonce"] == "" {
+		return "", fmt.Errorf("authentication challenge is missing a nonce")
+	}
+
+	c.digestMu.Lock()
+	defer c.digestMu.Unlock()
+
+	c.digest = &digestChallenge{
+		realm:  params["realm"],
+		nonce:  params["nonce"],
+		opaque: params["opaque"],
+		qop:    params["qop"] != "",
+	}
+
+	return c.authorize(reqURL), nil
+}
+
+// reuseDigest computes the Authorization header of a request in response to
+// the last challenge of the FRITZ!Box. This saves the additional round trip
+// to get a new challenge for each request.
+func (c *Client) reuseDigest(reqURL url.URL) (string, bool) {
+	c.digestMu.Lock()
+	defer c.digestMu.Unlock()
+
+	if c.digest == nil {
+		return "", false
+	}
+
+	return c.authorize(reqURL), true
+}
+
+// forgetDigest makes the next request ask for a new challenge.
+func (c *Client) forgetDigest() {
+	c.digestMu.Lock()
+	c.digest = nil
+	c.digestMu.Unlock()
+}
+
+// authorize computes the Authorization header as specified in RFC 2617. The
+// caller must hold the digestMu lock.
+func (c *Client) authorize(reqURL url.URL) string {
+	d := c.digest
+	d.nc++
+
+	cnonceBytes := make([]byte, 8)
+	_, _ = rand.Read(cnonceBytes)
+	cnonce := fmt.Sprintf("%x", cnonceBytes)
+	nc := fmt.Sprintf("%08x", d.nc)
+	uri := reqURL.RequestURI()
+
+	ha1 := md5Hex(c.Username + ":" + d.realm + ":" + c.Password)
+	ha2 := md5Hex("POST:" + uri)
+
+	var response string
+	if d.qop {
+		response = md5Hex(ha1 + ":" + d.nonce + ":" + nc + ":" + cnonce + ":auth:" + ha2)
+	} else {
+		response = md5Hex(ha1 + ":" + d.nonce + ":" + ha2)
+	}
+
+	auth := fmt.Sprintf(`Digest username=%q, realm=%q, nonce=%q, uri=%q, algorithm=MD5, response=%q`,
+		c.Username, d.realm, d.nonce, uri, response)
+	if d.opaque != "" {
+		auth += fmt.Sprintf(`, opaque=%q`, d.opaque)
+	}
+	if d.qop {
+		auth += fmt.Sprintf(`, qop=auth, nc=%s, cnonce=%q`, nc, cnonce)
+	}
+
+	return auth
+}
+
+func parseDigestChallenge(s string) map[string]string {
+	params := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+	return params
+}
+
+// download requests a document whose path was returned by a TR-064 action,
+// e.g. the host list. The caller must discard the returned body.
+func (c *Client) download(ctx context.Context, path string) (io.ReadCloser, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+
+	reqURL := c.BaseURL.ResolveReference(ref)
+	req, err := http.NewRequest("GET", reqURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+
+	resp, err := c.http.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		discardBody(resp.Body)
+		return nil, fmt.Errorf("bad HTTP status code: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+// maxDiscardSize limits how much of a remaining body discardBody reads. Larger
+// bodies (e.g. a never-ending error page) are not worth waiting for just to
+// reuse the connection.
+const maxDiscardSize = 64 << 10
+
+// discardBody reads the remaining body before closing it. Otherwise the
+// underlying connection cannot be reused for the next request.
+func discardBody(body io.ReadCloser) {
+	_, _ = io.CopyN(ioutil.Discard, body, maxDiscardSize)
+	_ = body.Close()
+}
+
+func md5Hex(s string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(s)))
+}
@@ -0,0 +1,110 @@
+package tr064
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// digestServer is a TR-064 endpoint which requires digest authentication like
+// the FRITZ!Box. It accepts each nonce until it is expired via expire.
+type digestServer struct {
+	*httptest.Server
+	t *testing.T
+
+	nonce       atomic.Value // current nonce
+	challenges  int32        // number of responses with a new challenge
+	connections int32
+	lastNC      string
+}
+
+func newDigestServer(t *testing.T) *digestServer {
+	s := &digestServer{t: t}
+	s.nonce.Store("nonce-1")
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serve))
+	s.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&s.connections, 1)
+		}
+	}
+	s.Start()
+	return s
+}
+
+func (s *digestServer) serve(w http.ResponseWriter, r *http.Request) {
+	params := parseDigestChallenge(strings.TrimPrefix(r.Header.Get("Authorization"), "Digest "))
+	if params["nonce"] != s.nonce.Load().(string) || !s.validResponse(r, params) {
+		atomic.AddInt32(&s.challenges, 1)
+		w.Header().Set("WWW-Authenticate", `Digest realm="F!Box SOAP-Auth", nonce="`+s.nonce.Load().(string)+`", algorithm=MD5, qop="auth"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if params["nc"] <= s.lastNC {
+		s.t.Errorf("nonce count %s was not incremented (last %s)", params["nc"], s.lastNC)
+	}
+	s.lastNC = params["nc"]
+
+	_, _ = w.Write([]byte("<ok/>"))
+}
+
+func (s *digestServer) validResponse(r *http.Request, params map[string]string) bool {
+	ha1 := md5Hex("user:" + params["realm"] + ":password")
+	ha2 := md5Hex(r.Method + ":" + params["uri"])
+	want := md5Hex(ha1 + ":" + params["nonce"] + ":" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+	return params["response"] == want
+}
+
+func (s *digestServer) expire(nonce string) {
+	s.nonce.Store(nonce)
+	s.lastNC = ""
+}
+
+func TestPostReusesDigestNonce(t *testing.T) {
+	srv := newDigestServer(t)
+	defer srv.Close()
+
+	c, err := New(srv.URL, "user", "password", zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqURL, _ := url.Parse(srv.URL + "/upnp/control/deviceinfo")
+
+	post := func() {
+		t.Helper()
+		body, err := c.post(context.Background(), *reqURL, http.Header{}, []byte("<request/>"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != "<ok/>" {
+			t.Fatalf("unexpected response %q", body)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		post()
+	}
+
+	if n := atomic.LoadInt32(&srv.challenges); n != 1 {
+		t.Errorf("server sent %d challenges, want 1", n)
+	}
+	if n := atomic.LoadInt32(&srv.connections); n != 1 {
+		t.Errorf("client opened %d connections, want 1", n)
+	}
+
+	// An expired nonce is replaced by the new challenge of the FRITZ!Box.
+	srv.expire("nonce-2")
+	post()
+	post()
+
+	if n := atomic.LoadInt32(&srv.challenges); n != 2 {
+		t.Errorf("server sent %d challenges after the nonce expired, want 2", n)
+	}
+}
@@ -0,0 +1,24 @@
+package tr064
+
+import "context"
+
+// LANStatistics contains the traffic counters of the LAN side of the
+// FRITZ!Box. All counters are reset when the FRITZ!Box reboots.
+type LANStatistics struct {
+	BytesSent       uint64 `xml:"NewBytesSent"`
+	BytesReceived   uint64 `xml:"NewBytesReceived"`
+	PacketsSent     uint64 `xml:"NewPacketsSent"`
+	PacketsReceived uint64 `xml:"NewPacketsReceived"`
+}
+
+func (c *Client) LANStatistics(ctx context.Context) (*LANStatistics, error) {
+	c.logger.Debug("Requesting LAN statistics")
+
+	var stats LANStatistics
+	err := c.call(ctx, lanGetStatistics, nil, &stats)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,62 @@
+package tr064
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// LogEntry is a single line of the FRITZ!Box event log.
+type LogEntry struct {
+	Time    time.Time
+	Message string
+}
+
+// logTimeLayout is the layout of the timestamp at the beginning of each line
+// of the FRITZ!Box event log.
+const logTimeLayout = "02.01.06 15:04:05"
+
+// DeviceLog returns the entries of the FRITZ!Box event log, newest first. The
+// FRITZ!Box does not include a time zone in its log so the timestamps are
+// interpreted in the local time zone of fritz-mon.
+func (c *Client) DeviceLog(ctx context.Context) ([]LogEntry, error) {
+	c.logger.Debug("Requesting device log")
+
+	var resp struct {
+		Log string `xml:"NewDeviceLog"`
+	}
+
+	err := c.call(ctx, deviceInfoGetDeviceLog, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := c.Location
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return parseDeviceLog(resp.Log, loc), nil
+}
+
+func parseDeviceLog(log string, loc *time.Location) []LogEntry {
+	var entries []LogEntry
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) <= len(logTimeLayout) {
+			continue
+		}
+
+		t, err := time.ParseInLocation(logTimeLayout, line[:len(logTimeLayout)], loc)
+		if err != nil {
+			continue // not a log line we understand
+		}
+
+		entries = append(entries, LogEntry{
+			Time:    t,
+			Message: strings.TrimSpace(line[len(logTimeLayout):]),
+		})
+	}
+
+	return entries
+}
@@ -0,0 +1,179 @@
+package tr064
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Mesh roles of the nodes of the mesh list.
+const (
+	MeshRoleMaster = "master" // the FRITZ!Box which controls the mesh
+	MeshRoleSlave  = "slave"  // a mesh repeater
+)
+
+// Backhaul is the uplink of a mesh repeater to the rest of the mesh.
+type Backhaul struct {
+	Repeater      string // name of the repeater
+	MACAddress    string // MAC address of the repeater
+	Medium        string // "ethernet" or the WLAN band, e.g. "2.4GHz" or "5GHz"
+	RateRx        int    // current data rate in kbit/s received by the repeater
+	RateTx        int    // current data rate in kbit/s sent by the repeater
+	MaxRateRx     int    // maximum data rate in kbit/s which the link could receive
+	MaxRateTx     int    // maximum data rate in kbit/s which the link could send
+	Connected     bool
+	ConnectedNode string // name of the node at the other end of the link
+}
+
+// meshList is the JSON document of Hosts#X_AVM-DE_GetMeshListPath.
+type meshList struct {
+	Nodes []meshNode `json:"nodes"`
+}
+
+type meshNode struct {
+	UID        string          `json:"uid"`
+	Name       string          `json:"device_name"`
+	MACAddress string          `json:"device_mac_address"`
+	IsMeshed   bool            `json:"is_meshed"`
+	Role       string          `json:"mesh_role"`
+	Interfaces []meshInterface `json:"node_interfaces"`
+}
+
+type meshInterface struct {
+	UID   string     `json:"uid"`
+	Name  string     `json:"name"` // e.g. "LAN:1", "AP:5G:0" or "UPLINK:2G:0"
+	Type  string     `json:"type"` // "LAN" or "WLAN"
+	Links []meshLink `json:"node_links"`
+}
+
+type meshLink struct {
+	Type       string `json:"type"` // "LAN" or "WLAN"
+	State      string `json:"state"`
+	Node1      string `json:"node_1_uid"`
+	Node2      string `json:"node_2_uid"`
+	Interface1 string `json:"node_interface_1_uid"`
+	Interface2 string `json:"node_interface_2_uid"`
+	MaxRateRx  int    `json:"max_data_rate_rx"`
+	MaxRateTx  int    `json:"max_data_rate_tx"`
+	CurRateRx  int    `json:"cur_data_rate_rx"`
+	CurRateTx  int    `json:"cur_data_rate_tx"`
+}
+
+// MeshBackhauls returns the uplinks of all mesh repeaters of the FRITZ!Box.
+// It must be called on the FRITZ!Box which is the mesh master since only it
+// knows the whole mesh.
+func (c *Client) MeshBackhauls(ctx context.Context) ([]Backhaul, error) {
+	c.logger.Debug("Requesting mesh list")
+
+	var resp struct {
+		Path string `xml:"NewX_AVM-DE_MeshListPath"`
+	}
+	err := c.call(ctx, hostsGetMeshListPath, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.download(ctx, resp.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download mesh list: %w", err)
+	}
+
+	defer discardBody(body)
+
+	var list meshList
+	err = json.NewDecoder(io.LimitReader(body, maxResponseSize)).Decode(&list)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode mesh list: %w", err)
+	}
+
+	return list.backhauls(), nil
+}
+
+// backhauls returns the uplinks of all repeaters in the mesh list. The uplink
+// of a repeater is its link to another meshed node, preferring connected links
+// since the mesh list also contains links which were used in the past.
+func (l meshList) backhauls() []Backhaul {
+	nodes := make(map[string]meshNode, len(l.Nodes))
+	for _, node := range l.Nodes {
+		nodes[node.UID] = node
+	}
+
+	var result []Backhaul
+	for _, node := range l.Nodes {
+		if !node.IsMeshed || node.Role != MeshRoleSlave {
+			continue
+		}
+
+		var backhaul *Backhaul
+		for _, iface := range node.Interfaces {
+			for _, link := range iface.Links {
+				b, ok := link.backhaul(node, iface, nodes)
+				if !ok || (backhaul != nil && (backhaul.Connected || !b.Connected)) {
+					continue
+				}
+				backhaul = &b
+			}
+		}
+
+		if backhaul != nil {
+			result = append(result, *backhaul)
+		}
+	}
+
+	return result
+}
+
+// backhaul returns the link as uplink of the given repeater if it connects the
+// repeater to another meshed node.
+func (link meshLink) backhaul(repeater meshNode, iface meshInterface, nodes map[string]meshNode) (Backhaul, bool) {
+	// The data rates of the link are reported from the point of view of the
+	// first node, so they are swapped if the repeater is the second one.
+	peerUID, rx, tx := link.Node2, link.CurRateRx, link.CurRateTx
+	maxRx, maxTx := link.MaxRateRx, link.MaxRateTx
+	switch repeater.UID {
+	case link.Node1:
+	case link.Node2:
+		peerUID, rx, tx = link.Node1, link.CurRateTx, link.CurRateRx
+		maxRx, maxTx = link.MaxRateTx, link.MaxRateRx
+	default:
+		return Backhaul{}, false
+	}
+
+	peer, ok := nodes[peerUID]
+	if !ok || !peer.IsMeshed {
+		return Backhaul{}, false
+	}
+
+	return Backhaul{
+		Repeater:      repeater.Name,
+		MACAddress:    repeater.MACAddress,
+		Medium:        meshMedium(link.Type, iface.Name),
+		RateRx:        rx,
+		RateTx:        tx,
+		MaxRateRx:     maxRx,
+		MaxRateTx:     maxTx,
+		Connected:     link.State == "CONNECTED",
+		ConnectedNode: peer.Name,
+	}, true
+}
+
+// meshMedium returns the medium of a link from its type and the name of the
+// interface, e.g. "UPLINK:5G:0" is a 5 GHz WLAN uplink.
+func meshMedium(linkType, ifaceName string) string {
+	if linkType != "WLAN" {
+		return "ethernet"
+	}
+
+	switch {
+	case strings.Contains(ifaceName, ":2G"):
+		return "2.4GHz"
+	case strings.Contains(ifaceName, ":5G"):
+		return "5GHz"
+	case strings.Contains(ifaceName, ":6G"):
+		return "6GHz"
+	default:
+		return "wlan"
+	}
+}
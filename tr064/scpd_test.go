@@ -0,0 +1,445 @@
+package tr064
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// The files in testdata follow the documents which a FRITZ!Box 7590 with
+// FRITZ!OS 7.57 serves. The SCPD files are trimmed to the actions which are
+// used by this package and a few of their neighbours, and the serial number
+// and log message are made up.
+
+// fixtureServer serves the recorded device description and service
+// descriptions of testdata. Actions are answered with the recorded response
+// in testdata/<response> which is registered for their control URL.
+type fixtureServer struct {
+	*httptest.Server
+	t *testing.T
+
+	description string                    // file which is served as /tr64desc.xml
+	responses   map[string]recordedAction // by control URL
+	requests    []soapRequest
+}
+
+type recordedAction struct {
+	response string
+	status   int
+}
+
+// soapRequest is an action request as it was received by the fixtureServer.
+type soapRequest struct {
+	Path       string
+	SOAPAction string
+	Namespace  string // xmlns:u of the action element
+	Action     string
+	Args       map[string]string
+}
+
+func newFixtureServer(t *testing.T) *fixtureServer {
+	s := &fixtureServer{
+		t:           t,
+		description: "tr64desc.xml",
+		responses:   map[string]recordedAction{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *fixtureServer) respond(controlURL, response string, status int) {
+	s.responses[controlURL] = recordedAction{response: response, status: status}
+}
+
+func (s *fixtureServer) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		name := strings.TrimPrefix(r.URL.Path, "/")
+		if r.URL.Path == descriptionPath {
+			name = s.description
+		}
+		http.ServeFile(w, r, filepath.Join("testdata", name))
+		return
+	}
+
+	s.requests = append(s.requests, s.decodeRequest(r))
+
+	action, ok := s.responses[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := ioutil.ReadFile(filepath.Join("testdata", action.response))
+	if err != nil {
+		s.t.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", `text/xml; charset="utf-8"`)
+	w.WriteHeader(action.status)
+	_, _ = w.Write(body)
+}
+
+func (s *fixtureServer) decodeRequest(r *http.Request) soapRequest {
+	var env struct {
+		Body struct {
+			Action struct {
+				XMLName xml.Name
+				Attrs   []xml.Attr `xml:",any,attr"`
+				Args    []struct {
+					XMLName xml.Name
+					Value   string `xml:",chardata"`
+				} `xml:",any"`
+			} `xml:",any"`
+		} `xml:"Body"`
+	}
+
+	if err := xml.NewDecoder(r.Body).Decode(&env); err != nil {
+		s.t.Errorf("Failed to decode SOAP request: %v", err)
+	}
+
+	req := soapRequest{
+		Path:       r.URL.Path,
+		SOAPAction: r.Header.Get("SOAPAction"),
+		Namespace:  env.Body.Action.XMLName.Space,
+		Action:     env.Body.Action.XMLName.Local,
+		Args:       map[string]string{},
+	}
+	for _, arg := range env.Body.Action.Args {
+		req.Args[arg.XMLName.Local] = arg.Value
+	}
+
+	return req
+}
+
+func (s *fixtureServer) client(t *testing.T) *Client {
+	c, err := New(s.URL, "user", "password", zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestServices(t *testing.T) {
+	srv := newFixtureServer(t)
+	defer srv.Close()
+	c := srv.client(t)
+	ctx := context.Background()
+
+	services, err := c.Services(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Services of the root device and of the nested LAN, WAN and WAN
+	// connection devices.
+	for _, want := range []string{
+		DeviceInfoService.Type,
+		HostsService.Type,
+		WLANConfigurationService(3).Type,
+		WANCommonInterfaceConfig.Type,
+		WANIPConnectionService.Type,
+		WANPPPConnectionService.Type,
+	} {
+		if !contains(services, want) {
+			t.Errorf("Services() = %v, missing %q", services, want)
+		}
+	}
+
+	ipClient, err := c.IsIPClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ipClient {
+		t.Error("IsIPClient() = true for a FRITZ!Box with WAN connection")
+	}
+
+	srv.description = "tr64desc_ipclient.xml"
+	ipClient, err = c.IsIPClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ipClient {
+		t.Error("IsIPClient() = false for a FRITZ!Repeater without WAN connection")
+	}
+}
+
+// TestBindingsMatchSCPD checks the typed bindings of this package against the
+// recorded service descriptions: each action must exist at the control URL of
+// its service, the arguments we send must be exactly the input arguments of
+// the action and each field we decode must be an output argument.
+func TestBindingsMatchSCPD(t *testing.T) {
+	desc := loadDescription(t, "tr64desc.xml")
+
+	tests := []struct {
+		action Action
+		in     interface{} // nil if the action has no input arguments
+		out    interface{} // nil if the response is decoded into an unexported type
+	}{
+		{action: deviceInfoGetInfo, out: DeviceInfo{}},
+		{action: deviceInfoGetSecurityPort},
+		{action: deviceInfoGetDeviceLog},
+		{action: timeGetInfo},
+		{action: wanPPPGetStatusInfo, out: ConnectionStatus{}},
+		{action: wanPPPGetExternalIPAddress},
+		{action: wanIPGetStatusInfo, out: ConnectionStatus{}},
+		{action: wanIPGetExternalIPAddress},
+		{action: wanIPGetExternalIPv6Address},
+		{action: wanCommonGetAddonInfos},
+		{action: wanCommonGetCommonLinkProperties, out: LinkProperties{}},
+		{action: wanDSLGetInfo, out: DSLInfo{}},
+		{action: lanGetStatistics, out: LANStatistics{}},
+		{action: hostsGetHostListPath},
+		{action: hostsGetHostNumberOfEntries},
+		{action: hostsGetGenericHostEntry, in: hostEntryArgs{}, out: Host{}},
+		{action: hostsGetMeshListPath},
+		{action: WLANConfigurationService(1).Action(wlanGetInfo), out: wlanInfo{}},
+		{action: WLANConfigurationService(1).Action(wlanGetStatistics)},
+		{action: WLANConfigurationService(2).Action(wlanGetByteStatistics)},
+		{action: WLANConfigurationService(2).Action(wlanGetTotalAssociations)},
+		{action: WLANConfigurationService(3).Action(wlanGetGenericAssociatedDeviceInfo), in: associatedDeviceArgs{}, out: AssociatedDevice{}},
+		{action: WLANConfigurationService(3).Action(wlanSetEnable), in: enableArgs{}},
+	}
+
+	for _, tt := range tests {
+		name := tt.action.Service.Type + "#" + tt.action.Name
+		t.Run(name, func(t *testing.T) {
+			service, ok := desc[tt.action.Service.Type]
+			if !ok {
+				t.Fatalf("Service %q is not in the device description", tt.action.Service.Type)
+			}
+			if service.ControlURL != tt.action.Service.ControlURL {
+				t.Errorf("ControlURL = %q, want %q", tt.action.Service.ControlURL, service.ControlURL)
+			}
+
+			scpd := loadSCPD(t, service.SCPDURL)
+			args, ok := scpd[tt.action.Name]
+			if !ok {
+				t.Fatalf("Action %q is not in %s", tt.action.Name, service.SCPDURL)
+			}
+
+			if got, want := xmlNames(tt.in), args["in"]; !reflect.DeepEqual(got, want) {
+				t.Errorf("Input arguments = %v, want %v", got, want)
+			}
+
+			for _, field := range xmlNames(tt.out) {
+				if !contains(args["out"], field) {
+					t.Errorf("Field %q is not an output argument, want one of %v", field, args["out"])
+				}
+			}
+		})
+	}
+}
+
+func TestCallDecodesRecordedResponse(t *testing.T) {
+	srv := newFixtureServer(t)
+	defer srv.Close()
+	srv.respond(DeviceInfoService.ControlURL, "GetInfoResponse.xml", http.StatusOK)
+	c := srv.client(t)
+
+	info, err := c.DeviceInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := DeviceInfo{
+		ManufacturerName: "AVM",
+		ModelName:        "FRITZ!Box 7590",
+		SerialNumber:     "3431C4000000",
+		SoftwareVersion:  "154.07.57",
+		HardwareVersion:  "FRITZ!Box 7590",
+		UpTime:           1209725,
+	}
+	if *info != want {
+		t.Errorf("DeviceInfo() = %+v, want %+v", *info, want)
+	}
+
+	if len(srv.requests) != 1 {
+		t.Fatalf("Server received %d requests, want 1", len(srv.requests))
+	}
+	req := srv.requests[0]
+	if req.SOAPAction != "urn:dslforum-org:service:DeviceInfo:1#GetInfo" {
+		t.Errorf("SOAPAction = %q", req.SOAPAction)
+	}
+	if req.Namespace != DeviceInfoService.Type || req.Action != "GetInfo" {
+		t.Errorf("Action element = %s %s", req.Namespace, req.Action)
+	}
+	if len(req.Args) != 0 {
+		t.Errorf("Arguments = %v, want none", req.Args)
+	}
+}
+
+func TestCallEncodesArguments(t *testing.T) {
+	srv := newFixtureServer(t)
+	defer srv.Close()
+	c := srv.client(t)
+
+	srv.respond("/upnp/control/wlanconfig3", "SetEnableResponse.xml", http.StatusOK)
+	if err := c.SetWLANEnabled(context.Background(), 3, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetWLANEnabled(context.Background(), 3, false); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.respond(HostsService.ControlURL, "GetGenericHostEntryFault.xml", http.StatusInternalServerError)
+	err := c.call(context.Background(), hostsGetGenericHostEntry, hostEntryArgs{Index: 7}, &Host{})
+
+	var f *Fault
+	if !errors.As(err, &f) {
+		t.Fatalf("Error = %v, want *Fault", err)
+	}
+	wantFault := Fault{
+		Service:     "/upnp/control/hosts",
+		Action:      "GetGenericHostEntry",
+		Code:        faultArrayIndexInvalid,
+		Description: "SpecifiedArrayIndexInvalid",
+	}
+	if *f != wantFault {
+		t.Errorf("Fault = %+v, want %+v", *f, wantFault)
+	}
+
+	want := []soapRequest{
+		{
+			Path:       "/upnp/control/wlanconfig3",
+			SOAPAction: "urn:dslforum-org:service:WLANConfiguration:3#SetEnable",
+			Namespace:  "urn:dslforum-org:service:WLANConfiguration:3",
+			Action:     "SetEnable",
+			Args:       map[string]string{"NewEnable": "1"},
+		},
+		{
+			Path:       "/upnp/control/wlanconfig3",
+			SOAPAction: "urn:dslforum-org:service:WLANConfiguration:3#SetEnable",
+			Namespace:  "urn:dslforum-org:service:WLANConfiguration:3",
+			Action:     "SetEnable",
+			Args:       map[string]string{"NewEnable": "0"},
+		},
+		{
+			Path:       "/upnp/control/hosts",
+			SOAPAction: "urn:dslforum-org:service:Hosts:1#GetGenericHostEntry",
+			Namespace:  "urn:dslforum-org:service:Hosts:1",
+			Action:     "GetGenericHostEntry",
+			Args:       map[string]string{"NewIndex": "7"},
+		},
+	}
+	if !reflect.DeepEqual(srv.requests, want) {
+		t.Errorf("Requests = %+v, want %+v", srv.requests, want)
+	}
+}
+
+// describedService is a service as it is listed in the device description.
+type describedService struct {
+	Type       string `xml:"serviceType"`
+	ControlURL string `xml:"controlURL"`
+	SCPDURL    string `xml:"SCPDURL"`
+}
+
+// loadDescription returns all services of a recorded device description by
+// their type.
+func loadDescription(t *testing.T, file string) map[string]describedService {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	services := map[string]describedService{}
+	dec := xml.NewDecoder(f)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "service" {
+			continue
+		}
+
+		var s describedService
+		if err := dec.DecodeElement(&s, &start); err != nil {
+			t.Fatal(err)
+		}
+		services[s.Type] = s
+	}
+
+	return services
+}
+
+// loadSCPD returns the sorted argument names of all actions of a recorded
+// service description by action name and direction ("in" or "out").
+func loadSCPD(t *testing.T, scpdURL string) map[string]map[string][]string {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(filepath.Join("testdata", scpdURL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var scpd struct {
+		Actions []struct {
+			Name      string `xml:"name"`
+			Arguments []struct {
+				Name      string `xml:"name"`
+				Direction string `xml:"direction"`
+			} `xml:"argumentList>argument"`
+		} `xml:"actionList>action"`
+	}
+	if err := xml.Unmarshal(data, &scpd); err != nil {
+		t.Fatalf("Failed to parse %s: %v", scpdURL, err)
+	}
+
+	actions := map[string]map[string][]string{}
+	for _, a := range scpd.Actions {
+		args := map[string][]string{}
+		for _, arg := range a.Arguments {
+			args[arg.Direction] = append(args[arg.Direction], arg.Name)
+		}
+		sort.Strings(args["in"])
+		actions[a.Name] = args
+	}
+
+	return actions
+}
+
+// xmlNames returns the sorted XML element names of the fields of a struct.
+func xmlNames(v interface{}) []string {
+	if v == nil {
+		return nil
+	}
+
+	var names []string
+	typ := reflect.TypeOf(v)
+	for i := 0; i < typ.NumField(); i++ {
+		tag := strings.Split(typ.Field(i).Tag.Get("xml"), ",")[0]
+		if tag != "" && tag != "-" {
+			names = append(names, tag)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package tr064
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"path"
+)
+
+// Service identifies a TR-064 service of the FRITZ!Box.
+type Service struct {
+	Type       string // e.g. "urn:dslforum-org:service:DeviceInfo:1"
+	ControlURL string // e.g. "/upnp/control/deviceinfo"
+}
+
+// Known TR-064 services.
+var (
+	DeviceInfoService        = Service{"urn:dslforum-org:service:DeviceInfo:1", "/upnp/control/deviceinfo"}
+	TimeService              = Service{"urn:dslforum-org:service:Time:1", "/upnp/control/time"}
+	WANIPConnectionService   = Service{"urn:dslforum-org:service:WANIPConnection:1", "/upnp/control/wanipconnection1"}
+	WANPPPConnectionService  = Service{"urn:dslforum-org:service:WANPPPConnection:1", "/upnp/control/wanpppconn1"}
+	WANCommonInterfaceConfig = Service{"urn:dslforum-org:service:WANCommonInterfaceConfig:1", "/upnp/control/wancommonifconfig1"}
+	WANDSLInterfaceConfig    = Service{"urn:dslforum-org:service:WANDSLInterfaceConfig:1", "/upnp/control/wandslifconfig1"}
+	LANEthernetInterface     = Service{"urn:dslforum-org:service:LANEthernetInterfaceConfig:1", "/upnp/control/lanethernetifcfg"}
+)
+
+type envelope struct {
+	Body struct {
+		Fault   *fault `xml:"Fault"`
+		Content []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+type fault struct {
+	Code   string `xml:"faultcode"`
+	String string `xml:"faultstring"`
+	Detail struct {
+		UPnPError struct {
+			Code        int    `xml:"errorCode"`
+			Description string `xml:"errorDescription"`
+		} `xml:"UPnPError"`
+	} `xml:"detail"`
+}
+
+// call executes the given action of a TR-064 service and decodes the response
+// arguments into out. The input arguments of the action are encoded from the
+// fields of in which may be nil if the action has no arguments.
+func (c *Client) call(ctx context.Context, action Action, in, out interface{}) error {
+	service := action.Service
+	if in == nil {
+		in = struct{}{}
+	}
+
+	body := new(bytes.Buffer)
+	body.WriteString(xml.Header)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	err := xml.NewEncoder(body).EncodeElement(in, xml.StartElement{
+		Name: xml.Name{Local: "u:" + action.Name},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns:u"}, Value: service.Type}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s arguments: %w", action.Name, err)
+	}
+	body.WriteString(`</s:Body></s:Envelope>`)
+
+	reqURL := c.BaseURL
+	reqURL.Path = path.Join(c.BaseURL.Path, service.ControlURL)
+
+	header := http.Header{}
+	header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	header.Set("SOAPAction", service.Type+"#"+action.Name)
+
+	resp, err := c.post(ctx, reqURL, header, body.Bytes())
+	if err != nil {
+		return fmt.Errorf("%s#%s: %w", service.ControlURL, action.Name, err)
+	}
+
+	var env envelope
+	err = xml.Unmarshal(resp, &env)
+	if err != nil {
+		return fmt.Errorf("failed to parse SOAP response: %w", err)
+	}
+
+	if f := env.Body.Fault; f != nil {
+		return &Fault{
+			Service:     service.ControlURL,
+			Action:      action.Name,
+			Code:        f.Detail.UPnPError.Code,
+			Description: f.Detail.UPnPError.Description,
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	err = xml.Unmarshal(env.Body.Content, out)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", action.Name, err)
+	}
+
+	return nil
+}
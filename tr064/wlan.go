@@ -0,0 +1,244 @@
+package tr064
+
+import (
+	"context"
+	"fmt"
+)
+
+// WLANConfigurationService returns the TR-064 service of the n-th WLAN of the
+// FRITZ!Box. Usually the first WLAN is the 2.4 GHz band, the second one is the
+// 5 GHz band and the last one is the guest network.
+func WLANConfigurationService(n int) Service {
+	return Service{
+		Type:       fmt.Sprintf("urn:dslforum-org:service:WLANConfiguration:%d", n),
+		ControlURL: fmt.Sprintf("/upnp/control/wlanconfig%d", n),
+	}
+}
+
+// WLANStatistics contains the state and traffic counters of a single WLAN. All
+// counters are reset when the FRITZ!Box reboots.
+type WLANStatistics struct {
+	Index          int    // the n in WLANConfiguration:n
+	Band           string // e.g. "2.4GHz", "5GHz" or "guest"
+	SSID           string
+	Enabled        bool
+	Guest          bool
+	Channel        int
+	PacketsSent    uint64
+	PacketsRecv    uint64
+	BytesSent      uint64
+	BytesRecv      uint64
+	ErrorsSent     uint64
+	ErrorsRecv     uint64
+	HasByteCounter bool // false if the FRITZ!OS version does not report byte counters
+	Clients        []AssociatedDevice
+}
+
+// AssociatedDevice is a client which is currently connected to a WLAN.
+type AssociatedDevice struct {
+	MACAddress     string `xml:"NewAssociatedDeviceMACAddress"`
+	IPAddress      string `xml:"NewAssociatedDeviceIPAddress"`
+	SignalStrength int    `xml:"NewX_AVM-DE_SignalStrength"` // in percent
+	Speed          int    `xml:"NewX_AVM-DE_Speed"`          // in Mbit/s
+}
+
+// WLANStatistics returns the traffic statistics of all WLANs of the FRITZ!Box.
+func (c *Client) WLANStatistics(ctx context.Context) ([]WLANStatistics, error) {
+	c.logger.Debug("Requesting WLAN statistics")
+
+	var result []WLANStatistics
+	for n := 1; n <= maxWLANs; n++ {
+		stats, err := c.wlanStatistics(ctx, n)
+		if err != nil && n == 1 {
+			return nil, err
+		}
+		if err != nil {
+			break // there are no more WLANs
+		}
+
+		result = append(result, *stats)
+	}
+
+	if n := len(result); isGuestWLAN(n, n) {
+		result[n-1].Guest = true
+		result[n-1].Band = guestBand
+	}
+
+	return result, nil
+}
+
+// WLANClient is a client which is connected to one of the WLANs of the FRITZ!Box.
+type WLANClient struct {
+	AssociatedDevice
+	Band string // e.g. "2.4GHz", "5GHz" or "guest"
+	SSID string
+}
+
+// WLANClients returns the clients of all WLANs of the FRITZ!Box.
+func (c *Client) WLANClients(ctx context.Context) ([]WLANClient, error) {
+	c.logger.Debug("Requesting WLAN clients")
+
+	var infos []*wlanInfo
+	for n := 1; n <= maxWLANs; n++ {
+		info, err := c.wlanInfo(ctx, WLANConfigurationService(n))
+		if err != nil && n == 1 {
+			return nil, err
+		}
+		if err != nil {
+			break // there are no more WLANs
+		}
+
+		infos = append(infos, info)
+	}
+
+	var result []WLANClient
+	for i, info := range infos {
+		n := i + 1
+		clients, err := c.associatedDevices(ctx, WLANConfigurationService(n))
+		if err != nil {
+			return nil, err
+		}
+
+		band := wlanBand(n, info.FrequencyBand)
+		if isGuestWLAN(n, len(infos)) {
+			band = guestBand
+		}
+
+		for _, client := range clients {
+			result = append(result, WLANClient{
+				AssociatedDevice: client,
+				Band:             band,
+				SSID:             info.SSID,
+			})
+		}
+	}
+
+	return result, nil
+}
+
+type wlanInfo struct {
+	Enabled       bool   `xml:"NewEnable"`
+	SSID          string `xml:"NewSSID"`
+	Channel       int    `xml:"NewChannel"`
+	FrequencyBand string `xml:"NewX_AVM-DE_FrequencyBand"`
+}
+
+func (c *Client) wlanInfo(ctx context.Context, service Service) (*wlanInfo, error) {
+	var info wlanInfo
+	err := c.call(ctx, service.Action(wlanGetInfo), nil, &info)
+	if err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// maxWLANs is the maximum number of WLANConfiguration services we probe for.
+// Tri-band boxes have two 5 GHz radios plus the guest network.
+const maxWLANs = 4
+
+func (c *Client) wlanStatistics(ctx context.Context, n int) (*WLANStatistics, error) {
+	service := WLANConfigurationService(n)
+
+	info, err := c.wlanInfo(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+
+	var packets struct {
+		Sent       uint64 `xml:"NewTotalPacketsSent"`
+		Recv       uint64 `xml:"NewTotalPacketsReceived"`
+		ErrorsSent uint64 `xml:"NewErrorsSent"`
+		ErrorsRecv uint64 `xml:"NewErrorsReceived"`
+	}
+	err = c.call(ctx, service.Action(wlanGetStatistics), nil, &packets)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &WLANStatistics{
+		Index:       n,
+		Band:        wlanBand(n, info.FrequencyBand),
+		SSID:        info.SSID,
+		Enabled:     info.Enabled,
+		Channel:     info.Channel,
+		PacketsSent: packets.Sent,
+		PacketsRecv: packets.Recv,
+		ErrorsSent:  packets.ErrorsSent,
+		ErrorsRecv:  packets.ErrorsRecv,
+	}
+
+	var bytes struct {
+		Sent uint64 `xml:"NewTotalBytesSent"`
+		Recv uint64 `xml:"NewTotalBytesReceived"`
+	}
+	err = c.call(ctx, service.Action(wlanGetByteStatistics), nil, &bytes)
+	if err == nil {
+		stats.BytesSent = bytes.Sent
+		stats.BytesRecv = bytes.Recv
+		stats.HasByteCounter = true
+	}
+
+	stats.Clients, err = c.associatedDevices(ctx, service)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (c *Client) associatedDevices(ctx context.Context, service Service) ([]AssociatedDevice, error) {
+	var total struct {
+		Associations int `xml:"NewTotalAssociations"`
+	}
+	err := c.call(ctx, service.Action(wlanGetTotalAssociations), nil, &total)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make([]AssociatedDevice, 0, total.Associations)
+	for i := 0; i < total.Associations; i++ {
+		var client AssociatedDevice
+		err := c.call(ctx, service.Action(wlanGetGenericAssociatedDeviceInfo), associatedDeviceArgs{Index: i}, &client)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, nil
+}
+
+// guestBand is used instead of the frequency band to label the guest WLAN.
+const guestBand = "guest"
+
+// isGuestWLAN returns true if the n-th of all count WLANs is the guest network.
+// The FRITZ!Box always offers the guest network as the last WLANConfiguration
+// service, i.e. the third one on dual-band and the fourth one on tri-band models.
+func isGuestWLAN(n, count int) bool {
+	return n >= 3 && n == count
+}
+
+// wlanBand translates the frequency band reported by the FRITZ!Box into a
+// label. Older FRITZ!OS versions do not report the band so we fall back to the
+// conventional order of the WLANConfiguration services.
+func wlanBand(n int, frequencyBand string) string {
+	switch frequencyBand {
+	case "2400":
+		return "2.4GHz"
+	case "5000":
+		return "5GHz"
+	case "6000":
+		return "6GHz"
+	}
+
+	switch n {
+	case 1:
+		return "2.4GHz"
+	case 2:
+		return "5GHz"
+	default:
+		return fmt.Sprintf("wlan%d", n)
+	}
+}
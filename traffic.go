@@ -0,0 +1,91 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+)
+
+// trafficBucketDuration is the time span of each value of the traffic
+// monitoring data. The FRITZ!Box reports the last 100 seconds in 20 buckets.
+const trafficBucketDuration = 5 * time.Second
+
+// trafficSeries is a single series of the traffic monitoring data.
+type trafficSeries struct {
+	direction string
+	class     string
+	values    []float64 // bytes per second, newest first
+}
+
+func trafficSeriesOf(stats *fritzbox.TrafficMonitoringData) []trafficSeries {
+	return []trafficSeries{
+		{"downstream", "internet", stats.DownstreamInternet},
+		{"downstream", "media", stats.DownStreamMedia},
+		{"downstream", "guest", stats.DownStreamGuest},
+		{"upstream", "realtime", stats.UpstreamRealtime},
+		{"upstream", "high_priority", stats.UpstreamHighPriority},
+		{"upstream", "default", stats.UpstreamDefaultPriority},
+		{"upstream", "low_priority", stats.UpstreamLowPriority},
+		{"upstream", "guest", stats.UpstreamGuest},
+	}
+}
+
+// newTrafficBuckets returns how many of the buckets received at now were not
+// yet seen at the previous collection and advances lastBucket accordingly. The
+// FRITZ!Box does not report timestamps, so the buckets are aligned to the time
+// of the first collection.
+func (m *NetworkMetrics) newTrafficBuckets(now time.Time, buckets int) int {
+	if m.lastBucket.IsZero() {
+		m.lastBucket = now
+		return buckets
+	}
+
+	n := int((now.Sub(m.lastBucket) + trafficBucketDuration/2) / trafficBucketDuration)
+	switch {
+	case n <= 0:
+		return 0
+	case n >= buckets:
+		// We missed some buckets, so the counters lose this traffic.
+		m.lastBucket = now
+		return buckets
+	default:
+		m.lastBucket = m.lastBucket.Add(time.Duration(n) * trafficBucketDuration)
+		return n
+	}
+}
+
+// collectTraffic adds the traffic of the new buckets to the byte counters and
+// sets the minimum, average and maximum bandwidth within these buckets.
+func (m *NetworkMetrics) collectTraffic(stats *fritzbox.TrafficMonitoringData, now time.Time) {
+	series := trafficSeriesOf(stats)
+
+	buckets := len(series[0].values)
+	for _, s := range series[1:] {
+		if len(s.values) < buckets {
+			buckets = len(s.values)
+		}
+	}
+
+	n := m.newTrafficBuckets(now, buckets)
+	if n == 0 {
+		return
+	}
+
+	for _, s := range series {
+		var sum, min, max float64
+		for i, bytesPerSecond := range s.values[:n] {
+			sum += bytesPerSecond
+			if i == 0 || bytesPerSecond < min {
+				min = bytesPerSecond
+			}
+			if bytesPerSecond > max {
+				max = bytesPerSecond
+			}
+		}
+
+		m.Bytes.WithLabelValues(s.direction, s.class).Add(sum * trafficBucketDuration.Seconds())
+		m.MinBandwidth.WithLabelValues(s.direction, s.class).Set(min * 8)
+		m.AvgBandwidth.WithLabelValues(s.direction, s.class).Set(sum / float64(n) * 8)
+		m.MaxBandwidth.WithLabelValues(s.direction, s.class).Set(max * 8)
+	}
+}
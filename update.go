@@ -0,0 +1,400 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// version is the version of fritz-mon. It is set at build time via
+// -ldflags "-X main.version=…" (see Makefile).
+var version = "dev"
+
+// releasePublicKey is the base64 encoded ed25519 public key which signs the
+// checksums of each release. It is set at build time via
+// -ldflags "-X main.releasePublicKey=…" (see Makefile). Without it, the update
+// command refuses to install any release.
+var releasePublicKey = ""
+
+const (
+	releasesURL        = "https://api.github.com/repos/fgrosse/fritz-mon/releases/latest"
+	checksumAssetName  = "SHA256SUMS"
+	signatureAssetName = "SHA256SUMS.sig"
+
+	// maxDownloadSize limits the size of each downloaded file and of the
+	// extracted binary. Release archives are far smaller.
+	maxDownloadSize = 100 << 20
+)
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	Assets  []struct {
+		Name        string `json:"name"`
+		DownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r githubRelease) assetURL(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.DownloadURL
+		}
+	}
+	return ""
+}
+
+func runUpdate(args []string) int {
+	flags := flag.NewFlagSet("update", flag.ContinueOnError)
+	checkOnly := flags.Bool("check-only", false, "only check if a new version is available")
+	if code, ok := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	fmt.Printf("Current version of fritz-mon is %s\n", version)
+	fmt.Println("  Checking for new releases on GitHub... ")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	release, err := latestRelease(ctx)
+	if err != nil {
+		fmt.Println("  ✘ Failed to check for new releases")
+		fmt.Println("    " + err.Error())
+		return 1
+	}
+
+	newer, err := isNewerVersion(release.TagName, version)
+	if err != nil {
+		fmt.Printf("  ✘ Cannot compare version %s with the latest release %s\n", version, release.TagName)
+		fmt.Println("    " + err.Error())
+		return 1
+	}
+
+	if !newer {
+		fmt.Println("  ✔ You are already running the latest version")
+		return 0
+	}
+
+	fmt.Printf("  ✔ Version %s is available\n", release.TagName)
+	if *checkOnly {
+		return 0
+	}
+
+	err = installRelease(ctx, release)
+	if err != nil {
+		fmt.Println("  ✘ Failed to update fritz-mon")
+		fmt.Println("    " + err.Error())
+		return 1
+	}
+
+	fmt.Printf("  ✔ fritz-mon has been updated to version %s\n", release.TagName)
+	fmt.Println("    Please restart fritz-mon for the update to take effect.")
+
+	return 0
+}
+
+func latestRelease(ctx context.Context) (githubRelease, error) {
+	var release githubRelease
+	body, err := download(ctx, releasesURL)
+	if err != nil {
+		return release, err
+	}
+
+	err = json.Unmarshal(body, &release)
+	if err != nil {
+		return release, fmt.Errorf("failed to decode GitHub release: %w", err)
+	}
+
+	if release.TagName == "" {
+		return release, errors.New("GitHub returned a release without a version tag")
+	}
+
+	return release, nil
+}
+
+// installRelease downloads the release archive for the current platform,
+// verifies the signature of the checksums and the checksum of the archive and
+// then replaces the currently running binary.
+func installRelease(ctx context.Context, release githubRelease) error {
+	archiveName := fmt.Sprintf("fritz-mon-%s.%s-%s.tar.gz", release.TagName, runtime.GOOS, runtime.GOARCH)
+	archiveURL := release.assetURL(archiveName)
+	if archiveURL == "" {
+		return fmt.Errorf("release %s has no archive for %s/%s", release.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+
+	checksumURL := release.assetURL(checksumAssetName)
+	if checksumURL == "" {
+		return fmt.Errorf("release %s has no %s file", release.TagName, checksumAssetName)
+	}
+
+	signatureURL := release.assetURL(signatureAssetName)
+	if signatureURL == "" {
+		return fmt.Errorf("release %s has no %s file", release.TagName, signatureAssetName)
+	}
+
+	fmt.Printf("  Downloading %s... \n", archiveName)
+	archive, err := download(ctx, archiveURL)
+	if err != nil {
+		return err
+	}
+
+	checksums, err := download(ctx, checksumURL)
+	if err != nil {
+		return err
+	}
+
+	signature, err := download(ctx, signatureURL)
+	if err != nil {
+		return err
+	}
+
+	err = verifySignature(checksums, signature, releasePublicKey)
+	if err != nil {
+		return err
+	}
+	fmt.Println("  ✔ The signature of the checksums is valid")
+
+	err = verifyChecksum(archive, archiveName, checksums)
+	if err != nil {
+		return err
+	}
+	fmt.Println("  ✔ The checksum of the downloaded archive is valid")
+
+	binary, err := extractBinary(archive, "fritz-mon")
+	if err != nil {
+		return err
+	}
+
+	return replaceExecutable(binary)
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+
+	req = req.WithContext(ctx)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad HTTP status code for %s: %s", url, resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDownloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HTTP response body: %w", err)
+	}
+
+	if len(body) > maxDownloadSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", url, maxDownloadSize)
+	}
+
+	return body, nil
+}
+
+// isNewerVersion returns true if the release is strictly newer than the
+// current version. Both are semantic versions with an optional "v" prefix. A
+// version with a pre-release suffix (e.g. "v1.2.0-rc1") is older than the same
+// version without one; pre-releases are compared lexically.
+func isNewerVersion(release, current string) (bool, error) {
+	r, err := parseSemver(release)
+	if err != nil {
+		return false, err
+	}
+
+	c, err := parseSemver(current)
+	if err != nil {
+		return false, err
+	}
+
+	for i := range r.numbers {
+		if r.numbers[i] != c.numbers[i] {
+			return r.numbers[i] > c.numbers[i], nil
+		}
+	}
+
+	switch {
+	case r.pre == c.pre:
+		return false, nil
+	case r.pre == "":
+		return true, nil
+	case c.pre == "":
+		return false, nil
+	default:
+		return r.pre > c.pre, nil
+	}
+}
+
+type semver struct {
+	numbers [3]int // major, minor and patch
+	pre     string // pre-release suffix without "-"
+}
+
+// gitDescribeSuffix matches the suffix which "git describe --dirty" appends
+// to the tag of builds between two releases, e.g. "-4-gabcdef1-dirty".
+var gitDescribeSuffix = regexp.MustCompile(`(-[0-9]+-g[0-9a-f]+)?(-dirty)?$`)
+
+// parseSemver parses versions like "v1.2.3" or "1.2.3-rc1". Build metadata
+// ("+…") and the suffix of "git describe" are ignored, so a build between two
+// releases is considered to be the release it is based on.
+func parseSemver(s string) (semver, error) {
+	var v semver
+	rest := strings.TrimPrefix(s, "v")
+	if i := strings.Index(rest, "+"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	rest = gitDescribeSuffix.ReplaceAllString(rest, "")
+	if i := strings.Index(rest, "-"); i >= 0 {
+		rest, v.pre = rest[:i], rest[i+1:]
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) != len(v.numbers) {
+		return v, fmt.Errorf("%q is not a semantic version", s)
+	}
+
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("%q is not a semantic version", s)
+		}
+		v.numbers[i] = n
+	}
+
+	return v, nil
+}
+
+// verifySignature checks the base64 encoded ed25519 signature of the checksums
+// against the public key of the releases.
+func verifySignature(checksums, signature []byte, publicKey string) error {
+	if publicKey == "" {
+		return errors.New("this build of fritz-mon has no public key to verify releases, please update manually")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("invalid public key to verify releases")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature in %s", signatureAssetName)
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(key), checksums, sig) {
+		return fmt.Errorf("signature of %s does not match the public key of the releases", checksumAssetName)
+	}
+
+	return nil
+}
+
+// verifyChecksum checks the data against the checksum of the file with the
+// given name. The checksums are expected in the format of sha256sum(1).
+func verifyChecksum(data []byte, name string, checksums []byte) error {
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+
+		if fields[0] != actual {
+			return fmt.Errorf("checksum mismatch for %s: expected %s but got %s", name, fields[0], actual)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("no checksum found for %s", name)
+}
+
+func extractBinary(archive []byte, name string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress archive: %w", err)
+	}
+
+	r := tar.NewReader(gz)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("archive does not contain %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		if filepath.Base(header.Name) != name || header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		binary, err := ioutil.ReadAll(io.LimitReader(r, maxDownloadSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %q from archive: %w", name, err)
+		}
+
+		if len(binary) > maxDownloadSize {
+			return nil, fmt.Errorf("%q in archive is larger than %d bytes", name, maxDownloadSize)
+		}
+
+		return binary, nil
+	}
+}
+
+// replaceExecutable atomically replaces the currently running binary. The new
+// binary is written next to the old one first so the final rename does not
+// cross file system boundaries.
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to determine path of fritz-mon binary: %w", err)
+	}
+
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return fmt.Errorf("failed to determine path of fritz-mon binary: %w", err)
+	}
+
+	tmp := exe + ".new"
+	err = ioutil.WriteFile(tmp, binary, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to write new binary: %w", err)
+	}
+
+	err = os.Rename(tmp, exe)
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to replace %s: %w", exe, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsNewerVersion(t *testing.T) {
+	cases := []struct {
+		release, current string
+		want             bool
+	}{
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.4", "v1.2.3", true},
+		{"v1.10.0", "v1.9.0", true},
+		{"v1.9.0", "v1.10.0", false}, // must not downgrade
+		{"v2.0.0", "v1.99.99", true},
+		{"v1.2.3", "1.2.3", false},
+		{"v1.2.3", "v1.2.3-rc1", true},
+		{"v1.2.3-rc2", "v1.2.3-rc1", true},
+		{"v1.2.3-rc1", "v1.2.3", false},
+		{"v1.2.3", "v1.2.3-4-gabcdef1", false},
+		{"v1.2.3", "v1.2.3-4-gabcdef1-dirty", false},
+		{"v1.2.4", "v1.2.3-4-gabcdef1", true},
+	}
+
+	for _, c := range cases {
+		got, err := isNewerVersion(c.release, c.current)
+		if err != nil {
+			t.Errorf("isNewerVersion(%q, %q): %v", c.release, c.current, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("isNewerVersion(%q, %q) = %v, want %v", c.release, c.current, got, c.want)
+		}
+	}
+
+	for _, current := range []string{"dev", "1.2", "v1.x.3"} {
+		if _, err := isNewerVersion("v1.2.3", current); err == nil {
+			t.Errorf("isNewerVersion(\"v1.2.3\", %q) did not fail", current)
+		}
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksums := []byte("0123abcd  fritz-mon-v1.2.3.linux-amd64.tar.gz\n")
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, checksums)) + "\n"
+	publicKey := base64.StdEncoding.EncodeToString(public)
+
+	if err := verifySignature(checksums, []byte(signature), publicKey); err != nil {
+		t.Errorf("valid signature was rejected: %v", err)
+	}
+
+	tampered := append([]byte("ffff"), checksums[4:]...)
+	if err := verifySignature(tampered, []byte(signature), publicKey); err == nil {
+		t.Error("signature of tampered checksums was accepted")
+	}
+
+	if err := verifySignature(checksums, []byte(signature), ""); err == nil {
+		t.Error("signature was accepted without a public key")
+	}
+
+	if err := verifySignature(checksums, []byte("invalid"), publicKey); err == nil {
+		t.Error("invalid signature was accepted")
+	}
+}
+
+func TestDownloadLimitsSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("x", maxDownloadSize+1)))
+	}))
+	defer srv.Close()
+
+	if _, err := download(context.Background(), srv.URL); err == nil {
+		t.Error("download of a file larger than maxDownloadSize did not fail")
+	}
+}
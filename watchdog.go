@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fgrosse/fritz-mon/tr064"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// Actions of the guest WLAN watchdog.
+const (
+	WatchdogActionAlert   = "alert"
+	WatchdogActionDisable = "disable"
+)
+
+// GuestWLANWatchdogConfig configures the rule which detects a guest WLAN that
+// was left enabled for too long.
+type GuestWLANWatchdogConfig struct {
+	MaxEnabled time.Duration `yaml:"max_enabled"`        // how long the guest WLAN may be enabled, zero disables the watchdog
+	Action     string        `yaml:"action"`             // "alert" to log a warning or "disable" to switch the guest WLAN off
+	Interval   time.Duration `yaml:"interval,omitempty"` // how often to check the guest WLAN
+}
+
+func (c GuestWLANWatchdogConfig) Enabled() bool {
+	return c.MaxEnabled > 0
+}
+
+// interval returns how often the watchdog runs or zero if it is disabled.
+func (c GuestWLANWatchdogConfig) interval() time.Duration {
+	if !c.Enabled() {
+		return 0
+	}
+	return c.Interval
+}
+
+func (c GuestWLANWatchdogConfig) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	if c.Action != WatchdogActionAlert && c.Action != WatchdogActionDisable {
+		return fmt.Errorf("guest_wlan_watchdog.action must be %q or %q", WatchdogActionAlert, WatchdogActionDisable)
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("guest_wlan_watchdog.interval must be positive")
+	}
+
+	return nil
+}
+
+type GuestWLANWatchdog struct {
+	EnabledDuration prometheus.Gauge   // how long the guest WLAN has been enabled
+	Triggered       prometheus.Counter // how often the watchdog fired
+
+	Config GuestWLANWatchdogConfig
+
+	logger       *zap.Logger
+	enabledSince time.Time // zero if the guest WLAN is disabled
+	triggered    bool      // the watchdog already fired for the current period
+}
+
+func NewGuestWLANWatchdog(logger *zap.Logger) *GuestWLANWatchdog {
+	namespace := "fritzbox"
+	subsystem := "wlan"
+
+	return &GuestWLANWatchdog{
+		logger: logger,
+		EnabledDuration: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "guest_enabled_seconds",
+				Help:      "Time in seconds since the guest WLAN was enabled as observed by fritz-mon or 0 if it is disabled.",
+			},
+		),
+		Triggered: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "guest_watchdog_triggered_total",
+				Help:      "Number of times the guest WLAN was enabled for longer than allowed.",
+			},
+		),
+	}
+}
+
+func (w *GuestWLANWatchdog) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		w.EnabledDuration,
+		w.Triggered,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check reads the state of the guest WLAN and fires the configured action if
+// it has been enabled for longer than allowed. Since the FRITZ!Box does not
+// report when the guest WLAN was enabled, the duration is measured from the
+// first check which saw it enabled.
+func (w *GuestWLANWatchdog) Check(ctx context.Context, client *tr064.Client) (*tr064.GuestWLAN, error) {
+	guest, err := client.GuestWLAN(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !guest.Enabled {
+		w.enabledSince = time.Time{}
+		w.triggered = false
+		w.EnabledDuration.Set(0)
+		return guest, nil
+	}
+
+	if w.enabledSince.IsZero() {
+		w.enabledSince = now
+	}
+
+	enabled := now.Sub(w.enabledSince)
+	w.EnabledDuration.Set(enabled.Seconds())
+	if enabled < w.Config.MaxEnabled || w.triggered {
+		return guest, nil
+	}
+
+	w.triggered = true
+	w.Triggered.Inc()
+
+	if w.Config.Action != WatchdogActionDisable {
+		w.logger.Warn("Guest WLAN has been enabled for too long",
+			zap.String("ssid", guest.SSID),
+			zap.Duration("enabled", enabled),
+		)
+		return guest, nil
+	}
+
+	w.logger.Warn("Disabling guest WLAN since it has been enabled for too long",
+		zap.String("ssid", guest.SSID),
+		zap.Duration("enabled", enabled),
+	)
+
+	err = client.SetWLANEnabled(ctx, guest.Index, false)
+	if err != nil {
+		w.triggered = false // try again next time
+		return nil, fmt.Errorf("failed to disable guest WLAN: %w", err)
+	}
+
+	return guest, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type WebhookConfig struct {
+	URL     string        `yaml:"url"`     // webhook is disabled if empty
+	Secret  string        `yaml:"secret"`  // optional key to sign each payload via HMAC-SHA256
+	Timeout time.Duration `yaml:"timeout"` // timeout of each HTTP request
+}
+
+func (c WebhookConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+func (c WebhookConfig) Validate() error {
+	if c.Enabled() && c.Timeout <= 0 {
+		return fmt.Errorf("webhook.timeout must be positive")
+	}
+	return nil
+}
+
+// webhookSignatureHeader contains the hex encoded HMAC-SHA256 of the request
+// body if a webhook secret is configured.
+const webhookSignatureHeader = "X-Fritz-Mon-Signature"
+
+// webhookPayload is the JSON document which is posted to the webhook after
+// each completed collection.
+type webhookPayload struct {
+	Box             string            `json:"box"`
+	Collector       string            `json:"collector"`
+	Timestamp       time.Time         `json:"timestamp"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	DeviceErrors    map[string]string `json:"device_errors,omitempty"`
+	Data            interface{}       `json:"data"`
+}
+
+// webhook posts the raw data of each collection to a configured URL.
+type webhook struct {
+	conf       WebhookConfig
+	http       *http.Client
+	pseudonyms *pseudonymizer
+	logger     *zap.Logger
+}
+
+func newWebhook(conf WebhookConfig, pseudonyms *pseudonymizer, logger *zap.Logger) *webhook {
+	return &webhook{
+		conf:       conf,
+		http:       &http.Client{Timeout: conf.Timeout},
+		pseudonyms: pseudonyms,
+		logger:     logger,
+	}
+}
+
+func (w *webhook) Post(ctx context.Context, result CollectionResult) {
+	err := w.post(ctx, result)
+	if err != nil {
+		w.logger.Error("Failed to post collection to webhook", zap.String("box", result.Box), zap.String("collector", result.Collector), zap.Error(err))
+		return
+	}
+
+	w.logger.Debug("Posted collection to webhook", zap.String("box", result.Box), zap.String("collector", result.Collector))
+}
+
+func (w *webhook) post(ctx context.Context, result CollectionResult) error {
+	body, err := json.Marshal(webhookPayload{
+		Box:             w.pseudonyms.label("box", result.Box),
+		Collector:       result.Collector,
+		Timestamp:       result.Start,
+		DurationSeconds: result.Duration.Seconds(),
+		DeviceErrors:    w.pseudonyms.deviceErrors(result.DeviceErrors),
+		Data:            w.pseudonyms.data(result.Data),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", w.conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build HTTP request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if w.conf.Secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+signPayload(w.conf.Secret, body))
+	}
+
+	req = req.WithContext(ctx)
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %w", err)
+	}
+
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("bad HTTP status code: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"time"
+
+	"github.com/fgrosse/fritz-mon/fritzbox"
+)
+
+// collectWindowOpen accumulates the time during which a thermostat reports an
+// open window. Since we only see the state at each collection, the whole
+// period since the previous reading is counted if the window is open now.
+func (m *DeviceMetrics) collectWindowOpen(device fritzbox.Device, now time.Time) {
+	last, ok := m.lastWindowUpdate[device.Name]
+	m.lastWindowUpdate[device.Name] = now
+
+	counter := m.WindowOpen.WithLabelValues(m.labels.values(device)...)
+	if !ok || !device.Thermostat.IsWindowOpen() {
+		return
+	}
+
+	if elapsed := now.Sub(last); elapsed > 0 && elapsed <= maxDegreeDayGap {
+		counter.Add(elapsed.Seconds())
+	}
+}
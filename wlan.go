@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+
+	"github.com/fgrosse/fritz-mon/tr064"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type WLANMetrics struct {
+	PacketsSent     *prometheus.GaugeVec // WLANConfiguration NewTotalPacketsSent
+	PacketsReceived *prometheus.GaugeVec // WLANConfiguration NewTotalPacketsReceived
+	BytesSent       *prometheus.GaugeVec // WLANConfiguration NewTotalBytesSent
+	BytesReceived   *prometheus.GaugeVec // WLANConfiguration NewTotalBytesReceived
+	ErrorsSent      *prometheus.GaugeVec // WLANConfiguration NewErrorsSent
+	ErrorsReceived  *prometheus.GaugeVec // WLANConfiguration NewErrorsReceived
+	Enabled         *prometheus.GaugeVec // WLANConfiguration NewEnable
+	Channel         *prometheus.GaugeVec // WLANConfiguration NewChannel
+	Stations        *prometheus.GaugeVec // WLANConfiguration NewTotalAssociations
+	ClientSignal    *prometheus.GaugeVec // signal strength of each connected client
+	ClientSpeed     *prometheus.GaugeVec // link speed of each connected client
+	GuestEnabled    prometheus.Gauge     // WLANConfiguration NewEnable of the guest network
+	GuestClients    prometheus.Gauge     // WLANConfiguration NewTotalAssociations of the guest network
+
+	Names *hostNames // optional resolver for the names of connected clients
+
+	logger *zap.Logger
+}
+
+func NewWLANMetrics(logger *zap.Logger) *WLANMetrics {
+	namespace := "fritzbox"
+	subsystem := "wlan"
+	labelNames := []string{"band", "ssid"}
+	clientLabelNames := []string{"band", "mac", "name"}
+
+	return &WLANMetrics{
+		logger: logger,
+		PacketsSent: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "packets_sent_total",
+				Help:      "Number of packets sent via the WLAN since the last reboot of the FRITZ!Box.",
+			},
+			labelNames,
+		),
+		PacketsReceived: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "packets_received_total",
+				Help:      "Number of packets received via the WLAN since the last reboot of the FRITZ!Box.",
+			},
+			labelNames,
+		),
+		BytesSent: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bytes_sent_total",
+				Help:      "Number of bytes sent via the WLAN since the last reboot of the FRITZ!Box.",
+			},
+			labelNames,
+		),
+		BytesReceived: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "bytes_received_total",
+				Help:      "Number of bytes received via the WLAN since the last reboot of the FRITZ!Box.",
+			},
+			labelNames,
+		),
+		ErrorsSent: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "errors_sent_total",
+				Help:      "Number of errors when sending packets via the WLAN since the last reboot of the FRITZ!Box.",
+			},
+			labelNames,
+		),
+		ErrorsReceived: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "errors_received_total",
+				Help:      "Number of errors when receiving packets via the WLAN since the last reboot of the FRITZ!Box.",
+			},
+			labelNames,
+		),
+		Enabled: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "enabled_bool",
+				Help:      "Either 0 or 1 to indicate if the WLAN is enabled.",
+			},
+			labelNames,
+		),
+		Channel: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "channel",
+				Help:      "Radio channel which is currently used by the WLAN.",
+			},
+			labelNames,
+		),
+		Stations: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "associated_stations",
+				Help:      "Number of clients which are currently connected to the WLAN.",
+			},
+			labelNames,
+		),
+		ClientSignal: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "client_signal_strength_percent",
+				Help:      "Signal strength of a client which is connected to the WLAN.",
+			},
+			clientLabelNames,
+		),
+		ClientSpeed: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "client_speed_mbps",
+				Help:      "Current link speed of a client which is connected to the WLAN in Mbit/s.",
+			},
+			clientLabelNames,
+		),
+		GuestEnabled: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "guest_enabled_bool",
+				Help:      "Either 0 or 1 to indicate if the guest WLAN is enabled.",
+			},
+		),
+		GuestClients: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "guest_clients",
+				Help:      "Number of clients which are currently connected to the guest WLAN.",
+			},
+		),
+	}
+}
+
+func (m *WLANMetrics) Register(r prometheus.Registerer) error {
+	metrics := []prometheus.Collector{
+		m.PacketsSent,
+		m.PacketsReceived,
+		m.BytesSent,
+		m.BytesReceived,
+		m.ErrorsSent,
+		m.ErrorsReceived,
+		m.Enabled,
+		m.Channel,
+		m.Stations,
+		m.ClientSignal,
+		m.ClientSpeed,
+		m.GuestEnabled,
+		m.GuestClients,
+	}
+
+	for _, metric := range metrics {
+		if err := r.Register(metric); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *WLANMetrics) FetchFrom(ctx context.Context, client *tr064.Client) ([]tr064.WLANStatistics, error) {
+	wlans, err := client.WLANStatistics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Names != nil {
+		err = m.Names.refresh(ctx, client)
+		if err != nil {
+			m.logger.Warn("Failed to fetch host names", zap.Error(err))
+		}
+	}
+
+	// Clients come and go so we only keep the series of connected clients.
+	m.ClientSignal.Reset()
+	m.ClientSpeed.Reset()
+
+	for _, wlan := range wlans {
+		for _, c := range wlan.Clients {
+			name := m.Names.Name(c.MACAddress)
+			m.ClientSignal.WithLabelValues(wlan.Band, c.MACAddress, name).Set(float64(c.SignalStrength))
+			m.ClientSpeed.WithLabelValues(wlan.Band, c.MACAddress, name).Set(float64(c.Speed))
+		}
+
+		if wlan.Guest {
+			m.GuestEnabled.Set(prometheusBool(wlan.Enabled))
+			m.GuestClients.Set(float64(len(wlan.Clients)))
+		}
+
+		m.Enabled.WithLabelValues(wlan.Band, wlan.SSID).Set(prometheusBool(wlan.Enabled))
+		m.Channel.WithLabelValues(wlan.Band, wlan.SSID).Set(float64(wlan.Channel))
+		m.Stations.WithLabelValues(wlan.Band, wlan.SSID).Set(float64(len(wlan.Clients)))
+		m.PacketsSent.WithLabelValues(wlan.Band, wlan.SSID).Set(float64(wlan.PacketsSent))
+		m.PacketsReceived.WithLabelValues(wlan.Band, wlan.SSID).Set(float64(wlan.PacketsRecv))
+		m.ErrorsSent.WithLabelValues(wlan.Band, wlan.SSID).Set(float64(wlan.ErrorsSent))
+		m.ErrorsReceived.WithLabelValues(wlan.Band, wlan.SSID).Set(float64(wlan.ErrorsRecv))
+		if wlan.HasByteCounter {
+			m.BytesSent.WithLabelValues(wlan.Band, wlan.SSID).Set(float64(wlan.BytesSent))
+			m.BytesReceived.WithLabelValues(wlan.Band, wlan.SSID).Set(float64(wlan.BytesRecv))
+		}
+
+		m.logger.Debug("Collected WLAN metrics",
+			zap.Int("wlan", wlan.Index),
+			zap.String("band", wlan.Band),
+			zap.String("ssid", wlan.SSID),
+			zap.Bool("enabled", wlan.Enabled),
+			zap.Int("channel", wlan.Channel),
+			zap.Uint64("packets_sent", wlan.PacketsSent),
+			zap.Uint64("packets_received", wlan.PacketsRecv),
+			zap.Int("clients", len(wlan.Clients)),
+		)
+	}
+
+	return wlans, nil
+}